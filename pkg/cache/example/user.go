@@ -3,7 +3,6 @@ package example
 import (
 	"errors"
 	"fmt"
-	"reflect"
 	"sync"
 	"time"
 
@@ -44,7 +43,7 @@ func (u userManager) Add(obj *User) (*User, error) {
 	meta.UpdateTime = time.Now()
 	meta.UUID = uuid.New().String()
 
-	err := u.Indexer.Add(meta)
+	err := u.users.Add(meta)
 	if err != nil {
 		return nil, err
 	}
@@ -59,109 +58,66 @@ func (u userManager) Update(obj *User) error {
 	meta := obj.DeepCopy()
 	meta.UpdateTime = time.Now()
 
-	return u.Indexer.Update(obj)
+	return u.users.Update(obj)
 }
 
 func (u userManager) Delete(obj *User) error {
-	return u.Indexer.Delete(obj)
+	return u.users.Delete(obj)
 }
 
 func (u userManager) List() []*User {
-	objects := u.Indexer.List()
-	users := make([]*User, 0, len(objects))
-	for _, v := range objects {
-		if u, ok := v.(*User); ok {
-			users = append(users, u.DeepCopy())
-		}
-	}
-	return users
+	return deepCopyUsers(u.users.List())
 }
 
-func (u userManager) Get(obj interface{}) (*User, bool, error) {
-	meta, exists, err := u.Indexer.Get(obj)
-	if err != nil {
-		return nil, false, err
-	}
-
-	if !exists {
-		return nil, false, nil
-	}
+func (u userManager) ListKeys() []string {
+	return u.users.ListKeys()
+}
 
-	item, ok := meta.(*User)
+func (u userManager) Get(obj interface{}) (*User, bool, error) {
+	user, ok := obj.(*User)
 	if !ok {
 		return nil, false, errors.New("object is not user")
 	}
 
+	item, exists, err := u.users.Get(user)
+	if err != nil || !exists {
+		return nil, false, err
+	}
 	return item.DeepCopy(), true, nil
 }
 
 func (u userManager) GetByKey(key string) (*User, bool, error) {
-	obj, exists, err := u.Indexer.GetByKey(key)
-	if err != nil {
+	item, exists, err := u.users.GetByKey(key)
+	if err != nil || !exists {
 		return nil, exists, err
 	}
-
-	if !exists {
-		return nil, false, nil
-	}
-
-	item, ok := obj.(*User)
-	if !ok {
-		return nil, true, errors.New("object is not User")
-	}
-
-	return item, false, nil
+	return item.DeepCopy(), true, nil
 }
 
 func (u userManager) Replace(users []*User, s string) error {
-	items := make([]interface{}, 0, len(users))
-	for _, v := range users {
-		items = append(items, v.DeepCopy())
-	}
-
-	return u.Indexer.Replace(items, s)
+	return u.users.Replace(deepCopyUsers(users), s)
 }
 
 func (u userManager) ListInTenant(tenant string) []*User {
-	users := make([]*User, 0)
-
-	objects, err := u.Indexer.Index(indexTypeTenantUser, &User{Tenant: tenant})
-	if err != nil {
-		return users
-	}
-	for _, v := range objects {
-		if u, ok := v.(*User); ok {
-			users = append(users, u.DeepCopy())
-		}
-	}
-	return users
+	users, _ := u.users.Index(indexTypeTenantUser, &User{Tenant: tenant})
+	return deepCopyUsers(users)
 }
 
 func (u userManager) ListInTenantIndex(tenant string) []string {
-	objects, err := u.Indexer.IndexKeys(indexTypeTenantUser, tenant)
+	objects, err := u.users.IndexKeys(indexTypeTenantUser, tenant)
 	if err != nil {
 		return nil
 	}
 	return objects
 }
 
-func (u userManager) ListInGroup(groups string) []*User {
-	users := make([]*User, 0)
-
-	objects, err := u.Indexer.Index(indexTypeGroupUser, &User{Group: []string{groups}})
-	if err != nil {
-		return users
-	}
-	for _, v := range objects {
-		if u, ok := v.(*User); ok {
-			users = append(users, u.DeepCopy())
-		}
-	}
-	return users
+func (u userManager) ListInGroup(group string) []*User {
+	users, _ := u.users.ByIndex(indexTypeGroupUser, group)
+	return deepCopyUsers(users)
 }
 
 func (u userManager) ListInGroupIndex(group string) []string {
-	objects, err := u.Indexer.IndexKeys(indexTypeGroupUser, group)
+	objects, err := u.users.IndexKeys(indexTypeGroupUser, group)
 	if err != nil {
 		return nil
 	}
@@ -169,39 +125,27 @@ func (u userManager) ListInGroupIndex(group string) []string {
 }
 
 func (u userManager) CleanGroup(group string) error {
-	objects, err := u.Indexer.Index(indexTypeGroupUser, &User{Group: []string{group}})
+	users, err := u.users.ByIndex(indexTypeGroupUser, group)
 	if err != nil {
 		return err
 	}
-	for _, v := range objects {
-		if user, ok := v.(*User); ok {
-			meta := user.DeepCopy()
-			meta.Group = sets.NewString(meta.Group...).Delete(group).List()
-			if err := u.Indexer.Update(meta); err != nil {
-				return err
-			}
+	for _, user := range users {
+		meta := user.DeepCopy()
+		meta.Group = sets.NewString(meta.Group...).Delete(group).List()
+		if err := u.users.Update(meta); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
 func (u userManager) ListInRole(role string) []*User {
-	users := make([]*User, 0)
-
-	objects, err := u.Indexer.Index(indexTypeRoleUser, &User{Roles: []string{role}})
-	if err != nil {
-		return users
-	}
-	for _, v := range objects {
-		if u, ok := v.(*User); ok {
-			users = append(users, u.DeepCopy())
-		}
-	}
-	return users
+	users, _ := u.users.ByIndex(indexTypeRoleUser, role)
+	return deepCopyUsers(users)
 }
 
 func (u userManager) ListInRoleIndex(role string) []string {
-	objects, err := u.Indexer.IndexKeys(indexTypeRoleUser, role)
+	objects, err := u.users.IndexKeys(indexTypeRoleUser, role)
 	if err != nil {
 		return nil
 	}
@@ -209,22 +153,28 @@ func (u userManager) ListInRoleIndex(role string) []string {
 }
 
 func (u userManager) CleanRole(role string) error {
-	objects, err := u.Indexer.Index(indexTypeRoleUser, &User{Roles: []string{role}})
+	users, err := u.users.ByIndex(indexTypeRoleUser, role)
 	if err != nil {
 		return err
 	}
-	for _, v := range objects {
-		if user, ok := v.(*User); ok {
-			meta := user.DeepCopy()
-			meta.Roles = sets.NewString(meta.Roles...).Delete(role).List()
-			if err := u.Indexer.Update(meta); err != nil {
-				return err
-			}
+	for _, user := range users {
+		meta := user.DeepCopy()
+		meta.Roles = sets.NewString(meta.Roles...).Delete(role).List()
+		if err := u.users.Update(meta); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+func deepCopyUsers(users []*User) []*User {
+	out := make([]*User, 0, len(users))
+	for _, v := range users {
+		out = append(out, v.DeepCopy())
+	}
+	return out
+}
+
 var _ UserManagerInterface = &userManager{}
 
 var (
@@ -234,41 +184,33 @@ var (
 
 func GetUMInstance() UserManagerInterface {
 	umOnce.Do(func() {
-		userIndexers := make(map[string]cache.IndexFunc)
-		userIndexers[indexTypeTenantUser] = tenantUserIndexer
-		userIndexers[indexTypeGroupUser] = groupUserIndexer
-		userIndexers[indexTypeRoleUser] = roleUserIndexer
-
-		umInstance = &userManager{
-			Indexer: cache.NewIndexer(userKeyFunc, userIndexers),
-		}
+		umInstance = newUserManager()
 	})
 	return umInstance
 }
 
 func NewUMInstance() UserManagerInterface {
-	userIndexers := make(map[string]cache.IndexFunc)
-	userIndexers[indexTypeTenantUser] = tenantUserIndexer
-	userIndexers[indexTypeGroupUser] = groupUserIndexer
-	userIndexers[indexTypeRoleUser] = roleUserIndexer
+	return newUserManager()
+}
 
+func newUserManager() *userManager {
 	return &userManager{
-		Indexer: cache.NewIndexer(userKeyFunc, userIndexers),
+		users: cache.NewTypedIndexer[*User](userKeyFunc, cache.TypedIndexers[*User]{
+			indexTypeTenantUser: tenantUserIndexer,
+			indexTypeGroupUser:  groupUserIndexer,
+			indexTypeRoleUser:   roleUserIndexer,
+		}),
 	}
 }
 
 type userManager struct {
-	cache.Indexer
+	users cache.TypedIndexer[*User]
 }
 
-func userKeyFunc(obj interface{}) (string, error) {
-	if obj == nil {
+func userKeyFunc(user *User) (string, error) {
+	if user == nil {
 		return "", fmt.Errorf("object is nil")
 	}
-	user, ok := obj.(*User)
-	if !ok {
-		return "", fmt.Errorf("object is %v,not %v type", reflect.TypeOf(obj), reflect.TypeOf(&User{}))
-	}
 	return user.UUID, nil
 }
 
@@ -279,26 +221,14 @@ const (
 	indexTypeRoleUser   = "roleUser"
 )
 
-func tenantUserIndexer(obj interface{}) ([]string, error) {
-	user, ok := obj.(*User)
-	if !ok {
-		return []string{""}, fmt.Errorf("object is %v,not %v type", reflect.TypeOf(obj), reflect.TypeOf(&User{}))
-	}
+func tenantUserIndexer(user *User) ([]string, error) {
 	return []string{user.Tenant}, nil
 }
 
-func groupUserIndexer(obj interface{}) ([]string, error) {
-	user, ok := obj.(*User)
-	if !ok {
-		return []string{""}, fmt.Errorf("object is %v,not %v type", reflect.TypeOf(obj), reflect.TypeOf(&User{}))
-	}
+func groupUserIndexer(user *User) ([]string, error) {
 	return user.Group, nil
 }
 
-func roleUserIndexer(obj interface{}) ([]string, error) {
-	user, ok := obj.(*User)
-	if !ok {
-		return []string{""}, fmt.Errorf("object is %v,not %v type", reflect.TypeOf(obj), reflect.TypeOf(&User{}))
-	}
+func roleUserIndexer(user *User) ([]string, error) {
 	return user.Roles, nil
 }
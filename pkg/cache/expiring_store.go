@@ -0,0 +1,588 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+	"fmt"
+	"time"
+
+	"github.com/wangweihong/gotoolbox/pkg/clock"
+)
+
+// Eviction 超出容量时的淘汰算法.
+type Eviction int
+
+const (
+	// EvictLRU 淘汰最久未访问的对象
+	EvictLRU Eviction = iota
+	// EvictLFU 淘汰访问次数最少的对象, 次数相同时淘汰最久未访问的对象
+	EvictLFU
+)
+
+func (e Eviction) String() string {
+	switch e {
+	case EvictLRU:
+		return "LRU"
+	case EvictLFU:
+		return "LFU"
+	default:
+		return fmt.Sprintf("Eviction(%d)", int(e))
+	}
+}
+
+// EvictReason 对象被淘汰的原因.
+type EvictReason int
+
+const (
+	// EvictReasonExpired 对象已过期
+	EvictReasonExpired EvictReason = iota
+	// EvictReasonCapacity 超出容量
+	EvictReasonCapacity
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictReasonExpired:
+		return "expired"
+	case EvictReasonCapacity:
+		return "capacity"
+	default:
+		return fmt.Sprintf("EvictReason(%d)", int(r))
+	}
+}
+
+// EvictFunc 对象被淘汰后的回调. 在锁外调用, 可以在回调中访问缓存.
+type EvictFunc func(key string, obj interface{}, reason EvictReason)
+
+// ExpirationPolicy 过期及容量淘汰策略.
+type ExpirationPolicy struct {
+	// 默认有效期, 0表示不过期. Add/Update/Inject/Replace时重新计算有效期
+	TTL time.Duration
+	// 最大对象数, 0表示不限制
+	MaxEntries int
+	// 超出容量时的淘汰算法
+	Eviction Eviction
+	// 对象因过期或超出容量被淘汰后的回调. Delete/Replace移除的对象不回调
+	OnEvict EvictFunc
+}
+
+// ExpiringThreadSafeStore 支持过期及容量淘汰的ThreadSafeStore.
+// 过期对象在下一次访问缓存时移除, 也可以调用Expire主动移除.
+type ExpiringThreadSafeStore interface {
+	ThreadSafeStore
+	// AddWithTTL 添加对象并指定有效期, ttl<=0表示不过期
+	AddWithTTL(key string, obj interface{}, ttl time.Duration)
+	// Expire 移除所有已过期的对象, 返回移除的个数
+	Expire() int
+}
+
+// ExpiringStore 支持过期及容量淘汰的Store.
+type ExpiringStore interface {
+	Store
+	// AddWithTTL 添加对象并指定有效期, ttl<=0表示不过期
+	AddWithTTL(obj interface{}, ttl time.Duration) error
+	// Expire 移除所有已过期的对象, 返回移除的个数
+	Expire() int
+}
+
+// ExpiringIndexer 支持过期及容量淘汰的Indexer, 淘汰对象时同步更新索引.
+type ExpiringIndexer interface {
+	Indexer
+	ExpiringStore
+}
+
+// expiringEntry 对象的过期及访问信息.
+type expiringEntry struct {
+	key      string
+	expireAt time.Time
+	// 在过期堆中的位置, -1表示不过期
+	expireIndex int
+
+	// LRU链表中的元素
+	elem *list.Element
+	// LFU访问次数及最后访问序号
+	freq      uint64
+	access    uint64
+	freqIndex int
+}
+
+// 按过期时间排序的最小堆.
+type expireHeap []*expiringEntry
+
+func (h expireHeap) Len() int           { return len(h) }
+func (h expireHeap) Less(i, j int) bool { return h[i].expireAt.Before(h[j].expireAt) }
+func (h expireHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].expireIndex = i
+	h[j].expireIndex = j
+}
+
+func (h *expireHeap) Push(x interface{}) {
+	e := x.(*expiringEntry)
+	e.expireIndex = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *expireHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	e.expireIndex = -1
+	*h = old[:n-1]
+	return e
+}
+
+// 按访问次数及最后访问序号排序的最小堆.
+type freqHeap []*expiringEntry
+
+func (h freqHeap) Len() int { return len(h) }
+func (h freqHeap) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].access < h[j].access
+}
+
+func (h freqHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].freqIndex = i
+	h[j].freqIndex = j
+}
+
+func (h *freqHeap) Push(x interface{}) {
+	e := x.(*expiringEntry)
+	e.freqIndex = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *freqHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return e
+}
+
+type evicted struct {
+	key    string
+	obj    interface{}
+	reason EvictReason
+}
+
+// expiringThreadSafeMap 在threadSafeMap基础上记录每个对象的过期时间及访问信息.
+// 由于Get需要更新访问信息, 所有操作均持有写锁.
+type expiringThreadSafeMap struct {
+	*threadSafeMap
+
+	policy  ExpirationPolicy
+	clock   clock.PassiveClock
+	entries map[string]*expiringEntry
+	expires expireHeap
+	lru     *list.List
+	lfu     freqHeap
+	access  uint64
+}
+
+var _ ExpiringThreadSafeStore = &expiringThreadSafeMap{}
+
+// NewExpiringThreadSafeStore 创建支持过期及容量淘汰的ThreadSafeStore. policy为nil时不过期也不限制容量.
+func NewExpiringThreadSafeStore(indexers Indexers, indices Indices, policy *ExpirationPolicy) ExpiringThreadSafeStore {
+	return NewExpiringThreadSafeStoreWithClock(indexers, indices, policy, clock.RealClock{})
+}
+
+func NewExpiringThreadSafeStoreWithClock(indexers Indexers, indices Indices, policy *ExpirationPolicy,
+	c clock.PassiveClock,
+) ExpiringThreadSafeStore {
+	return newExpiringThreadSafeMap(indexers, indices, policy, c)
+}
+
+func newExpiringThreadSafeMap(indexers Indexers, indices Indices, policy *ExpirationPolicy,
+	c clock.PassiveClock,
+) *expiringThreadSafeMap {
+	m := &expiringThreadSafeMap{
+		threadSafeMap: &threadSafeMap{
+			items:    map[string]interface{}{},
+			indexers: indexers,
+			indices:  indices,
+		},
+		clock:   c,
+		entries: map[string]*expiringEntry{},
+		lru:     list.New(),
+	}
+	if policy != nil {
+		m.policy = *policy
+	}
+	return m
+}
+
+func (c *expiringThreadSafeMap) Add(key string, obj interface{}) {
+	c.AddWithTTL(key, obj, c.policy.TTL)
+}
+
+func (c *expiringThreadSafeMap) AddWithTTL(key string, obj interface{}, ttl time.Duration) {
+	c.lock.Lock()
+	evicts := c.expire()
+	oldObject := c.items[key]
+	c.items[key] = obj
+	c.updateIndices(oldObject, obj, key)
+	c.touch(key, ttl)
+	evicts = append(evicts, c.shrink(key)...)
+	c.lock.Unlock()
+
+	c.notify(evicts)
+}
+
+func (c *expiringThreadSafeMap) Inject(key string, obj interface{}) error {
+	c.lock.Lock()
+	evicts := c.expire()
+	if c.items[key] != nil {
+		c.lock.Unlock()
+		c.notify(evicts)
+		return fmt.Errorf("object %v exist", key)
+	}
+	c.items[key] = obj
+	c.updateIndices(nil, obj, key)
+	c.touch(key, c.policy.TTL)
+	evicts = append(evicts, c.shrink(key)...)
+	c.lock.Unlock()
+
+	c.notify(evicts)
+	return nil
+}
+
+func (c *expiringThreadSafeMap) Update(key string, obj interface{}) error {
+	c.lock.Lock()
+	evicts := c.expire()
+	oldObject := c.items[key]
+	if oldObject == nil {
+		c.lock.Unlock()
+		c.notify(evicts)
+		return fmt.Errorf("object %v not exist", key)
+	}
+	c.items[key] = obj
+	c.updateIndices(oldObject, obj, key)
+	c.touch(key, c.policy.TTL)
+	c.lock.Unlock()
+
+	c.notify(evicts)
+	return nil
+}
+
+func (c *expiringThreadSafeMap) Delete(key string) {
+	c.lock.Lock()
+	evicts := c.expire()
+	c.remove(key)
+	c.lock.Unlock()
+
+	c.notify(evicts)
+}
+
+// Get 返回指定的对象, 并更新对象的访问信息.
+func (c *expiringThreadSafeMap) Get(key string) (item interface{}, exists bool) {
+	c.lock.Lock()
+	evicts := c.expire()
+	item, exists = c.items[key]
+	if exists {
+		c.access++
+		e := c.entries[key]
+		e.access = c.access
+		e.freq++
+		c.lru.MoveToFront(e.elem)
+		if c.policy.Eviction == EvictLFU {
+			heap.Fix(&c.lfu, e.freqIndex)
+		}
+	}
+	c.lock.Unlock()
+
+	c.notify(evicts)
+	return item, exists
+}
+
+// peek 返回未过期的对象, 不更新访问信息.
+func (c *expiringThreadSafeMap) peek(key string) (interface{}, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	item, exists := c.items[key]
+	if !exists {
+		return nil, false
+	}
+	if e := c.entries[key]; e.expireIndex >= 0 && !c.clock.Now().Before(e.expireAt) {
+		return nil, false
+	}
+	return item, true
+}
+
+func (c *expiringThreadSafeMap) List() []interface{} {
+	c.lock.Lock()
+	evicts := c.expire()
+	list := c.list()
+	c.lock.Unlock()
+
+	c.notify(evicts)
+	return list
+}
+
+func (c *expiringThreadSafeMap) ListWithResourceVersion() ([]interface{}, string) {
+	c.lock.Lock()
+	evicts := c.expire()
+	list, rv := c.list(), c.resourceVersion
+	c.lock.Unlock()
+
+	c.notify(evicts)
+	return list, rv
+}
+
+func (c *expiringThreadSafeMap) ListKeys() []string {
+	c.lock.Lock()
+	evicts := c.expire()
+	keys := c.listKeys()
+	c.lock.Unlock()
+
+	c.notify(evicts)
+	return keys
+}
+
+// Replace 替换所有对象, 超出容量的对象被淘汰.
+func (c *expiringThreadSafeMap) Replace(items map[string]interface{}, resourceVersion string) {
+	c.lock.Lock()
+	c.replace(items, resourceVersion)
+	c.entries = map[string]*expiringEntry{}
+	c.expires = nil
+	c.lru.Init()
+	c.lfu = nil
+	for key := range c.items {
+		c.touch(key, c.policy.TTL)
+	}
+	evicts := c.shrink("")
+	c.lock.Unlock()
+
+	c.notify(evicts)
+}
+
+func (c *expiringThreadSafeMap) Index(indexName string, obj interface{}) ([]interface{}, error) {
+	c.lock.Lock()
+	evicts := c.expire()
+	list, err := c.index(indexName, obj)
+	c.lock.Unlock()
+
+	c.notify(evicts)
+	return list, err
+}
+
+func (c *expiringThreadSafeMap) IndexKeys(indexName, indexedValue string) ([]string, error) {
+	c.lock.Lock()
+	evicts := c.expire()
+	keys, err := c.indexKeys(indexName, indexedValue)
+	c.lock.Unlock()
+
+	c.notify(evicts)
+	return keys, err
+}
+
+func (c *expiringThreadSafeMap) ListIndexFuncValues(indexName string) []string {
+	c.lock.Lock()
+	evicts := c.expire()
+	values := c.listIndexFuncValues(indexName)
+	c.lock.Unlock()
+
+	c.notify(evicts)
+	return values
+}
+
+func (c *expiringThreadSafeMap) ByIndex(indexName, indexedValue string) ([]interface{}, error) {
+	c.lock.Lock()
+	evicts := c.expire()
+	list, err := c.byIndex(indexName, indexedValue)
+	c.lock.Unlock()
+
+	c.notify(evicts)
+	return list, err
+}
+
+func (c *expiringThreadSafeMap) Expire() int {
+	c.lock.Lock()
+	evicts := c.expire()
+	c.lock.Unlock()
+
+	c.notify(evicts)
+	return len(evicts)
+}
+
+// touch 记录对象的有效期及访问信息. 调用方需持有锁.
+func (c *expiringThreadSafeMap) touch(key string, ttl time.Duration) {
+	c.access++
+	e, ok := c.entries[key]
+	if !ok {
+		e = &expiringEntry{key: key, expireIndex: -1}
+		e.elem = c.lru.PushFront(e)
+		c.entries[key] = e
+		if c.policy.Eviction == EvictLFU {
+			heap.Push(&c.lfu, e)
+		}
+	} else {
+		c.lru.MoveToFront(e.elem)
+	}
+	e.freq++
+	e.access = c.access
+	if c.policy.Eviction == EvictLFU {
+		heap.Fix(&c.lfu, e.freqIndex)
+	}
+
+	if ttl <= 0 {
+		if e.expireIndex >= 0 {
+			heap.Remove(&c.expires, e.expireIndex)
+		}
+		e.expireAt = time.Time{}
+		return
+	}
+	e.expireAt = c.clock.Now().Add(ttl)
+	if e.expireIndex >= 0 {
+		heap.Fix(&c.expires, e.expireIndex)
+	} else {
+		heap.Push(&c.expires, e)
+	}
+}
+
+// remove 移除对象及其索引, 返回被移除的对象. 调用方需持有锁.
+func (c *expiringThreadSafeMap) remove(key string) (interface{}, bool) {
+	obj, exists := c.items[key]
+	if !exists {
+		return nil, false
+	}
+	c.delete(key)
+
+	e := c.entries[key]
+	delete(c.entries, key)
+	c.lru.Remove(e.elem)
+	if e.expireIndex >= 0 {
+		heap.Remove(&c.expires, e.expireIndex)
+	}
+	if c.policy.Eviction == EvictLFU {
+		heap.Remove(&c.lfu, e.freqIndex)
+	}
+	return obj, true
+}
+
+// expire 移除已过期的对象. 调用方需持有锁.
+func (c *expiringThreadSafeMap) expire() []evicted {
+	if len(c.expires) == 0 {
+		return nil
+	}
+
+	now := c.clock.Now()
+	var evicts []evicted
+	for len(c.expires) > 0 && !now.Before(c.expires[0].expireAt) {
+		key := c.expires[0].key
+		obj, _ := c.remove(key)
+		evicts = append(evicts, evicted{key: key, obj: obj, reason: EvictReasonExpired})
+	}
+	return evicts
+}
+
+// shrink 超出容量时按淘汰算法移除对象, 不淘汰刚写入的keep. 调用方需持有锁.
+func (c *expiringThreadSafeMap) shrink(keep string) []evicted {
+	if c.policy.MaxEntries <= 0 {
+		return nil
+	}
+
+	var evicts []evicted
+	for len(c.items) > c.policy.MaxEntries {
+		key := c.victim(keep)
+		if key == "" {
+			break
+		}
+		obj, _ := c.remove(key)
+		evicts = append(evicts, evicted{key: key, obj: obj, reason: EvictReasonCapacity})
+	}
+	return evicts
+}
+
+// victim 返回淘汰的对象. 调用方需持有锁.
+func (c *expiringThreadSafeMap) victim(keep string) string {
+	if c.policy.Eviction == EvictLFU {
+		if len(c.lfu) == 0 {
+			return ""
+		}
+		if c.lfu[0].key != keep {
+			return c.lfu[0].key
+		}
+		// 刚写入的对象访问次数可能最少, 从堆顶的两个子节点中选择次小的
+		var next *expiringEntry
+		for _, i := range []int{1, 2} {
+			if i < len(c.lfu) && (next == nil || c.lfu.Less(i, next.freqIndex)) {
+				next = c.lfu[i]
+			}
+		}
+		if next == nil {
+			return ""
+		}
+		return next.key
+	}
+
+	for elem := c.lru.Back(); elem != nil; elem = elem.Prev() {
+		if e := elem.Value.(*expiringEntry); e.key != keep {
+			return e.key
+		}
+	}
+	return ""
+}
+
+func (c *expiringThreadSafeMap) notify(evicts []evicted) {
+	if c.policy.OnEvict == nil {
+		return
+	}
+	for _, e := range evicts {
+		c.policy.OnEvict(e.key, e.obj, e.reason)
+	}
+}
+
+// expiringCache 基于expiringThreadSafeMap实现ExpiringIndexer.
+type expiringCache struct {
+	cache
+	storage *expiringThreadSafeMap
+}
+
+var _ ExpiringIndexer = &expiringCache{}
+
+func (c *expiringCache) AddWithTTL(obj interface{}, ttl time.Duration) error {
+	key, err := c.keyFunc(obj)
+	if err != nil {
+		return KeyError{obj, err}
+	}
+	c.storage.AddWithTTL(key, obj, ttl)
+	return nil
+}
+
+func (c *expiringCache) Expire() int {
+	return c.storage.Expire()
+}
+
+func (c *expiringCache) peek(key string) (interface{}, bool) {
+	return c.storage.peek(key)
+}
+
+// NewExpiringStore 创建支持过期及容量淘汰的Store. policy为nil时不过期也不限制容量.
+func NewExpiringStore(keyFunc KeyFunc, policy *ExpirationPolicy) ExpiringStore {
+	return NewExpiringIndexer(keyFunc, Indexers{}, policy)
+}
+
+// NewExpiringIndexer 创建支持过期及容量淘汰的Indexer, 对象被淘汰时同步更新索引.
+func NewExpiringIndexer(keyFunc KeyFunc, indexers Indexers, policy *ExpirationPolicy) ExpiringIndexer {
+	return NewExpiringIndexerWithClock(keyFunc, indexers, policy, clock.RealClock{})
+}
+
+func NewExpiringIndexerWithClock(keyFunc KeyFunc, indexers Indexers, policy *ExpirationPolicy,
+	c clock.PassiveClock,
+) ExpiringIndexer {
+	storage := newExpiringThreadSafeMap(indexers, Indices{}, policy, c)
+	return &expiringCache{
+		cache: cache{
+			cacheStorage: storage,
+			keyFunc:      keyFunc,
+		},
+		storage: storage,
+	}
+}
@@ -0,0 +1,129 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/wangweihong/gotoolbox/pkg/cache"
+	"github.com/wangweihong/gotoolbox/pkg/clock"
+)
+
+type account struct {
+	Name  string
+	Group string
+}
+
+func accountKey(obj interface{}) (string, error) {
+	return obj.(*account).Name, nil
+}
+
+func groupIndex(obj interface{}) ([]string, error) {
+	return []string{obj.(*account).Group}, nil
+}
+
+type evictRecord struct {
+	key    string
+	reason cache.EvictReason
+}
+
+func newAccountIndexer(policy *cache.ExpirationPolicy, c clock.PassiveClock) (cache.ExpiringIndexer, *[]evictRecord) {
+	var evicts []evictRecord
+	policy.OnEvict = func(key string, obj interface{}, reason cache.EvictReason) {
+		evicts = append(evicts, evictRecord{key: key, reason: reason})
+	}
+	return cache.NewExpiringIndexerWithClock(accountKey, cache.Indexers{"group": groupIndex}, policy, c), &evicts
+}
+
+func TestExpiringIndexer(t *testing.T) {
+	Convey("过期", t, func() {
+		fakeClock := clock.NewFakePassiveClock(time.Now())
+		indexer, evicts := newAccountIndexer(&cache.ExpirationPolicy{TTL: time.Minute}, fakeClock)
+
+		So(indexer.Add(&account{Name: "u1", Group: "g1"}), ShouldBeNil)
+		So(indexer.AddWithTTL(&account{Name: "u2", Group: "g1"}, 3*time.Minute), ShouldBeNil)
+		So(indexer.AddWithTTL(&account{Name: "u3", Group: "g2"}, 0), ShouldBeNil)
+
+		fakeClock.SetTime(fakeClock.Now().Add(2 * time.Minute))
+		_, exists, _ := indexer.GetByKey("u1")
+		So(exists, ShouldBeFalse)
+		So(*evicts, ShouldResemble, []evictRecord{{key: "u1", reason: cache.EvictReasonExpired}})
+
+		keys, err := indexer.IndexKeys("group", "g1")
+		So(err, ShouldBeNil)
+		So(keys, ShouldResemble, []string{"u2"})
+
+		// 更新后重新计算有效期
+		So(indexer.Update(&account{Name: "u2", Group: "g2"}), ShouldBeNil)
+		fakeClock.SetTime(fakeClock.Now().Add(50 * time.Second))
+		So(indexer.Expire(), ShouldEqual, 0)
+		fakeClock.SetTime(fakeClock.Now().Add(10 * time.Second))
+		So(indexer.Expire(), ShouldEqual, 1)
+
+		So(indexer.ListKeys(), ShouldResemble, []string{"u3"})
+		So(indexer.ListIndexFuncValues("group"), ShouldResemble, []string{"g2"})
+	})
+
+	Convey("LRU淘汰", t, func() {
+		indexer, evicts := newAccountIndexer(&cache.ExpirationPolicy{MaxEntries: 2}, clock.RealClock{})
+
+		So(indexer.Add(&account{Name: "u1", Group: "g1"}), ShouldBeNil)
+		So(indexer.Add(&account{Name: "u2", Group: "g1"}), ShouldBeNil)
+		_, _, _ = indexer.GetByKey("u1")
+		So(indexer.Add(&account{Name: "u3", Group: "g2"}), ShouldBeNil)
+
+		So(*evicts, ShouldResemble, []evictRecord{{key: "u2", reason: cache.EvictReasonCapacity}})
+		list, err := indexer.ByIndex("group", "g1")
+		So(err, ShouldBeNil)
+		So(list, ShouldHaveLength, 1)
+		So(list[0].(*account).Name, ShouldEqual, "u1")
+
+		// 超出容量的Replace只保留容量内的对象
+		So(indexer.Replace([]interface{}{
+			&account{Name: "u4", Group: "g1"},
+			&account{Name: "u5", Group: "g1"},
+			&account{Name: "u6", Group: "g1"},
+		}, ""), ShouldBeNil)
+		So(indexer.ListKeys(), ShouldHaveLength, 2)
+		keys, _ := indexer.IndexKeys("group", "g1")
+		So(keys, ShouldHaveLength, 2)
+	})
+
+	Convey("LFU淘汰", t, func() {
+		indexer, evicts := newAccountIndexer(&cache.ExpirationPolicy{MaxEntries: 2, Eviction: cache.EvictLFU},
+			clock.RealClock{})
+
+		So(indexer.Add(&account{Name: "u1", Group: "g1"}), ShouldBeNil)
+		So(indexer.Add(&account{Name: "u2", Group: "g1"}), ShouldBeNil)
+		for i := 0; i < 3; i++ {
+			_, _, _ = indexer.GetByKey("u1")
+		}
+		_, _, _ = indexer.GetByKey("u2")
+		So(indexer.Add(&account{Name: "u3", Group: "g2"}), ShouldBeNil)
+		So(*evicts, ShouldResemble, []evictRecord{{key: "u2", reason: cache.EvictReasonCapacity}})
+
+		// 新写入的对象访问次数最少, 但不会被立即淘汰
+		So(indexer.Add(&account{Name: "u4", Group: "g2"}), ShouldBeNil)
+		So((*evicts)[1], ShouldResemble, evictRecord{key: "u3", reason: cache.EvictReasonCapacity})
+		_, exists, _ := indexer.GetByKey("u4")
+		So(exists, ShouldBeTrue)
+
+		keys, _ := indexer.IndexKeys("group", "g2")
+		So(keys, ShouldResemble, []string{"u4"})
+	})
+
+	Convey("淘汰回调中访问缓存", t, func() {
+		var indexer cache.ExpiringIndexer
+		var remain []string
+		indexer = cache.NewExpiringIndexer(accountKey, cache.Indexers{}, &cache.ExpirationPolicy{
+			MaxEntries: 1,
+			OnEvict: func(key string, obj interface{}, reason cache.EvictReason) {
+				remain = indexer.ListKeys()
+			},
+		})
+		So(indexer.Add(&account{Name: "u1"}), ShouldBeNil)
+		So(indexer.Add(&account{Name: "u2"}), ShouldBeNil)
+		So(remain, ShouldResemble, []string{"u2"})
+	})
+}
@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/wangweihong/gotoolbox/pkg/json"
+)
+
+// snapshotFormatVersion 快照格式版本, 格式不兼容时递增.
+const snapshotFormatVersion = 1
+
+// Snapshot 缓存快照.
+type Snapshot[T any] struct {
+	FormatVersion   int       `json:"formatVersion"`
+	ResourceVersion string    `json:"resourceVersion"`
+	CreatedAt       time.Time `json:"createdAt"`
+	Items           []T       `json:"items"`
+}
+
+// SnapshotCodec 快照编解码器. v为*Snapshot[T].
+type SnapshotCodec interface {
+	Encode(w io.Writer, v any) error
+	Decode(r io.Reader, v any) error
+}
+
+var (
+	// JSONSnapshotCodec 以json格式保存快照, 便于排查.
+	JSONSnapshotCodec SnapshotCodec = jsonSnapshotCodec{}
+	// GobSnapshotCodec 以gob格式保存快照, 体积更小, 编解码更快. 对象中的接口类型需要gob.Register.
+	GobSnapshotCodec SnapshotCodec = gobSnapshotCodec{}
+)
+
+type jsonSnapshotCodec struct{}
+
+func (jsonSnapshotCodec) Encode(w io.Writer, v any) error { return json.NewEncoder(w).Encode(v) }
+func (jsonSnapshotCodec) Decode(r io.Reader, v any) error { return json.NewDecoder(r).Decode(v) }
+
+type gobSnapshotCodec struct{}
+
+func (gobSnapshotCodec) Encode(w io.Writer, v any) error { return gob.NewEncoder(w).Encode(v) }
+func (gobSnapshotCodec) Decode(r io.Reader, v any) error { return gob.NewDecoder(r).Decode(v) }
+
+// SaveSnapshot 将缓存中的所有对象及resourceVersion保存到文件. 先写入临时文件再重命名, 保存失败不会破坏已有快照.
+// 缓存中的对象类型必须为T. store实现VersionedLister时(如NewStore/NewIndexer创建的缓存), 对象与resourceVersion在同一把锁内读取.
+func SaveSnapshot[T any](store Store, path string, codec SnapshotCodec) (err error) {
+	if codec == nil {
+		return fmt.Errorf("snapshot codec must not be nil")
+	}
+	list, resourceVersion := listWithResourceVersion(store)
+	snapshot := &Snapshot[T]{
+		FormatVersion:   snapshotFormatVersion,
+		ResourceVersion: resourceVersion,
+		CreatedAt:       time.Now(),
+	}
+	for _, obj := range list {
+		item, ok := obj.(T)
+		if !ok {
+			return fmt.Errorf("unexpected object type %T in snapshot of %v", obj, typeOf[T]())
+		}
+		snapshot.Items = append(snapshot.Items, item)
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	w := bufio.NewWriter(tmp)
+	if err = codec.Encode(w, snapshot); err != nil {
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	err = os.Rename(tmp.Name(), path)
+	return err
+}
+
+// LoadSnapshot 读取快照文件, 通过Replace恢复缓存中的对象及resourceVersion, 索引随之重建.
+// 返回快照的resourceVersion. 快照不存在时返回的错误满足errors.Is(err, fs.ErrNotExist).
+func LoadSnapshot[T any](store Store, path string, codec SnapshotCodec) (string, error) {
+	if codec == nil {
+		return "", fmt.Errorf("snapshot codec must not be nil")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	snapshot := &Snapshot[T]{}
+	if err := codec.Decode(bufio.NewReader(f), snapshot); err != nil {
+		return "", fmt.Errorf("decode snapshot %s: %w", path, err)
+	}
+	if snapshot.FormatVersion != snapshotFormatVersion {
+		return "", fmt.Errorf("unsupported snapshot format version %d", snapshot.FormatVersion)
+	}
+
+	list := make([]interface{}, 0, len(snapshot.Items))
+	for _, item := range snapshot.Items {
+		list = append(list, item)
+	}
+	if err := store.Replace(list, snapshot.ResourceVersion); err != nil {
+		return "", err
+	}
+	return snapshot.ResourceVersion, nil
+}
+
+// Snapshotter 定期将缓存保存到快照文件, 启动时从快照恢复.
+type Snapshotter[T any] struct {
+	store   Store
+	path    string
+	codec   SnapshotCodec
+	onError func(error)
+}
+
+// SnapshotterOption 快照器选项.
+type SnapshotterOption func(*snapshotterOptions)
+
+type snapshotterOptions struct {
+	onError func(error)
+}
+
+// WithSnapshotOnError 定期保存快照失败时调用fn, 如记录日志. 默认忽略.
+func WithSnapshotOnError(fn func(error)) SnapshotterOption {
+	return func(o *snapshotterOptions) {
+		o.onError = fn
+	}
+}
+
+// NewSnapshotter 创建快照器. codec为nil时使用JSONSnapshotCodec.
+func NewSnapshotter[T any](store Store, path string, codec SnapshotCodec, opts ...SnapshotterOption) *Snapshotter[T] {
+	if codec == nil {
+		codec = JSONSnapshotCodec
+	}
+	o := &snapshotterOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &Snapshotter[T]{store: store, path: path, codec: codec, onError: o.onError}
+}
+
+// Save 保存快照.
+func (s *Snapshotter[T]) Save() error {
+	return SaveSnapshot[T](s.store, s.path, s.codec)
+}
+
+// Restore 从快照恢复缓存, 返回快照的resourceVersion.
+func (s *Snapshotter[T]) Restore() (string, error) {
+	return LoadSnapshot[T](s.store, s.path, s.codec)
+}
+
+// Run 每隔interval保存一次快照, 保存失败时调用WithSnapshotOnError指定的回调.
+// ctx结束时再保存一次后返回, 返回最后一次保存的错误. interval必须大于0.
+func (s *Snapshotter[T]) Run(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("snapshot interval must be positive, got %v", interval)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return s.Save()
+		case <-ticker.C:
+			if err := s.Save(); err != nil && s.onError != nil {
+				s.onError(fmt.Errorf("save cache snapshot %s: %w", s.path, err))
+			}
+		}
+	}
+}
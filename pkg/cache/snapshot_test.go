@@ -0,0 +1,163 @@
+package cache_test
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/wangweihong/gotoolbox/pkg/cache"
+)
+
+func TestSnapshot(t *testing.T) {
+	for name, codec := range map[string]cache.SnapshotCodec{
+		"json": cache.JSONSnapshotCodec,
+		"gob":  cache.GobSnapshotCodec,
+	} {
+		Convey("快照保存及恢复:"+name, t, func() {
+			path := filepath.Join(t.TempDir(), "inventory", "accounts.snapshot")
+
+			indexer := cache.NewIndexer(accountKey, cache.Indexers{"group": groupIndex})
+			So(indexer.Replace([]interface{}{
+				&account{Name: "u1", Group: "g1"},
+				&account{Name: "u2", Group: "g1"},
+			}, "rv-10"), ShouldBeNil)
+			So(indexer.Add(&account{Name: "u3", Group: "g2"}), ShouldBeNil)
+			So(cache.SaveSnapshot[*account](indexer, path, codec), ShouldBeNil)
+
+			restored := cache.NewIndexer(accountKey, cache.Indexers{"group": groupIndex})
+			rv, err := cache.LoadSnapshot[*account](restored, path, codec)
+			So(err, ShouldBeNil)
+			So(rv, ShouldEqual, "rv-10")
+			So(restored.(cache.ResourceVersioner).LastSyncResourceVersion(), ShouldEqual, "rv-10")
+			So(restored.ListKeys(), ShouldHaveLength, 3)
+
+			keys, err := restored.IndexKeys("group", "g1")
+			So(err, ShouldBeNil)
+			So(keys, ShouldHaveLength, 2)
+			obj, exists, _ := restored.GetByKey("u3")
+			So(exists, ShouldBeTrue)
+			So(obj.(*account).Group, ShouldEqual, "g2")
+
+			files, _ := os.ReadDir(filepath.Dir(path))
+			So(files, ShouldHaveLength, 1)
+		})
+	}
+
+	Convey("快照不存在或对象类型不匹配", t, func() {
+		dir := t.TempDir()
+		store := cache.NewStore(accountKey)
+		_, err := cache.LoadSnapshot[*account](store, filepath.Join(dir, "notexist"), cache.JSONSnapshotCodec)
+		So(err, ShouldWrap, fs.ErrNotExist)
+
+		So(store.Add(&account{Name: "u1"}), ShouldBeNil)
+		So(cache.SaveSnapshot[account](store, filepath.Join(dir, "s"), cache.JSONSnapshotCodec), ShouldNotBeNil)
+		files, _ := os.ReadDir(dir)
+		So(files, ShouldBeEmpty)
+	})
+
+	Convey("定期保存快照", t, func() {
+		path := filepath.Join(t.TempDir(), "accounts.snapshot")
+		store := cache.NewStore(accountKey)
+		So(store.Add(&account{Name: "u1"}), ShouldBeNil)
+
+		s := cache.NewSnapshotter[*account](store, path, nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() {
+			done <- s.Run(ctx, 10*time.Millisecond)
+		}()
+
+		So(func() bool {
+			deadline := time.Now().Add(time.Second)
+			for time.Now().Before(deadline) {
+				if _, err := os.Stat(path); err == nil {
+					return true
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+			return false
+		}(), ShouldBeTrue)
+
+		So(store.Add(&account{Name: "u2"}), ShouldBeNil)
+		cancel()
+		So(<-done, ShouldBeNil)
+
+		restored := cache.NewStore(accountKey)
+		_, err := cache.NewSnapshotter[*account](restored, path, nil).Restore()
+		So(err, ShouldBeNil)
+		So(restored.ListKeys(), ShouldHaveLength, 2)
+	})
+
+	Convey("定期保存失败时回调", t, func() {
+		// 父目录是文件, 保存总是失败
+		parent := filepath.Join(t.TempDir(), "file")
+		So(os.WriteFile(parent, nil, 0o644), ShouldBeNil)
+		store := cache.NewStore(accountKey)
+
+		errs := make(chan error, 100)
+		s := cache.NewSnapshotter[*account](store, filepath.Join(parent, "accounts.snapshot"), nil,
+			cache.WithSnapshotOnError(func(err error) {
+				errs <- err
+			}))
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() {
+			done <- s.Run(ctx, 10*time.Millisecond)
+		}()
+
+		select {
+		case err := <-errs:
+			So(err, ShouldNotBeNil)
+		case <-time.After(time.Second):
+			So("on error not called", ShouldBeEmpty)
+		}
+		cancel()
+		So(<-done, ShouldNotBeNil)
+	})
+
+	Convey("参数校验", t, func() {
+		path := filepath.Join(t.TempDir(), "accounts.snapshot")
+		store := cache.NewStore(accountKey)
+		So(cache.SaveSnapshot[*account](store, path, nil), ShouldNotBeNil)
+		_, err := cache.LoadSnapshot[*account](store, path, nil)
+		So(err, ShouldNotBeNil)
+
+		s := cache.NewSnapshotter[*account](store, path, nil)
+		So(s.Run(context.Background(), 0), ShouldNotBeNil)
+		So(s.Run(context.Background(), -time.Second), ShouldNotBeNil)
+	})
+
+	Convey("对象与resourceVersion一致", t, func() {
+		path := filepath.Join(t.TempDir(), "accounts.snapshot")
+		store := cache.NewStore(accountKey)
+		So(store.Replace([]interface{}{&account{Name: "v0"}}, "0"), ShouldBeNil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 1; ctx.Err() == nil; i++ {
+				v := strconv.Itoa(i)
+				_ = store.Replace([]interface{}{&account{Name: "v" + v}}, v)
+			}
+		}()
+		defer func() {
+			cancel()
+			<-done
+		}()
+
+		for i := 0; i < 200; i++ {
+			So(cache.SaveSnapshot[*account](store, path, cache.JSONSnapshotCodec), ShouldBeNil)
+			restored := cache.NewStore(accountKey)
+			rv, err := cache.LoadSnapshot[*account](restored, path, cache.JSONSnapshotCodec)
+			So(err, ShouldBeNil)
+			So(restored.ListKeys(), ShouldResemble, []string{"v" + rv})
+		}
+	})
+}
@@ -37,6 +37,28 @@ type Store interface {
 	Resync() error
 }
 
+// ResourceVersioner 返回最后一次Replace的resourceVersion, 用于快照等场景.
+type ResourceVersioner interface {
+	LastSyncResourceVersion() string
+}
+
+// VersionedLister 在同一把锁内返回所有对象及最后一次Replace的resourceVersion, 二者保持一致.
+type VersionedLister interface {
+	ListWithResourceVersion() ([]interface{}, string)
+}
+
+// listWithResourceVersion 返回所有对象及resourceVersion, store实现VersionedLister时保证二者一致.
+func listWithResourceVersion(store Store) ([]interface{}, string) {
+	if v, ok := store.(VersionedLister); ok {
+		return v.ListWithResourceVersion()
+	}
+	rv := ""
+	if v, ok := store.(ResourceVersioner); ok {
+		rv = v.LastSyncResourceVersion()
+	}
+	return store.List(), rv
+}
+
 // KeyFunc knows how to make a key from an object. Implementations should be deterministic.
 // 用于从对象中计算出指定的key.如MetaNamespaceKeyFunc，通过对象命名空间和对象计算出key.
 type KeyFunc func(obj any) (string, error)
@@ -174,6 +196,22 @@ func (c *cache) Replace(list []any, resourceVersion string) error {
 	return nil
 }
 
+// LastSyncResourceVersion 返回最后一次Replace的resourceVersion.
+func (c *cache) LastSyncResourceVersion() string {
+	if v, ok := c.cacheStorage.(ResourceVersioner); ok {
+		return v.LastSyncResourceVersion()
+	}
+	return ""
+}
+
+// ListWithResourceVersion 返回所有对象及最后一次Replace的resourceVersion.
+func (c *cache) ListWithResourceVersion() ([]interface{}, string) {
+	if v, ok := c.cacheStorage.(VersionedLister); ok {
+		return v.ListWithResourceVersion()
+	}
+	return c.cacheStorage.List(), c.LastSyncResourceVersion()
+}
+
 // Resync is meaningless for one of these.
 func (c *cache) Resync() error {
 	return nil
@@ -57,6 +57,9 @@ type threadSafeMap struct {
 	indices Indices //  存储每个索引器对应索引和索引值表。 注意最后存储的索引值是索引的对象的键，而不是索引的对象。找到索引值后，再去items中取对象
 	//  本质为map[索引器名]map[索引1]map[值1]
 	//								[值2]
+
+	// 最后一次Replace的resourceVersion
+	resourceVersion string
 }
 
 // 添加指定对象,并基于索引器建立索引.
@@ -99,6 +102,11 @@ func (c *threadSafeMap) Update(key string, obj interface{}) error {
 func (c *threadSafeMap) Delete(key string) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	c.delete(key)
+}
+
+// delete 调用方需持有锁.
+func (c *threadSafeMap) delete(key string) {
 	if obj, exists := c.items[key]; exists {
 		c.deleteFromIndices(obj, key)
 		delete(c.items, key)
@@ -117,6 +125,11 @@ func (c *threadSafeMap) Get(key string) (item interface{}, exists bool) {
 func (c *threadSafeMap) List() []interface{} {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
+	return c.list()
+}
+
+// list 调用方需持有锁.
+func (c *threadSafeMap) list() []interface{} {
 	list := make([]interface{}, 0, len(c.items))
 	for _, item := range c.items {
 		list = append(list, item)
@@ -130,6 +143,11 @@ func (c *threadSafeMap) List() []interface{} {
 func (c *threadSafeMap) ListKeys() []string {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
+	return c.listKeys()
+}
+
+// listKeys 调用方需持有锁.
+func (c *threadSafeMap) listKeys() []string {
 	list := make([]string, 0, len(c.items))
 	for key := range c.items {
 		list = append(list, key)
@@ -141,7 +159,13 @@ func (c *threadSafeMap) ListKeys() []string {
 func (c *threadSafeMap) Replace(items map[string]interface{}, resourceVersion string) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	c.replace(items, resourceVersion)
+}
+
+// replace 调用方需持有锁.
+func (c *threadSafeMap) replace(items map[string]interface{}, resourceVersion string) {
 	c.items = items
+	c.resourceVersion = resourceVersion
 
 	// rebuild any index
 	c.indices = Indices{}
@@ -157,6 +181,11 @@ func (c *threadSafeMap) Index(indexName string, obj interface{}) ([]interface{},
 	c.lock.RLock()
 	defer c.lock.RUnlock()
 
+	return c.index(indexName, obj)
+}
+
+// index 调用方需持有锁.
+func (c *threadSafeMap) index(indexName string, obj interface{}) ([]interface{}, error) {
 	indexFunc := c.indexers[indexName]
 	if indexFunc == nil {
 		return nil, fmt.Errorf("Index with name %s does not exist", indexName)
@@ -197,6 +226,11 @@ func (c *threadSafeMap) ByIndex(indexName, indexedValue string) ([]interface{},
 	c.lock.RLock()
 	defer c.lock.RUnlock()
 
+	return c.byIndex(indexName, indexedValue)
+}
+
+// byIndex 调用方需持有锁.
+func (c *threadSafeMap) byIndex(indexName, indexedValue string) ([]interface{}, error) {
 	// 找到指定的索引器
 	indexFunc := c.indexers[indexName]
 	if indexFunc == nil {
@@ -222,6 +256,11 @@ func (c *threadSafeMap) IndexKeys(indexName, indexedValue string) ([]string, err
 	c.lock.RLock()
 	defer c.lock.RUnlock()
 
+	return c.indexKeys(indexName, indexedValue)
+}
+
+// indexKeys 调用方需持有锁.
+func (c *threadSafeMap) indexKeys(indexName, indexedValue string) ([]string, error) {
 	indexFunc := c.indexers[indexName]
 	if indexFunc == nil {
 		return nil, fmt.Errorf("Index with name %s does not exist", indexName)
@@ -238,6 +277,11 @@ func (c *threadSafeMap) ListIndexFuncValues(indexName string) []string {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
 
+	return c.listIndexFuncValues(indexName)
+}
+
+// listIndexFuncValues 调用方需持有锁.
+func (c *threadSafeMap) listIndexFuncValues(indexName string) []string {
 	index := c.indices[indexName]
 	names := make([]string, 0, len(index))
 	for key := range index {
@@ -341,6 +385,20 @@ func (c *threadSafeMap) deleteFromIndices(obj interface{}, key string) {
 	}
 }
 
+// LastSyncResourceVersion 返回最后一次Replace的resourceVersion.
+func (c *threadSafeMap) LastSyncResourceVersion() string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.resourceVersion
+}
+
+// ListWithResourceVersion 返回所有对象及最后一次Replace的resourceVersion.
+func (c *threadSafeMap) ListWithResourceVersion() ([]interface{}, string) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.list(), c.resourceVersion
+}
+
 func (c *threadSafeMap) Resync() error {
 	// Nothing to do
 	return nil
@@ -0,0 +1,244 @@
+package cache
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// TypedKeyFunc 从T类型对象计算key.
+type TypedKeyFunc[T any] func(obj T) (string, error)
+
+// TypedIndexFunc 从T类型对象计算索引值.
+type TypedIndexFunc[T any] func(obj T) ([]string, error)
+
+// TypedIndexers 索引器名到TypedIndexFunc的映射.
+type TypedIndexers[T any] map[string]TypedIndexFunc[T]
+
+// Keyer 实现了Key方法的对象可以直接作为TypedStore的对象, 无需指定TypedKeyFunc.
+type Keyer interface {
+	Key() string
+}
+
+// TypedStore 类型化的Store, 无需对结果进行类型断言.
+type TypedStore[T any] interface {
+	Add(obj T) error
+	Update(obj T) error
+	Delete(obj T) error
+	// List 返回所有T类型的对象. 底层Indexer中的非T类型对象(如通过Untyped写入)被忽略, 可通过GetByKey获取对应的错误
+	List() []T
+	ListKeys() []string
+	Get(obj T) (item T, exists bool, err error)
+	GetByKey(key string) (item T, exists bool, err error)
+	Replace(list []T, resourceVersion string) error
+	Resync() error
+}
+
+// TypedIndexer 类型化的Indexer.
+type TypedIndexer[T any] interface {
+	TypedStore[T]
+	// Index 返回与obj在指定索引器中的索引值相交的对象. 结果中存在非T类型的对象时返回错误
+	Index(indexName string, obj T) ([]T, error)
+	IndexKeys(indexName, indexedValue string) ([]string, error)
+	ListIndexFuncValues(indexName string) []string
+	// ByIndex 返回索引值为indexedValue的对象. 结果中存在非T类型的对象时返回错误
+	ByIndex(indexName, indexedValue string) ([]T, error)
+	AddIndexers(newIndexers TypedIndexers[T]) error
+	// Untyped 返回底层的Indexer, 用于Watch、快照等基于interface{}的功能
+	Untyped() Indexer
+}
+
+// typedCache 基于Indexer实现TypedIndexer, 写入的对象都为T类型.
+type typedCache[T any] struct {
+	indexer Indexer
+}
+
+var _ TypedIndexer[Keyer] = &typedCache[Keyer]{}
+
+// NewTypedStore 创建类型化的Store. keyFunc为nil时T需要实现Keyer.
+func NewTypedStore[T any](keyFunc TypedKeyFunc[T]) TypedStore[T] {
+	return NewTypedIndexer[T](keyFunc, TypedIndexers[T]{})
+}
+
+// NewTypedIndexer 创建类型化的Indexer. keyFunc为nil时T需要实现Keyer.
+func NewTypedIndexer[T any](keyFunc TypedKeyFunc[T], indexers TypedIndexers[T]) TypedIndexer[T] {
+	if keyFunc == nil {
+		keyFunc = KeyerKeyFunc[T]
+	}
+	return &typedCache[T]{
+		indexer: NewIndexer(keyFunc.Untyped(), indexers.Untyped()),
+	}
+}
+
+// AsTyped 将对象均为T类型的Indexer包装成TypedIndexer, 如NewWatchableIndexer/NewExpiringIndexer创建的Indexer.
+func AsTyped[T any](indexer Indexer) TypedIndexer[T] {
+	return &typedCache[T]{indexer: indexer}
+}
+
+// KeyerKeyFunc 通过Keyer.Key计算key.
+func KeyerKeyFunc[T any](obj T) (string, error) {
+	k, ok := any(obj).(Keyer)
+	if !ok {
+		return "", fmt.Errorf("object %T does not implement cache.Keyer", obj)
+	}
+	return k.Key(), nil
+}
+
+// FieldKeyFunc 以结构体(或结构体指针)的字符串字段作为key.
+func FieldKeyFunc[T any](field string) TypedKeyFunc[T] {
+	return func(obj T) (string, error) {
+		v := reflect.ValueOf(obj)
+		for v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return "", fmt.Errorf("object %T is nil", obj)
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return "", fmt.Errorf("object %T is not a struct", obj)
+		}
+		f := v.FieldByName(field)
+		if !f.IsValid() || f.Kind() != reflect.String {
+			return "", fmt.Errorf("object %T has no string field %s", obj, field)
+		}
+		return f.String(), nil
+	}
+}
+
+// Untyped 转换成KeyFunc, 对象不为T类型时返回错误.
+func (f TypedKeyFunc[T]) Untyped() KeyFunc {
+	return func(obj interface{}) (string, error) {
+		t, ok := obj.(T)
+		if !ok {
+			return "", fmt.Errorf("object is %T, not %v", obj, typeOf[T]())
+		}
+		return f(t)
+	}
+}
+
+// Untyped 转换成IndexFunc, 对象不为T类型时返回错误.
+func (f TypedIndexFunc[T]) Untyped() IndexFunc {
+	return func(obj interface{}) ([]string, error) {
+		t, ok := obj.(T)
+		if !ok {
+			return nil, fmt.Errorf("object is %T, not %v", obj, typeOf[T]())
+		}
+		return f(t)
+	}
+}
+
+// Untyped 转换成Indexers.
+func (i TypedIndexers[T]) Untyped() Indexers {
+	indexers := make(Indexers, len(i))
+	for name, f := range i {
+		indexers[name] = f.Untyped()
+	}
+	return indexers
+}
+
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// toTyped 转换查询结果, 存在非T类型的对象时返回错误.
+func toTyped[T any](objs []interface{}) ([]T, error) {
+	list := make([]T, 0, len(objs))
+	for _, obj := range objs {
+		t, ok := obj.(T)
+		if !ok {
+			return nil, fmt.Errorf("object is %T, not %v", obj, typeOf[T]())
+		}
+		list = append(list, t)
+	}
+	return list, nil
+}
+
+func toTypedItem[T any](obj interface{}, exists bool, err error) (T, bool, error) {
+	var zero T
+	if err != nil || !exists {
+		return zero, exists, err
+	}
+	t, ok := obj.(T)
+	if !ok {
+		return zero, true, fmt.Errorf("object is %T, not %v", obj, typeOf[T]())
+	}
+	return t, true, nil
+}
+
+func (c *typedCache[T]) Add(obj T) error {
+	return c.indexer.Add(obj)
+}
+
+func (c *typedCache[T]) Update(obj T) error {
+	return c.indexer.Update(obj)
+}
+
+func (c *typedCache[T]) Delete(obj T) error {
+	return c.indexer.Delete(obj)
+}
+
+func (c *typedCache[T]) List() []T {
+	objs := c.indexer.List()
+	list := make([]T, 0, len(objs))
+	for _, obj := range objs {
+		if t, ok := obj.(T); ok {
+			list = append(list, t)
+		}
+	}
+	return list
+}
+
+func (c *typedCache[T]) ListKeys() []string {
+	return c.indexer.ListKeys()
+}
+
+func (c *typedCache[T]) Get(obj T) (T, bool, error) {
+	return toTypedItem[T](c.indexer.Get(obj))
+}
+
+func (c *typedCache[T]) GetByKey(key string) (T, bool, error) {
+	return toTypedItem[T](c.indexer.GetByKey(key))
+}
+
+func (c *typedCache[T]) Replace(list []T, resourceVersion string) error {
+	items := make([]interface{}, 0, len(list))
+	for _, item := range list {
+		items = append(items, item)
+	}
+	return c.indexer.Replace(items, resourceVersion)
+}
+
+func (c *typedCache[T]) Resync() error {
+	return c.indexer.Resync()
+}
+
+func (c *typedCache[T]) Index(indexName string, obj T) ([]T, error) {
+	objs, err := c.indexer.Index(indexName, obj)
+	if err != nil {
+		return nil, err
+	}
+	return toTyped[T](objs)
+}
+
+func (c *typedCache[T]) IndexKeys(indexName, indexedValue string) ([]string, error) {
+	return c.indexer.IndexKeys(indexName, indexedValue)
+}
+
+func (c *typedCache[T]) ListIndexFuncValues(indexName string) []string {
+	return c.indexer.ListIndexFuncValues(indexName)
+}
+
+func (c *typedCache[T]) ByIndex(indexName, indexedValue string) ([]T, error) {
+	objs, err := c.indexer.ByIndex(indexName, indexedValue)
+	if err != nil {
+		return nil, err
+	}
+	return toTyped[T](objs)
+}
+
+func (c *typedCache[T]) AddIndexers(newIndexers TypedIndexers[T]) error {
+	return c.indexer.AddIndexers(newIndexers.Untyped())
+}
+
+func (c *typedCache[T]) Untyped() Indexer {
+	return c.indexer
+}
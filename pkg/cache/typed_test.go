@@ -0,0 +1,119 @@
+package cache_test
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/wangweihong/gotoolbox/pkg/cache"
+)
+
+type keyedAccount struct {
+	account
+}
+
+func (a *keyedAccount) Key() string {
+	return a.Name
+}
+
+func typedGroupIndex(a *account) ([]string, error) {
+	return []string{a.Group}, nil
+}
+
+func TestTypedIndexer(t *testing.T) {
+	Convey("类型化Indexer", t, func() {
+		indexer := cache.NewTypedIndexer[*account](cache.FieldKeyFunc[*account]("Name"),
+			cache.TypedIndexers[*account]{"group": typedGroupIndex})
+		So(indexer.Add(&account{Name: "a", Group: "g1"}), ShouldBeNil)
+		So(indexer.Add(&account{Name: "b", Group: "g1"}), ShouldBeNil)
+		So(indexer.Add(&account{Name: "c", Group: "g2"}), ShouldBeNil)
+
+		Convey("查询结果无需类型断言", func() {
+			item, exists, err := indexer.GetByKey("a")
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeTrue)
+			So(item.Group, ShouldEqual, "g1")
+
+			item, exists, err = indexer.GetByKey("notexist")
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeFalse)
+			So(item, ShouldBeNil)
+
+			So(len(indexer.List()), ShouldEqual, 3)
+		})
+
+		Convey("索引查询", func() {
+			items, err := indexer.ByIndex("group", "g1")
+			So(err, ShouldBeNil)
+			names := make([]string, 0, len(items))
+			for _, item := range items {
+				names = append(names, item.Name)
+			}
+			sort.Strings(names)
+			So(names, ShouldResemble, []string{"a", "b"})
+
+			items, err = indexer.Index("group", &account{Group: "g2"})
+			So(err, ShouldBeNil)
+			So(len(items), ShouldEqual, 1)
+			So(items[0].Name, ShouldEqual, "c")
+
+			So(indexer.ListIndexFuncValues("group"), ShouldHaveLength, 2)
+		})
+
+		Convey("Replace后重建索引", func() {
+			So(indexer.Replace([]*account{{Name: "d", Group: "g3"}}, "10"), ShouldBeNil)
+			So(indexer.ListKeys(), ShouldResemble, []string{"d"})
+			keys, err := indexer.IndexKeys("group", "g3")
+			So(err, ShouldBeNil)
+			So(keys, ShouldResemble, []string{"d"})
+		})
+
+		Convey("底层Indexer写入其他类型对象返回错误", func() {
+			So(indexer.Untyped().Add("string"), ShouldNotBeNil)
+		})
+	})
+
+	Convey("通过Keyer计算key", t, func() {
+		store := cache.NewTypedStore[*keyedAccount](nil)
+		So(store.Add(&keyedAccount{account{Name: "a"}}), ShouldBeNil)
+		item, exists, err := store.Get(&keyedAccount{account{Name: "a"}})
+		So(err, ShouldBeNil)
+		So(exists, ShouldBeTrue)
+		So(item.Name, ShouldEqual, "a")
+
+		So(cache.NewTypedStore[*account](nil).Add(&account{Name: "a"}), ShouldNotBeNil)
+	})
+
+	Convey("包装已有的Indexer", t, func() {
+		watchable := cache.NewWatchableIndexer(accountKey, cache.Indexers{"group": groupIndex})
+		indexer := cache.AsTyped[*account](watchable)
+		So(indexer.Add(&account{Name: "a", Group: "g1"}), ShouldBeNil)
+
+		items, err := indexer.ByIndex("group", "g1")
+		So(err, ShouldBeNil)
+		So(len(items), ShouldEqual, 1)
+		So(indexer.Untyped(), ShouldEqual, watchable)
+	})
+
+	Convey("底层Indexer中存在非T类型的对象", t, func() {
+		untyped := cache.NewIndexer(func(obj interface{}) (string, error) {
+			if a, ok := obj.(*account); ok {
+				return a.Name, nil
+			}
+			return fmt.Sprint(obj), nil
+		}, cache.Indexers{"group": func(obj interface{}) ([]string, error) { return []string{"g1"}, nil }})
+		indexer := cache.AsTyped[*account](untyped)
+		So(indexer.Add(&account{Name: "a", Group: "g1"}), ShouldBeNil)
+		So(untyped.Add("b"), ShouldBeNil)
+
+		So(indexer.List(), ShouldHaveLength, 1)
+		_, _, err := indexer.GetByKey("b")
+		So(err, ShouldNotBeNil)
+		_, err = indexer.ByIndex("group", "g1")
+		So(err, ShouldNotBeNil)
+		_, err = indexer.Index("group", &account{Name: "a"})
+		So(err, ShouldNotBeNil)
+	})
+}
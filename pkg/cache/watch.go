@@ -0,0 +1,549 @@
+package cache
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wangweihong/gotoolbox/pkg/clock"
+	"github.com/wangweihong/gotoolbox/pkg/workqueue"
+)
+
+// EventType 缓存变更事件类型.
+type EventType string
+
+const (
+	Added    EventType = "ADDED"
+	Modified EventType = "MODIFIED"
+	Deleted  EventType = "DELETED"
+)
+
+// Event 缓存变更事件. Modified事件的OldObject为变更前的对象, Deleted事件的Object为被删除的对象.
+type Event struct {
+	Type      EventType
+	Key       string
+	Object    interface{}
+	OldObject interface{}
+}
+
+// ResourceEventHandler 缓存变更事件处理器. 同一处理器的事件按变更顺序串行调用.
+type ResourceEventHandler interface {
+	OnAdd(obj interface{})
+	OnUpdate(oldObj, newObj interface{})
+	OnDelete(obj interface{})
+}
+
+// ResourceEventHandlerFuncs 通过函数实现ResourceEventHandler, 未设置的函数忽略对应事件.
+type ResourceEventHandlerFuncs struct {
+	AddFunc    func(obj interface{})
+	UpdateFunc func(oldObj, newObj interface{})
+	DeleteFunc func(obj interface{})
+}
+
+func (r ResourceEventHandlerFuncs) OnAdd(obj interface{}) {
+	if r.AddFunc != nil {
+		r.AddFunc(obj)
+	}
+}
+
+func (r ResourceEventHandlerFuncs) OnUpdate(oldObj, newObj interface{}) {
+	if r.UpdateFunc != nil {
+		r.UpdateFunc(oldObj, newObj)
+	}
+}
+
+func (r ResourceEventHandlerFuncs) OnDelete(obj interface{}) {
+	if r.DeleteFunc != nil {
+		r.DeleteFunc(obj)
+	}
+}
+
+// NewEnqueueHandler 将变更对象的key加入工作队列, 用于基于缓存事件构建控制器.
+func NewEnqueueHandler(queue workqueue.Interface, keyFunc KeyFunc) ResourceEventHandler {
+	enqueue := func(obj interface{}) {
+		if key, err := keyFunc(obj); err == nil {
+			queue.Add(key)
+		}
+	}
+	return ResourceEventHandlerFuncs{
+		AddFunc:    enqueue,
+		UpdateFunc: func(_, newObj interface{}) { enqueue(newObj) },
+		DeleteFunc: enqueue,
+	}
+}
+
+// WatchableIndexer 可以监听变更的Indexer.
+type WatchableIndexer interface {
+	Indexer
+	// Watch 返回此后的变更事件, ctx结束时关闭通道.
+	// 事件在内部缓冲, 读取缓慢不会阻塞缓存的写入.
+	Watch(ctx context.Context) <-chan Event
+	// AddEventHandler 注册事件处理器, 缓存中已有的对象先以OnAdd通知. 返回的函数用于移除处理器.
+	AddEventHandler(handler ResourceEventHandler) (remove func())
+}
+
+// watchableCache 包装Indexer, 在写入时产生变更事件.
+type watchableCache struct {
+	Indexer
+	keyFunc KeyFunc
+
+	// 保证写入及事件顺序一致
+	lock        sync.Mutex
+	broadcaster *broadcaster
+	// Replace时由Replace根据最终结果产生事件, 忽略Replace过程中的淘汰
+	replacing atomic.Bool
+	// 持有lock时产生的淘汰回调, 在unlock释放锁后调用
+	evicted []func()
+}
+
+var _ WatchableIndexer = &watchableCache{}
+
+// NewWatchable 包装Indexer, 通过该包装写入缓存时产生变更事件. 直接写入被包装的Indexer不产生事件.
+// Replace时与已有对象比较, 只对新增、删除及发生变化(reflect.DeepEqual)的对象产生事件.
+func NewWatchable(indexer Indexer, keyFunc KeyFunc) WatchableIndexer {
+	return &watchableCache{
+		Indexer:     indexer,
+		keyFunc:     keyFunc,
+		broadcaster: newBroadcaster(),
+	}
+}
+
+// NewWatchableIndexer 创建可以监听变更的Indexer.
+func NewWatchableIndexer(keyFunc KeyFunc, indexers Indexers) WatchableIndexer {
+	return NewWatchable(NewIndexer(keyFunc, indexers), keyFunc)
+}
+
+// WatchableExpiringIndexer 可以监听变更, 支持过期及容量淘汰的Indexer.
+type WatchableExpiringIndexer interface {
+	WatchableIndexer
+	ExpiringStore
+}
+
+type watchableExpiringCache struct {
+	*watchableCache
+	expiring ExpiringIndexer
+}
+
+func (c *watchableExpiringCache) AddWithTTL(obj interface{}, ttl time.Duration) error {
+	key, err := c.keyFunc(obj)
+	if err != nil {
+		return KeyError{obj, err}
+	}
+
+	c.lock.Lock()
+	defer c.unlock()
+
+	old, exists := c.get(key)
+	if err := c.expiring.AddWithTTL(obj, ttl); err != nil {
+		return err
+	}
+	c.emitSet(key, old, exists, obj)
+	return nil
+}
+
+func (c *watchableExpiringCache) Expire() int {
+	c.lock.Lock()
+	defer c.unlock()
+	return c.expiring.Expire()
+}
+
+// 读取时也会淘汰过期对象, 持有锁读取, 使淘汰产生的Deleted事件与写入产生的事件保持顺序.
+
+func (c *watchableExpiringCache) Get(obj interface{}) (interface{}, bool, error) {
+	c.lock.Lock()
+	defer c.unlock()
+	return c.Indexer.Get(obj)
+}
+
+func (c *watchableExpiringCache) GetByKey(key string) (interface{}, bool, error) {
+	c.lock.Lock()
+	defer c.unlock()
+	return c.Indexer.GetByKey(key)
+}
+
+func (c *watchableExpiringCache) List() []interface{} {
+	c.lock.Lock()
+	defer c.unlock()
+	return c.Indexer.List()
+}
+
+func (c *watchableExpiringCache) ListWithResourceVersion() ([]interface{}, string) {
+	c.lock.Lock()
+	defer c.unlock()
+	return listWithResourceVersion(c.Indexer)
+}
+
+func (c *watchableExpiringCache) ListKeys() []string {
+	c.lock.Lock()
+	defer c.unlock()
+	return c.Indexer.ListKeys()
+}
+
+func (c *watchableExpiringCache) Index(indexName string, obj interface{}) ([]interface{}, error) {
+	c.lock.Lock()
+	defer c.unlock()
+	return c.Indexer.Index(indexName, obj)
+}
+
+func (c *watchableExpiringCache) IndexKeys(indexName, indexedValue string) ([]string, error) {
+	c.lock.Lock()
+	defer c.unlock()
+	return c.Indexer.IndexKeys(indexName, indexedValue)
+}
+
+func (c *watchableExpiringCache) ListIndexFuncValues(indexName string) []string {
+	c.lock.Lock()
+	defer c.unlock()
+	return c.Indexer.ListIndexFuncValues(indexName)
+}
+
+func (c *watchableExpiringCache) ByIndex(indexName, indexedValue string) ([]interface{}, error) {
+	c.lock.Lock()
+	defer c.unlock()
+	return c.Indexer.ByIndex(indexName, indexedValue)
+}
+
+// NewWatchableExpiringIndexer 创建可以监听变更, 支持过期及容量淘汰的Indexer. 被淘汰的对象产生Deleted事件.
+// 读取与写入一样持有锁, 保证读取触发的淘汰事件与写入事件顺序一致. policy.OnEvict在释放锁后调用, 可以在回调中访问缓存.
+func NewWatchableExpiringIndexer(keyFunc KeyFunc, indexers Indexers, policy *ExpirationPolicy) WatchableExpiringIndexer {
+	return NewWatchableExpiringIndexerWithClock(keyFunc, indexers, policy, clock.RealClock{})
+}
+
+func NewWatchableExpiringIndexerWithClock(keyFunc KeyFunc, indexers Indexers, policy *ExpirationPolicy,
+	c clock.PassiveClock,
+) WatchableExpiringIndexer {
+	w := &watchableCache{
+		keyFunc:     keyFunc,
+		broadcaster: newBroadcaster(),
+	}
+	p := ExpirationPolicy{}
+	if policy != nil {
+		p = *policy
+	}
+	onEvict := p.OnEvict
+	// 淘汰只在持有w.lock时发生: 事件在锁内产生以保持顺序, 用户回调在释放锁后调用
+	p.OnEvict = func(key string, obj interface{}, reason EvictReason) {
+		if !w.replacing.Load() {
+			w.broadcaster.broadcast(Event{Type: Deleted, Key: key, Object: obj})
+		}
+		if onEvict != nil {
+			w.evicted = append(w.evicted, func() { onEvict(key, obj, reason) })
+		}
+	}
+
+	expiring := NewExpiringIndexerWithClock(keyFunc, indexers, &p, c)
+	w.Indexer = expiring
+	return &watchableExpiringCache{
+		watchableCache: w,
+		expiring:       expiring,
+	}
+}
+
+func (c *watchableCache) Add(obj interface{}) error {
+	key, err := c.keyFunc(obj)
+	if err != nil {
+		return KeyError{obj, err}
+	}
+
+	c.lock.Lock()
+	defer c.unlock()
+
+	old, exists := c.get(key)
+	if err := c.Indexer.Add(obj); err != nil {
+		return err
+	}
+	c.emitSet(key, old, exists, obj)
+	return nil
+}
+
+func (c *watchableCache) Update(obj interface{}) error {
+	key, err := c.keyFunc(obj)
+	if err != nil {
+		return KeyError{obj, err}
+	}
+
+	c.lock.Lock()
+	defer c.unlock()
+
+	old, exists := c.get(key)
+	if err := c.Indexer.Update(obj); err != nil {
+		return err
+	}
+	c.emitSet(key, old, exists, obj)
+	return nil
+}
+
+func (c *watchableCache) Delete(obj interface{}) error {
+	key, err := c.keyFunc(obj)
+	if err != nil {
+		return KeyError{obj, err}
+	}
+
+	c.lock.Lock()
+	defer c.unlock()
+
+	old, exists := c.get(key)
+	if err := c.Indexer.Delete(obj); err != nil {
+		return err
+	}
+	if exists {
+		c.broadcaster.broadcast(Event{Type: Deleted, Key: key, Object: old})
+	}
+	return nil
+}
+
+// Replace 替换所有对象, 只对新增、删除及发生变化的对象产生事件.
+func (c *watchableCache) Replace(list []interface{}, resourceVersion string) error {
+	keys := make([]string, 0, len(list))
+	items := make(map[string]interface{}, len(list))
+	for _, item := range list {
+		key, err := c.keyFunc(item)
+		if err != nil {
+			return KeyError{item, err}
+		}
+		if _, ok := items[key]; !ok {
+			keys = append(keys, key)
+		}
+		items[key] = item
+	}
+
+	c.lock.Lock()
+	defer c.unlock()
+
+	oldKeys := c.Indexer.ListKeys()
+	sort.Strings(oldKeys)
+	olds := make(map[string]interface{}, len(oldKeys))
+	for _, key := range oldKeys {
+		if obj, exists := c.get(key); exists {
+			olds[key] = obj
+		}
+	}
+	c.replacing.Store(true)
+	err := c.Indexer.Replace(list, resourceVersion)
+	c.replacing.Store(false)
+	if err != nil {
+		return err
+	}
+
+	// 与替换后的结果比较, 超出容量被淘汰的对象视为不存在.
+	// 先按key顺序产生删除事件, 再按list顺序产生新增及修改事件
+	for _, key := range oldKeys {
+		old, existed := olds[key]
+		if !existed {
+			continue
+		}
+		if _, exists := c.get(key); !exists {
+			c.broadcaster.broadcast(Event{Type: Deleted, Key: key, Object: old})
+		}
+	}
+	for _, key := range keys {
+		obj, exists := c.get(key)
+		if !exists {
+			continue
+		}
+		old, existed := olds[key]
+		if existed && reflect.DeepEqual(old, obj) {
+			continue
+		}
+		c.emitSet(key, old, existed, obj)
+	}
+	return nil
+}
+
+// LastSyncResourceVersion 返回被包装Indexer最后一次Replace的resourceVersion.
+func (c *watchableCache) LastSyncResourceVersion() string {
+	if v, ok := c.Indexer.(ResourceVersioner); ok {
+		return v.LastSyncResourceVersion()
+	}
+	return ""
+}
+
+// ListWithResourceVersion 返回被包装Indexer的所有对象及最后一次Replace的resourceVersion.
+func (c *watchableCache) ListWithResourceVersion() ([]interface{}, string) {
+	return listWithResourceVersion(c.Indexer)
+}
+
+// peeker 读取对象但不更新访问信息, 避免产生事件时影响LRU/LFU淘汰.
+type peeker interface {
+	peek(key string) (interface{}, bool)
+}
+
+func (c *watchableCache) get(key string) (interface{}, bool) {
+	if p, ok := c.Indexer.(peeker); ok {
+		return p.peek(key)
+	}
+	obj, exists, _ := c.Indexer.GetByKey(key)
+	return obj, exists
+}
+
+// unlock 释放锁后调用持有锁期间产生的淘汰回调, 使回调中可以访问缓存.
+func (c *watchableCache) unlock() {
+	evicted := c.evicted
+	c.evicted = nil
+	c.lock.Unlock()
+
+	for _, f := range evicted {
+		f()
+	}
+}
+
+// emitSet 产生写入事件. 调用方需持有锁.
+func (c *watchableCache) emitSet(key string, old interface{}, exists bool, obj interface{}) {
+	if exists {
+		c.broadcaster.broadcast(Event{Type: Modified, Key: key, Object: obj, OldObject: old})
+	} else {
+		c.broadcaster.broadcast(Event{Type: Added, Key: key, Object: obj})
+	}
+}
+
+func (c *watchableCache) Watch(ctx context.Context) <-chan Event {
+	ch := make(chan Event)
+	l := newListener(func(e Event) {
+		select {
+		case ch <- e:
+		case <-ctx.Done():
+		}
+	})
+	l.onStop = func() { close(ch) }
+
+	c.lock.Lock()
+	c.broadcaster.add(l)
+	c.unlock()
+
+	go func() {
+		<-ctx.Done()
+		c.broadcaster.remove(l)
+	}()
+	return ch
+}
+
+func (c *watchableCache) AddEventHandler(handler ResourceEventHandler) func() {
+	l := newListener(func(e Event) {
+		switch e.Type {
+		case Added:
+			handler.OnAdd(e.Object)
+		case Modified:
+			handler.OnUpdate(e.OldObject, e.Object)
+		case Deleted:
+			handler.OnDelete(e.Object)
+		}
+	})
+
+	c.lock.Lock()
+	// 持有锁时读取已有对象, 保证与之后的事件之间没有遗漏或重复
+	var initial []Event
+	for _, key := range c.Indexer.ListKeys() {
+		if obj, exists := c.get(key); exists {
+			initial = append(initial, Event{Type: Added, Key: key, Object: obj})
+		}
+	}
+	l.push(initial...)
+	c.broadcaster.add(l)
+	c.unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { c.broadcaster.remove(l) })
+	}
+}
+
+// broadcaster 将事件分发给所有监听者.
+type broadcaster struct {
+	lock      sync.Mutex
+	listeners map[*listener]struct{}
+}
+
+func newBroadcaster() *broadcaster {
+	return &broadcaster{listeners: map[*listener]struct{}{}}
+}
+
+func (b *broadcaster) add(l *listener) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.listeners[l] = struct{}{}
+	go l.run()
+}
+
+func (b *broadcaster) remove(l *listener) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if _, ok := b.listeners[l]; ok {
+		delete(b.listeners, l)
+		l.stop()
+	}
+}
+
+func (b *broadcaster) broadcast(e Event) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for l := range b.listeners {
+		l.push(e)
+	}
+}
+
+// listener 缓冲事件并在独立的协程中依次处理, 避免处理缓慢阻塞缓存写入.
+type listener struct {
+	lock    sync.Mutex
+	cond    *sync.Cond
+	pending []Event
+	stopped bool
+
+	handle func(Event)
+	onStop func()
+}
+
+func newListener(handle func(Event)) *listener {
+	l := &listener{handle: handle}
+	l.cond = sync.NewCond(&l.lock)
+	return l
+}
+
+func (l *listener) push(events ...Event) {
+	if len(events) == 0 {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.pending = append(l.pending, events...)
+	l.cond.Signal()
+}
+
+func (l *listener) stop() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.stopped = true
+	l.cond.Signal()
+}
+
+func (l *listener) run() {
+	defer func() {
+		if l.onStop != nil {
+			l.onStop()
+		}
+	}()
+
+	for {
+		l.lock.Lock()
+		for len(l.pending) == 0 && !l.stopped {
+			l.cond.Wait()
+		}
+		if l.stopped {
+			l.lock.Unlock()
+			return
+		}
+		e := l.pending[0]
+		l.pending[0] = Event{}
+		l.pending = l.pending[1:]
+		l.lock.Unlock()
+
+		l.handle(e)
+	}
+}
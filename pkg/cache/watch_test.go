@@ -0,0 +1,218 @@
+package cache_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/wangweihong/gotoolbox/pkg/cache"
+	"github.com/wangweihong/gotoolbox/pkg/clock"
+	"github.com/wangweihong/gotoolbox/pkg/workqueue"
+)
+
+func receive(ch <-chan cache.Event, n int) []cache.Event {
+	var events []cache.Event
+	for i := 0; i < n; i++ {
+		select {
+		case e := <-ch:
+			events = append(events, e)
+		case <-time.After(time.Second):
+			return events
+		}
+	}
+	return events
+}
+
+func eventTypes(events []cache.Event) []string {
+	var types []string
+	for _, e := range events {
+		types = append(types, string(e.Type)+":"+e.Key)
+	}
+	return types
+}
+
+func TestWatch(t *testing.T) {
+	Convey("Watch变更事件", t, func() {
+		indexer := cache.NewWatchableIndexer(accountKey, cache.Indexers{"group": groupIndex})
+		So(indexer.Add(&account{Name: "u0", Group: "g1"}), ShouldBeNil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		ch := indexer.Watch(ctx)
+
+		So(indexer.Add(&account{Name: "u1", Group: "g1"}), ShouldBeNil)
+		So(indexer.Update(&account{Name: "u1", Group: "g2"}), ShouldBeNil)
+		So(indexer.Delete(&account{Name: "u1"}), ShouldBeNil)
+		So(indexer.Delete(&account{Name: "notexist"}), ShouldBeNil)
+
+		events := receive(ch, 3)
+		So(eventTypes(events), ShouldResemble, []string{"ADDED:u1", "MODIFIED:u1", "DELETED:u1"})
+		So(events[1].OldObject.(*account).Group, ShouldEqual, "g1")
+		So(events[1].Object.(*account).Group, ShouldEqual, "g2")
+		So(events[2].Object.(*account).Group, ShouldEqual, "g2")
+
+		Convey("Replace只产生实际变化的事件", func() {
+			So(indexer.Add(&account{Name: "u2", Group: "g1"}), ShouldBeNil)
+			So(receive(ch, 1), ShouldHaveLength, 1)
+
+			So(indexer.Replace([]interface{}{
+				&account{Name: "u0", Group: "g1"},
+				&account{Name: "u2", Group: "g2"},
+				&account{Name: "u3", Group: "g1"},
+			}, ""), ShouldBeNil)
+			events := receive(ch, 2)
+			So(eventTypes(events), ShouldResemble, []string{"MODIFIED:u2", "ADDED:u3"})
+
+			So(indexer.Replace([]interface{}{&account{Name: "u3", Group: "g1"}}, ""), ShouldBeNil)
+			So(eventTypes(receive(ch, 2)), ShouldHaveLength, 2)
+
+			keys, _ := indexer.IndexKeys("group", "g1")
+			So(keys, ShouldResemble, []string{"u3"})
+		})
+
+		Convey("ctx结束后关闭通道", func() {
+			cancel()
+			for range ch {
+			}
+		})
+		cancel()
+	})
+
+	Convey("事件处理器", t, func() {
+		indexer := cache.NewWatchableIndexer(accountKey, cache.Indexers{})
+		So(indexer.Add(&account{Name: "u0"}), ShouldBeNil)
+
+		var lock sync.Mutex
+		var calls []string
+		record := func(s string) {
+			lock.Lock()
+			defer lock.Unlock()
+			calls = append(calls, s)
+		}
+		remove := indexer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { record("add:" + obj.(*account).Name) },
+			UpdateFunc: func(_, obj interface{}) { record("update:" + obj.(*account).Name) },
+			DeleteFunc: func(obj interface{}) { record("delete:" + obj.(*account).Name) },
+		})
+
+		So(indexer.Add(&account{Name: "u1"}), ShouldBeNil)
+		So(indexer.Add(&account{Name: "u1", Group: "g1"}), ShouldBeNil)
+		So(indexer.Delete(&account{Name: "u0"}), ShouldBeNil)
+
+		expected := []string{"add:u0", "add:u1", "update:u1", "delete:u0"}
+		So(func() bool {
+			deadline := time.Now().Add(time.Second)
+			for time.Now().Before(deadline) {
+				lock.Lock()
+				n := len(calls)
+				lock.Unlock()
+				if n >= len(expected) {
+					return true
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			return false
+		}(), ShouldBeTrue)
+		lock.Lock()
+		So(calls, ShouldResemble, expected)
+		lock.Unlock()
+
+		remove()
+		So(indexer.Add(&account{Name: "u2"}), ShouldBeNil)
+		time.Sleep(50 * time.Millisecond)
+		lock.Lock()
+		So(calls, ShouldHaveLength, len(expected))
+		lock.Unlock()
+	})
+
+	Convey("事件加入工作队列", t, func() {
+		queue := workqueue.NewNamedRateLimitingQueue(
+			workqueue.NewItemExponentialFailureRateLimiter(time.Millisecond, time.Second), "cache")
+		defer queue.ShutDown()
+
+		indexer := cache.NewWatchableIndexer(accountKey, cache.Indexers{})
+		indexer.AddEventHandler(cache.NewEnqueueHandler(queue, accountKey))
+		So(indexer.Add(&account{Name: "u1"}), ShouldBeNil)
+
+		item, shutdown := queue.Get()
+		So(shutdown, ShouldBeFalse)
+		So(item, ShouldEqual, "u1")
+		queue.Done(item)
+	})
+
+	Convey("淘汰产生删除事件", t, func() {
+		indexer := cache.NewWatchableExpiringIndexer(accountKey, cache.Indexers{}, &cache.ExpirationPolicy{MaxEntries: 1})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ch := indexer.Watch(ctx)
+
+		So(indexer.Add(&account{Name: "u1"}), ShouldBeNil)
+		So(indexer.AddWithTTL(&account{Name: "u2"}, time.Minute), ShouldBeNil)
+		So(eventTypes(receive(ch, 3)), ShouldResemble, []string{"ADDED:u1", "DELETED:u1", "ADDED:u2"})
+
+		So(indexer.Replace([]interface{}{&account{Name: "u3"}, &account{Name: "u4"}}, ""), ShouldBeNil)
+		events := eventTypes(receive(ch, 2))
+		So(events, ShouldHaveLength, 2)
+		So(events[0], ShouldEqual, "DELETED:u2")
+		So(indexer.ListKeys(), ShouldHaveLength, 1)
+		So(events[1], ShouldEqual, "ADDED:"+indexer.ListKeys()[0])
+	})
+}
+
+func TestWatchExpireOnRead(t *testing.T) {
+	Convey("读取触发的淘汰事件与写入事件顺序一致", t, func() {
+		fakeClock := clock.NewFakeClock(time.Now())
+		var indexer cache.WatchableExpiringIndexer
+		indexer = cache.NewWatchableExpiringIndexerWithClock(accountKey, cache.Indexers{}, &cache.ExpirationPolicy{
+			TTL: time.Second,
+			OnEvict: func(key string, obj interface{}, reason cache.EvictReason) {
+				// 回调在释放锁后调用, 写入的事件在淘汰事件之后
+				_ = indexer.Add(&account{Name: "u1"})
+			},
+		}, fakeClock)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ch := indexer.Watch(ctx)
+
+		So(indexer.Add(&account{Name: "u1"}), ShouldBeNil)
+		fakeClock.Step(2 * time.Second)
+		_, exists, err := indexer.GetByKey("u1")
+		So(err, ShouldBeNil)
+		So(exists, ShouldBeFalse)
+		So(eventTypes(receive(ch, 3)), ShouldResemble, []string{"ADDED:u1", "DELETED:u1", "ADDED:u1"})
+		So(indexer.ListKeys(), ShouldResemble, []string{"u1"})
+	})
+}
+
+func TestWatchOnEvictAccessCache(t *testing.T) {
+	Convey("淘汰回调中访问缓存", t, func() {
+		var indexer cache.WatchableExpiringIndexer
+		var keys [][]string
+		var found []bool
+		indexer = cache.NewWatchableExpiringIndexer(accountKey, cache.Indexers{}, &cache.ExpirationPolicy{
+			MaxEntries: 1,
+			OnEvict: func(key string, obj interface{}, reason cache.EvictReason) {
+				keys = append(keys, indexer.ListKeys())
+				_, exists, _ := indexer.GetByKey(key)
+				found = append(found, exists)
+			},
+		})
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = indexer.Add(&account{Name: "u1"})
+			_ = indexer.Add(&account{Name: "u2"})
+			_ = indexer.AddWithTTL(&account{Name: "u3"}, time.Minute)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("OnEvict accessing the cache deadlocked")
+		}
+		So(keys, ShouldResemble, [][]string{{"u2"}, {"u3"}})
+		So(found, ShouldResemble, []bool{false, false})
+	})
+}
@@ -0,0 +1,238 @@
+//nolint:errorlint
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// Class 错误分类, 可以按位组合.
+type Class uint32
+
+const (
+	// ClassRetryable 可重试错误, 如依赖服务暂时不可用.
+	ClassRetryable Class = 1 << iota
+	// ClassTimeout 超时错误.
+	ClassTimeout
+	// ClassNotFound 资源不存在.
+	ClassNotFound
+	// ClassConflict 资源冲突, 如已存在或版本冲突.
+	ClassConflict
+	// ClassPermission 无权限.
+	ClassPermission
+	// ClassUserVisible 错误信息可以直接展示给用户.
+	ClassUserVisible
+)
+
+var classNames = []struct {
+	class Class
+	name  string
+}{
+	{ClassRetryable, "retryable"},
+	{ClassTimeout, "timeout"},
+	{ClassNotFound, "not-found"},
+	{ClassConflict, "conflict"},
+	{ClassPermission, "permission"},
+	{ClassUserVisible, "user-visible"},
+}
+
+// Has 是否包含指定的所有分类.
+func (c Class) Has(class Class) bool {
+	return class != 0 && c&class == class
+}
+
+func (c Class) String() string {
+	names := make([]string, 0, len(classNames))
+	for _, n := range classNames {
+		if c.Has(n.class) {
+			names = append(names, n.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// ClassCoder 声明了默认错误分类的Coder. 携带该错误码的错误默认属于这些分类.
+type ClassCoder interface {
+	Coder
+	Class() Class
+}
+
+// NewClassCoder 创建声明了默认错误分类的Coder.
+func NewClassCoder(code int, httpCode int, message map[string]string, classes ...Class) Coder {
+	return classCoder{
+		defaultCoder: defaultCoder{
+			code:     code,
+			message:  message,
+			httpCode: httpCode,
+		},
+		class: joinClasses(classes),
+	}
+}
+
+type classCoder struct {
+	defaultCoder
+	class Class
+}
+
+func (c classCoder) Class() Class {
+	return c.class
+}
+
+// WithClass 为错误附加分类. 携带错误码的错误附加分类后仍保留错误码.
+// If err is nil, WithClass returns nil.
+func WithClass(err error, classes ...Class) error {
+	if err == nil {
+		return nil
+	}
+
+	if e, ok := err.(*withCode); ok {
+		return &withCode{
+			err:   e.err,
+			code:  e.code,
+			cause: err,
+			class: joinClasses(classes),
+			stack: e.stack,
+		}
+	}
+
+	return &withClass{
+		cause: err,
+		class: joinClasses(classes),
+	}
+}
+
+// Retryable 标记错误为可重试.
+func Retryable(err error) error {
+	return WithClass(err, ClassRetryable)
+}
+
+// UserVisible 标记错误信息可以直接展示给用户.
+func UserVisible(err error) error {
+	return WithClass(err, ClassUserVisible)
+}
+
+type withClass struct {
+	cause error
+	class Class
+}
+
+func (w *withClass) Error() string { return w.cause.Error() }
+
+func (w *withClass) Cause() error { return w.cause }
+
+// Unwrap provides compatibility for Go 1.13 error chains.
+func (w *withClass) Unwrap() error { return w.cause }
+
+func (w *withClass) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			fmt.Fprintf(s, "%+v", w.cause)
+			return
+		}
+		fallthrough
+	case 's', 'q':
+		io.WriteString(s, w.Error())
+	}
+}
+
+// Classes 返回错误链中所有错误的分类, 包括:
+//  1. WithClass附加的分类;
+//  2. 错误码注册时声明的默认分类;
+//  3. 标准库错误, 如超时错误、fs.ErrNotExist、fs.ErrExist、fs.ErrPermission.
+func Classes(err error) Class {
+	var c Class
+	for err != nil {
+		switch e := err.(type) {
+		case *withClass:
+			c |= e.class
+		case *withCode:
+			c |= e.class
+			codeMux.RLock()
+			coder, ok := codes[e.code]
+			codeMux.RUnlock()
+			if cc, ok2 := coder.(ClassCoder); ok && ok2 {
+				c |= cc.Class()
+			}
+		default:
+			c |= stdClasses(err)
+		}
+
+		switch e := err.(type) {
+		case interface{ Cause() error }:
+			err = e.Cause()
+		case interface{ Unwrap() []error }:
+			for _, inner := range e.Unwrap() {
+				c |= Classes(inner)
+			}
+			return c
+		default:
+			err = errors.Unwrap(err)
+		}
+	}
+	return c
+}
+
+// stdClasses 标准库错误的分类.
+func stdClasses(err error) Class {
+	var c Class
+	if t, ok := err.(interface{ Timeout() bool }); ok && t.Timeout() {
+		c |= ClassTimeout
+	}
+	// syscall.Errno等通过Is方法匹配
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		c |= ClassNotFound
+	case errors.Is(err, fs.ErrExist):
+		c |= ClassConflict
+	case errors.Is(err, fs.ErrPermission):
+		c |= ClassPermission
+	}
+	return c
+}
+
+// HasClass reports whether any error in err's chain has all the given classes.
+func HasClass(err error, class Class) bool {
+	return Classes(err).Has(class)
+}
+
+// IsRetryable 错误是否可重试.
+func IsRetryable(err error) bool {
+	return HasClass(err, ClassRetryable)
+}
+
+// IsTimeout 是否为超时错误.
+func IsTimeout(err error) bool {
+	return HasClass(err, ClassTimeout)
+}
+
+// IsNotFound 是否为资源不存在错误.
+func IsNotFound(err error) bool {
+	return HasClass(err, ClassNotFound)
+}
+
+// IsConflict 是否为资源冲突错误.
+func IsConflict(err error) bool {
+	return HasClass(err, ClassConflict)
+}
+
+// IsPermission 是否为无权限错误.
+func IsPermission(err error) bool {
+	return HasClass(err, ClassPermission)
+}
+
+// IsUserVisible 错误信息是否可以直接展示给用户.
+func IsUserVisible(err error) bool {
+	return HasClass(err, ClassUserVisible)
+}
+
+func joinClasses(classes []Class) Class {
+	var c Class
+	for _, class := range classes {
+		c |= class
+	}
+	return c
+}
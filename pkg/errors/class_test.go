@@ -0,0 +1,75 @@
+package errors_test
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/wangweihong/gotoolbox/pkg/errors"
+)
+
+const (
+	ErrServiceBusy = iota + 2001
+	ErrUserExist
+)
+
+func init() {
+	errors.MustRegister(errors.NewClassCoder(ErrServiceBusy, http.StatusInternalServerError, map[string]string{
+		errors.MessageLangCNKey: "服务繁忙",
+		errors.MessageLangENKey: "Service busy",
+	}, errors.ClassRetryable))
+	errors.MustRegister(errors.NewClassCoder(ErrUserExist, http.StatusBadRequest, map[string]string{
+		errors.MessageLangCNKey: "用户已存在",
+		errors.MessageLangENKey: "User already exists",
+	}, errors.ClassConflict, errors.ClassUserVisible))
+}
+
+func TestClass(t *testing.T) {
+	Convey("错误分类", t, func() {
+		Convey("附加分类", func() {
+			err := errors.Retryable(errors.New("connection refused"))
+			So(errors.IsRetryable(err), ShouldBeTrue)
+			So(errors.IsTimeout(err), ShouldBeFalse)
+			So(err.Error(), ShouldEqual, "connection refused")
+
+			// 经过多层包装后仍可查询
+			err = errors.Wrap(fmt.Errorf("call: %w", errors.WithStack(err)), "sync user")
+			So(errors.IsRetryable(err), ShouldBeTrue)
+			So(errors.WithClass(nil, errors.ClassRetryable), ShouldBeNil)
+		})
+
+		Convey("附加分类后保留错误码", func() {
+			err := errors.UserVisible(errors.WithCode(ErrCall, "call device service fail"))
+			So(errors.IsCode(err, ErrCall), ShouldBeTrue)
+			So(errors.IsUserVisible(err), ShouldBeTrue)
+			So(errors.Classes(err), ShouldEqual, errors.ClassUserVisible)
+		})
+
+		Convey("错误码声明的默认分类", func() {
+			err := errors.Wrap(errors.WithCode(ErrServiceBusy, "device service busy"), "sync device")
+			So(errors.IsRetryable(err), ShouldBeTrue)
+
+			err = errors.WithClass(errors.WithCode(ErrUserExist, "user u1 exist"), errors.ClassRetryable)
+			c := errors.Classes(err)
+			So(c.Has(errors.ClassConflict|errors.ClassUserVisible|errors.ClassRetryable), ShouldBeTrue)
+			So(c.Has(errors.ClassPermission), ShouldBeFalse)
+			So(c.String(), ShouldEqual, "retryable|conflict|user-visible")
+
+			So(errors.Classes(errors.WithCode(ErrCall, "call fail")), ShouldEqual, 0)
+		})
+
+		Convey("标准库错误", func() {
+			_, err := os.Open("/not/exist/file")
+			So(errors.IsNotFound(errors.WithStack(err)), ShouldBeTrue)
+			So(errors.IsTimeout(errors.Wrap(context.DeadlineExceeded, "wait")), ShouldBeTrue)
+			So(errors.IsPermission(stderrors.Join(errors.New("a"), os.ErrPermission)), ShouldBeTrue)
+			So(errors.IsRetryable(context.DeadlineExceeded), ShouldBeFalse)
+			So(errors.Classes(nil), ShouldEqual, 0)
+		})
+	})
+}
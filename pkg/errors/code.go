@@ -93,6 +93,8 @@ func (f defaultCoder) MessageEN() string {
 var (
 	codes   = map[int]Coder{}
 	codeMux = &sync.RWMutex{}
+	// 注册错误码的包路径
+	codePkgs = map[int]string{}
 )
 
 func registerPre(coder Coder) {
@@ -138,6 +140,7 @@ func Register(coder Coder) {
 	defer codeMux.Unlock()
 
 	codes[coder.Code()] = coder
+	codePkgs[coder.Code()] = callerPackage()
 }
 
 // MustRegister register a user define error code.
@@ -153,6 +156,7 @@ func MustRegister(coder Coder) {
 	}
 
 	codes[coder.Code()] = coder
+	codePkgs[coder.Code()] = callerPackage()
 }
 
 // ParseCoder parse any error into *withCode.
@@ -242,4 +246,6 @@ func NewCoder(code int, httpCode int, message map[string]string) Coder {
 func init() {
 	codes[success.code] = success
 	codes[unknown.code] = unknown
+	codePkgs[success.code] = selfPackage()
+	codePkgs[unknown.code] = selfPackage()
 }
@@ -304,6 +304,8 @@ type withCode struct {
 	err   error
 	code  int
 	cause error
+	// 附加的错误分类
+	class Class
 	*stack
 }
 
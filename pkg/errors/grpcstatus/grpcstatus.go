@@ -0,0 +1,230 @@
+// Package grpcstatus 在pkg/errors的错误码/Status与gRPC status之间转换.
+//
+// 错误码、HTTP状态码通过errdetails.ErrorInfo传递, 中英文信息通过errdetails.LocalizedMessage传递,
+// ServiceStack调用链中的每个服务通过一个errdetails.DebugInfo传递, 因此跨服务时可以还原完整的错误调用链.
+package grpcstatus
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/wangweihong/gotoolbox/pkg/errors"
+	"github.com/wangweihong/gotoolbox/pkg/json"
+)
+
+// ErrorDomain ErrorInfo的Domain, 用于识别由本包转换的gRPC status.
+const ErrorDomain = "github.com/wangweihong/gotoolbox/pkg/errors"
+
+const (
+	metadataCode       = "code"
+	metadataHTTPStatus = "http_status"
+)
+
+// 错误信息语言与gRPC LocalizedMessage语言标签的对应关系.
+var langToLocale = map[string]string{
+	errors.MessageLangCNKey: "zh-CN",
+	errors.MessageLangENKey: "en-US",
+}
+
+// ToGRPCStatus 将错误转换成gRPC status. nil错误返回OK状态.
+// 已经是gRPC status的错误直接返回; context撤销/超时转换为对应的gRPC状态码.
+func ToGRPCStatus(err error) *status.Status {
+	if err == nil {
+		return status.New(codes.OK, "")
+	}
+	var se interface{ GRPCStatus() *status.Status }
+	if stderrors.As(err, &se) {
+		return se.GRPCStatus()
+	}
+
+	s := errors.ToStatus(err)
+	grpcCode := GRPCCode(s.HTTPStatus)
+	switch {
+	case stderrors.Is(err, context.Canceled):
+		grpcCode = codes.Canceled
+	case stderrors.Is(err, context.DeadlineExceeded):
+		grpcCode = codes.DeadlineExceeded
+	}
+	return StatusToGRPC(s, grpcCode)
+}
+
+// StatusToGRPC 将Status转换成指定gRPC状态码的gRPC status.
+func StatusToGRPC(s *errors.Status, grpcCode codes.Code) *status.Status {
+	if grpcCode == codes.OK {
+		return status.New(codes.OK, "")
+	}
+
+	st := status.New(grpcCode, s.Desc)
+
+	info := &errdetails.ErrorInfo{
+		Reason: strconv.Itoa(s.Code),
+		Domain: ErrorDomain,
+		Metadata: map[string]string{
+			metadataCode:       strconv.Itoa(s.Code),
+			metadataHTTPStatus: strconv.Itoa(s.HTTPStatus),
+		},
+	}
+	withDetails, err := st.WithDetails(info)
+	if err != nil {
+		return st
+	}
+	st = withDetails
+
+	langs := make([]string, 0, len(s.Message))
+	for lang := range s.Message {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	for _, lang := range langs {
+		locale, ok := langToLocale[lang]
+		if !ok {
+			locale = lang
+		}
+		if withDetails, err := st.WithDetails(&errdetails.LocalizedMessage{Locale: locale, Message: s.Message[lang]}); err == nil {
+			st = withDetails
+		}
+	}
+
+	for _, ss := range s.Cause {
+		service, _ := json.Marshal(ss.Service)
+		if withDetails, err := st.WithDetails(&errdetails.DebugInfo{StackEntries: ss.Stacks, Detail: string(service)}); err == nil {
+			st = withDetails
+		}
+	}
+	return st
+}
+
+// FromGRPCStatus 将gRPC status转换成Status. OK状态返回成功Status.
+// 非本包转换的gRPC status错误码为未知错误码, HTTP状态码根据gRPC状态码推断.
+func FromGRPCStatus(st *status.Status) *errors.Status {
+	if st == nil || st.Code() == codes.OK {
+		return errors.ToStatus(nil)
+	}
+
+	unknown := errors.Unknown()
+	s := &errors.Status{
+		HTTPStatus: HTTPStatus(st.Code()),
+		Code:       unknown.Code(),
+		Message:    unknown.Message(),
+		Desc:       st.Message(),
+	}
+
+	var messages map[string]string
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			if d.GetDomain() != ErrorDomain {
+				continue
+			}
+			if code, err := strconv.Atoi(d.GetMetadata()[metadataCode]); err == nil {
+				s.Code = code
+			}
+			if httpStatus, err := strconv.Atoi(d.GetMetadata()[metadataHTTPStatus]); err == nil {
+				s.HTTPStatus = httpStatus
+			}
+		case *errdetails.LocalizedMessage:
+			if messages == nil {
+				messages = make(map[string]string)
+			}
+			messages[localeToLang(d.GetLocale())] = d.GetMessage()
+		case *errdetails.DebugInfo:
+			ss := errors.ServiceStack{Stacks: d.GetStackEntries()}
+			_ = json.Unmarshal([]byte(d.GetDetail()), &ss.Service)
+			s.Cause = append(s.Cause, ss)
+		}
+	}
+	if messages != nil {
+		s.Message = messages
+	}
+	return s
+}
+
+// FromGRPCError 将gRPC调用返回的错误转换成pkg/errors的状态错误, 并在错误调用链中添加当前服务的调用栈.
+// 可以通过errors.ToStatus获取错误码. nil错误返回nil; 非gRPC status错误原样返回.
+func FromGRPCError(err error) error {
+	if err == nil {
+		return nil
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	return FromGRPCStatus(st).Error()
+}
+
+func localeToLang(locale string) string {
+	for lang, l := range langToLocale {
+		if l == locale {
+			return lang
+		}
+	}
+	return locale
+}
+
+// GRPCCode 根据HTTP状态码返回对应的gRPC状态码.
+func GRPCCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusOK:
+		return codes.OK
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	case http.StatusInternalServerError:
+		return codes.Internal
+	default:
+		return codes.Unknown
+	}
+}
+
+// HTTPStatus 根据gRPC状态码返回对应的HTTP状态码, 参考google.golang.org/grpc-gateway.
+func HTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		// 客户端关闭请求, 非标准状态码
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
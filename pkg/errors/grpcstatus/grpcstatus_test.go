@@ -0,0 +1,138 @@
+package grpcstatus_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/wangweihong/gotoolbox/pkg/errors"
+	"github.com/wangweihong/gotoolbox/pkg/errors/grpcstatus"
+)
+
+const ErrDeviceNotFound = 110001
+
+func init() {
+	errors.MustRegister(errors.NewCoder(ErrDeviceNotFound, http.StatusNotFound, map[string]string{
+		errors.MessageLangCNKey: "设备不存在",
+		errors.MessageLangENKey: "Device not found",
+	}))
+	errors.UpdateModuleInfo(errors.NewModuleGetter("github.com/wangweihong/gotoolbox", "127.0.0.1", 123))
+}
+
+// 健康检查服务, 所有调用都返回设备不存在错误.
+type healthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+}
+
+func (healthServer) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	return nil, errors.NewStatusF(ErrDeviceNotFound, "device %v not found", req.GetService())
+}
+
+func (healthServer) Watch(req *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
+	if err := stream.Send(&grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}); err != nil {
+		return err
+	}
+	return errors.WithCode(ErrDeviceNotFound, "device %v removed", req.GetService())
+}
+
+func TestGRPCStatus(t *testing.T) {
+	Convey("Status与gRPC status转换", t, func() {
+		Convey("携带错误码/多语言信息/服务调用栈", func() {
+			s := errors.ToStatus(errors.NewStatus(ErrDeviceNotFound, "device d1 not found"))
+			st := grpcstatus.ToGRPCStatus(errors.NewStatus(ErrDeviceNotFound, "device d1 not found"))
+			So(st.Code(), ShouldEqual, codes.NotFound)
+			So(st.Message(), ShouldEqual, s.Desc)
+
+			got := grpcstatus.FromGRPCStatus(st)
+			So(got.Code, ShouldEqual, ErrDeviceNotFound)
+			So(got.HTTPStatus, ShouldEqual, http.StatusNotFound)
+			So(got.Message, ShouldResemble, s.Message)
+			So(got.Desc, ShouldEqual, s.Desc)
+			So(len(got.Cause), ShouldEqual, 1)
+			So(got.Cause[0].Service, ShouldResemble, s.Cause[0].Service)
+			So(got.Cause[0].Stacks, ShouldNotBeEmpty)
+		})
+
+		Convey("WithCode错误", func() {
+			st := grpcstatus.ToGRPCStatus(errors.WithCode(ErrDeviceNotFound, "device d1 not found"))
+			So(st.Code(), ShouldEqual, codes.NotFound)
+			So(grpcstatus.FromGRPCStatus(st).Code, ShouldEqual, ErrDeviceNotFound)
+		})
+
+		Convey("非本包的gRPC status", func() {
+			got := grpcstatus.FromGRPCStatus(status.New(codes.PermissionDenied, "denied"))
+			So(got.Code, ShouldEqual, errors.Unknown().Code())
+			So(got.HTTPStatus, ShouldEqual, http.StatusForbidden)
+			So(got.Desc, ShouldEqual, "denied")
+
+			So(grpcstatus.ToGRPCStatus(status.Error(codes.Unavailable, "down")).Code(), ShouldEqual, codes.Unavailable)
+			So(grpcstatus.ToGRPCStatus(context.DeadlineExceeded).Code(), ShouldEqual, codes.DeadlineExceeded)
+			So(grpcstatus.ToGRPCStatus(nil).Code(), ShouldEqual, codes.OK)
+			So(grpcstatus.FromGRPCError(nil), ShouldBeNil)
+		})
+	})
+}
+
+func TestInterceptor(t *testing.T) {
+	Convey("gRPC拦截器自动转换错误", t, func() {
+		lis := bufconn.Listen(1024 * 1024)
+		server := grpc.NewServer(
+			grpc.UnaryInterceptor(grpcstatus.UnaryServerInterceptor()),
+			grpc.StreamInterceptor(grpcstatus.StreamServerInterceptor()),
+		)
+		grpc_health_v1.RegisterHealthServer(server, healthServer{})
+		go func() {
+			_ = server.Serve(lis)
+		}()
+		defer server.Stop()
+
+		conn, err := grpc.NewClient("passthrough:///bufnet",
+			grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
+				return lis.DialContext(ctx)
+			}),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithUnaryInterceptor(grpcstatus.UnaryClientInterceptor()),
+			grpc.WithStreamInterceptor(grpcstatus.StreamClientInterceptor()),
+		)
+		So(err, ShouldBeNil)
+		defer conn.Close()
+		client := grpc_health_v1.NewHealthClient(conn)
+
+		Convey("一元调用", func() {
+			_, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "d1"})
+			So(err, ShouldNotBeNil)
+
+			s := errors.ToStatus(err)
+			So(s.Code, ShouldEqual, ErrDeviceNotFound)
+			So(s.HTTPStatus, ShouldEqual, http.StatusNotFound)
+			So(s.Message[errors.MessageLangCNKey], ShouldEqual, "设备不存在")
+			So(s.Desc, ShouldContainSubstring, "device d1 not found")
+			// 客户端调用栈 + 服务端调用栈
+			So(len(s.Cause), ShouldEqual, 2)
+		})
+
+		Convey("流调用", func() {
+			stream, err := client.Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "d2"})
+			So(err, ShouldBeNil)
+			resp, err := stream.Recv()
+			So(err, ShouldBeNil)
+			So(resp.GetStatus(), ShouldEqual, grpc_health_v1.HealthCheckResponse_SERVING)
+
+			_, err = stream.Recv()
+			So(err, ShouldNotEqual, io.EOF)
+			s := errors.ToStatus(err)
+			So(s.Code, ShouldEqual, ErrDeviceNotFound)
+			So(s.Desc, ShouldContainSubstring, "device d2 removed")
+		})
+	})
+}
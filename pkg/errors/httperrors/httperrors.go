@@ -0,0 +1,238 @@
+// Package httperrors 将错误渲染成统一格式的HTTP JSON错误响应.
+//
+// HTTP状态码取自错误码注册的Coder, 错误信息根据请求的Accept-Language选择中文或英文,
+// 具体出错信息及调用栈等调试信息仅在调试模式下返回, 避免泄露内部实现.
+//
+//	{"code":110001,"message":"设备不存在"}
+package httperrors
+
+import (
+	"net/http"
+	"runtime/debug"
+
+	"golang.org/x/text/language"
+
+	"github.com/wangweihong/gotoolbox/pkg/errors"
+	"github.com/wangweihong/gotoolbox/pkg/json"
+	"github.com/wangweihong/gotoolbox/pkg/log"
+)
+
+// ErrorBody 错误响应体.
+type ErrorBody struct {
+	// 错误码
+	Code int `json:"code"`
+	// 错误码对应的信息, 根据请求语言选择
+	Message string `json:"message"`
+
+	// 以下字段仅在调试模式下返回
+	// 具体的出错信息
+	Desc       string                `json:"desc,omitempty"`
+	HTTPStatus int                   `json:"http,omitempty"`
+	Stack      any                   `json:"stack,omitempty"`
+	Cause      []errors.ServiceStack `json:"cause,omitempty"`
+}
+
+// 错误信息语言与语言标签的对应关系.
+var langTags = map[string]language.Tag{
+	errors.MessageLangCNKey: language.Chinese,
+	errors.MessageLangENKey: language.English,
+}
+
+var supportedLangs = []string{errors.MessageLangENKey, errors.MessageLangCNKey}
+
+type Option func(*Renderer)
+
+// WithDebug 调试模式下返回具体出错信息、调用栈及服务调用链.
+func WithDebug(debug bool) Option {
+	return func(r *Renderer) {
+		r.debug = debug
+	}
+}
+
+// WithDefaultLang 请求未指定或指定了不支持的语言时使用的语言, 默认为errors.MessageLangENKey.
+func WithDefaultLang(lang string) Option {
+	return func(r *Renderer) {
+		if _, ok := langTags[lang]; ok {
+			r.defaultLang = lang
+		}
+	}
+}
+
+// NewRenderer 创建错误响应渲染器.
+func NewRenderer(opts ...Option) *Renderer {
+	r := &Renderer{
+		defaultLang: errors.MessageLangENKey,
+	}
+	for _, o := range opts {
+		o(r)
+	}
+
+	// 默认语言放在第一位, 无法匹配时使用
+	r.langs = []string{r.defaultLang}
+	for _, lang := range supportedLangs {
+		if lang != r.defaultLang {
+			r.langs = append(r.langs, lang)
+		}
+	}
+	tags := make([]language.Tag, 0, len(r.langs))
+	for _, lang := range r.langs {
+		tags = append(tags, langTags[lang])
+	}
+	r.matcher = language.NewMatcher(tags)
+	return r
+}
+
+type Renderer struct {
+	debug       bool
+	defaultLang string
+	langs       []string
+	matcher     language.Matcher
+}
+
+// Lang 根据请求的Accept-Language选择错误信息语言.
+func (r *Renderer) Lang(req *http.Request) string {
+	if req == nil {
+		return r.defaultLang
+	}
+	accept := req.Header.Get("Accept-Language")
+	if accept == "" {
+		return r.defaultLang
+	}
+	tags, _, err := language.ParseAcceptLanguage(accept)
+	if err != nil || len(tags) == 0 {
+		return r.defaultLang
+	}
+	_, index, confidence := r.matcher.Match(tags...)
+	if confidence == language.No {
+		return r.defaultLang
+	}
+	return r.langs[index]
+}
+
+// Render 将错误转换成HTTP状态码及错误响应体.
+func (r *Renderer) Render(req *http.Request, err error) (int, *ErrorBody) {
+	st := errors.ToStatus(err)
+	httpStatus := st.HTTPStatus
+	if httpStatus == 0 {
+		httpStatus = http.StatusInternalServerError
+	}
+
+	message := st.Message[r.Lang(req)]
+	if message == "" {
+		message = st.Message[errors.MessageLangENKey]
+	}
+	body := &ErrorBody{
+		Code:    st.Code,
+		Message: message,
+	}
+
+	if r.debug {
+		body.Desc = st.Desc
+		body.HTTPStatus = httpStatus
+		body.Cause = st.Cause
+		if fe := errors.FromError(err); fe != nil {
+			body.Stack = fe.ToDetailJson()["stack"]
+		}
+	}
+	return httpStatus, body
+}
+
+// WriteError 将错误写入HTTP响应. err为nil时不做任何处理.
+func (r *Renderer) WriteError(w http.ResponseWriter, req *http.Request, err error) {
+	if err == nil {
+		return
+	}
+	httpStatus, body := r.Render(req, err)
+
+	data, mErr := json.Marshal(body)
+	if mErr != nil {
+		http.Error(w, mErr.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(httpStatus)
+	_, _ = w.Write(data)
+}
+
+// WriteProblem 将错误以RFC 7807 application/problem+json格式写入HTTP响应. title根据请求语言选择,
+// 非调试模式下不返回具体出错信息及服务调用栈. err为nil时不做任何处理.
+func (r *Renderer) WriteProblem(w http.ResponseWriter, req *http.Request, err error) {
+	if err == nil {
+		return
+	}
+	instance := ""
+	if req != nil && req.URL != nil {
+		instance = req.URL.Path
+	}
+	problem := errors.ToProblem(err, instance)
+	if title := problem.Messages[r.Lang(req)]; title != "" {
+		problem.Title = title
+	}
+	if problem.Status == 0 {
+		problem.Status = http.StatusInternalServerError
+	}
+	if !r.debug {
+		problem.Detail = ""
+		problem.Cause = nil
+	}
+
+	data, mErr := json.Marshal(problem)
+	if mErr != nil {
+		http.Error(w, mErr.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", errors.ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	_, _ = w.Write(data)
+}
+
+// HandlerFunc 返回错误的HTTP处理函数.
+type HandlerFunc func(w http.ResponseWriter, req *http.Request) error
+
+// Handler 将返回错误的处理函数转换成http.Handler, 返回的错误写入HTTP响应.
+func (r *Renderer) Handler(fn HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.WriteError(w, req, fn(w, req))
+	})
+}
+
+// Middleware 捕获处理过程中的panic, 记录日志后返回通用的500错误响应, 响应中不包含panic信息.
+func (r *Renderer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer func() {
+			if v := recover(); v != nil {
+				// 中止处理的panic由http.Server处理
+				if v == http.ErrAbortHandler { //nolint:errorlint
+					panic(v)
+				}
+				log.Errorf("http handler panic: %v %s", v, debug.Stack())
+				r.WriteError(w, req, errors.WithCode(errors.Unknown().Code(), http.StatusText(http.StatusInternalServerError)))
+			}
+		}()
+		next.ServeHTTP(w, req)
+	})
+}
+
+var defaultRenderer = NewRenderer()
+
+// WriteError 使用默认渲染器将错误写入HTTP响应.
+func WriteError(w http.ResponseWriter, req *http.Request, err error) {
+	defaultRenderer.WriteError(w, req, err)
+}
+
+// WriteProblem 使用默认渲染器将错误以problem+json格式写入HTTP响应.
+func WriteProblem(w http.ResponseWriter, req *http.Request, err error) {
+	defaultRenderer.WriteProblem(w, req, err)
+}
+
+// Handler 使用默认渲染器转换返回错误的处理函数.
+func Handler(fn HandlerFunc) http.Handler {
+	return defaultRenderer.Handler(fn)
+}
+
+// Middleware 使用默认渲染器捕获panic.
+func Middleware(next http.Handler) http.Handler {
+	return defaultRenderer.Middleware(next)
+}
@@ -0,0 +1,135 @@
+package httperrors_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/wangweihong/gotoolbox/pkg/errors"
+	"github.com/wangweihong/gotoolbox/pkg/errors/httperrors"
+	"github.com/wangweihong/gotoolbox/pkg/httpcli"
+	"github.com/wangweihong/gotoolbox/pkg/httpcli/interceptorcli"
+	"github.com/wangweihong/gotoolbox/pkg/json"
+)
+
+const ErrDeviceNotFound = 110001
+
+func init() {
+	errors.MustRegister(errors.NewCoder(ErrDeviceNotFound, http.StatusNotFound, map[string]string{
+		errors.MessageLangCNKey: "设备不存在",
+		errors.MessageLangENKey: "Device not found",
+	}))
+	errors.UpdateModuleInfo(errors.NewModuleGetter("github.com/wangweihong/gotoolbox", "127.0.0.1", 123))
+}
+
+func serve(h http.Handler, acceptLanguage string) (*httptest.ResponseRecorder, map[string]any) {
+	req := httptest.NewRequest(http.MethodGet, "/devices/d1", nil)
+	if acceptLanguage != "" {
+		req.Header.Set("Accept-Language", acceptLanguage)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	body := map[string]any{}
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+	return w, body
+}
+
+func TestRenderer(t *testing.T) {
+	getDevice := func(w http.ResponseWriter, req *http.Request) error {
+		return errors.WithCode(ErrDeviceNotFound, "device d1 not found")
+	}
+
+	Convey("错误响应", t, func() {
+		Convey("状态码及语言", func() {
+			h := httperrors.Handler(getDevice)
+
+			w, body := serve(h, "")
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+			So(w.Header().Get("Content-Type"), ShouldEqual, "application/json; charset=utf-8")
+			So(body["code"], ShouldEqual, ErrDeviceNotFound)
+			So(body["message"], ShouldEqual, "Device not found")
+			So(body, ShouldNotContainKey, "desc")
+			So(body, ShouldNotContainKey, "stack")
+			So(body, ShouldNotContainKey, "cause")
+
+			_, body = serve(h, "zh-CN,zh;q=0.9,en;q=0.8")
+			So(body["message"], ShouldEqual, "设备不存在")
+
+			_, body = serve(h, "fr-FR,en;q=0.5")
+			So(body["message"], ShouldEqual, "Device not found")
+
+			_, body = serve(httperrors.NewRenderer(httperrors.WithDefaultLang(errors.MessageLangCNKey)).Handler(getDevice), "fr-FR")
+			So(body["message"], ShouldEqual, "设备不存在")
+		})
+
+		Convey("调试模式返回调用栈", func() {
+			h := httperrors.NewRenderer(httperrors.WithDebug(true)).Handler(getDevice)
+			_, body := serve(h, "")
+			So(body["http"], ShouldEqual, http.StatusNotFound)
+			So(body["desc"], ShouldContainSubstring, "device d1 not found")
+			So(body["stack"], ShouldNotBeEmpty)
+			So(body["cause"], ShouldNotBeEmpty)
+		})
+
+		Convey("未注册错误码及panic", func() {
+			w, body := serve(httperrors.Middleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				panic("boom")
+			})), "")
+			So(w.Code, ShouldEqual, http.StatusInternalServerError)
+			So(body["code"], ShouldEqual, errors.Unknown().Code())
+			So(body["message"], ShouldEqual, errors.Unknown().String())
+			So(w.Body.String(), ShouldNotContainSubstring, "boom")
+
+			_, body = serve(httperrors.NewRenderer(httperrors.WithDebug(true)).Middleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				panic("boom")
+			})), "")
+			So(body["desc"], ShouldNotContainSubstring, "boom")
+		})
+
+		Convey("无错误时不写入响应", func() {
+			w, _ := serve(httperrors.Handler(func(w http.ResponseWriter, req *http.Request) error {
+				_, _ = w.Write([]byte("ok"))
+				return nil
+			}), "")
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Body.String(), ShouldEqual, "ok")
+		})
+	})
+}
+
+func TestWriteProblem(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		httperrors.WriteProblem(w, req, errors.WithCode(ErrDeviceNotFound, "device d1 not found"))
+	}))
+	defer server.Close()
+
+	Convey("problem+json错误响应", t, func() {
+		req := httptest.NewRequest(http.MethodGet, "/devices/d1", nil)
+		req.Header.Set("Accept-Language", "zh-CN")
+		w := httptest.NewRecorder()
+		httperrors.WriteProblem(w, req, errors.WithCode(ErrDeviceNotFound, "device d1 not found"))
+		So(w.Code, ShouldEqual, http.StatusNotFound)
+		So(w.Header().Get("Content-Type"), ShouldEqual, errors.ProblemContentType)
+
+		p, err := errors.ParseProblem(w.Body.Bytes())
+		So(err, ShouldBeNil)
+		So(p.Title, ShouldEqual, "设备不存在")
+		So(p.Instance, ShouldEqual, "/devices/d1")
+		So(p.Code, ShouldEqual, ErrDeviceNotFound)
+		So(p.Cause, ShouldBeEmpty)
+		So(p.Detail, ShouldBeEmpty)
+
+		Convey("下游服务的problem响应还原为带错误码的错误", func() {
+			c, err := httpcli.NewClient(nil, httpcli.WithIntercepts(interceptorcli.DecodeResponseInterceptor("decode")))
+			So(err, ShouldBeNil)
+			reply := map[string]any{}
+			_, err = c.Invoke(context.Background(),
+				httpcli.NewHttpRequestBuilder().GET().WithEndpoint(server.URL).WithPath("/devices/d1").Build(), nil, &reply)
+			So(errors.IsCode(err, ErrDeviceNotFound), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,152 @@
+//nolint:errorlint
+package errors
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ProblemContentType RFC 7807 problem details的媒体类型.
+const ProblemContentType = "application/problem+json"
+
+// ProblemTypePrefix 错误码对应的problem type URI前缀. 服务可以修改为自己的错误码文档地址.
+var ProblemTypePrefix = "urn:gotoolbox:errors:"
+
+// Problem RFC 7807 problem details.
+// 除标准成员外, 使用扩展成员code/messages/cause传递错误码、多语言信息及服务调用栈.
+type Problem struct {
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// 扩展成员: 错误码
+	Code int `json:"code,omitempty"`
+	// 扩展成员: 错误码对应的多语言信息
+	Messages map[string]string `json:"messages,omitempty"`
+	// 扩展成员: 服务调用栈
+	Cause []ServiceStack `json:"cause,omitempty"`
+
+	// Extensions 其他扩展成员, 如第三方API定义的成员
+	Extensions map[string]any `json:"-"`
+}
+
+// ToProblem 将错误转换成problem details. instance通常为请求路径.
+func ToProblem(err error, instance string) *Problem {
+	st := ToStatus(err)
+	return &Problem{
+		Type:     ProblemTypePrefix + strconv.Itoa(st.Code),
+		Title:    st.Message[MessageLangENKey],
+		Status:   st.HTTPStatus,
+		Detail:   st.Desc,
+		Instance: instance,
+		Code:     st.Code,
+		Messages: st.Message,
+		Cause:    st.Cause,
+	}
+}
+
+// ParseProblem 解析problem details.
+func ParseProblem(data []byte) (*Problem, error) {
+	p := &Problem{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, WithStack(err)
+	}
+	return p, nil
+}
+
+// Error 将problem details还原成带错误码的错误, 可以通过IsCode判断错误码.
+// 未携带code扩展成员(如第三方API)时, 尝试从type中解析错误码, 失败则为未知错误码.
+func (p *Problem) Error() error {
+	code := p.Code
+	if code == 0 && strings.HasPrefix(p.Type, ProblemTypePrefix) {
+		code, _ = strconv.Atoi(strings.TrimPrefix(p.Type, ProblemTypePrefix))
+	}
+	if code == 0 {
+		code = unknown.code
+	}
+
+	detail := p.Detail
+	if detail == "" {
+		detail = p.Title
+	}
+	if detail == "" {
+		detail = http.StatusText(p.Status)
+	}
+
+	// 保留下游服务的调用栈, 转换成Status时继承
+	st := &status{
+		stack: callers(),
+		code:  code,
+		cause: p.Cause,
+		err:   errors.New(detail),
+	}
+	return &withCode{
+		err:   st,
+		code:  code,
+		stack: st.stack,
+	}
+}
+
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	type plain Problem
+	data, err := json.Marshal((*plain)(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return data, err
+	}
+
+	members := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+	for k, v := range p.Extensions {
+		// 不覆盖已有成员
+		if _, ok := members[k]; ok {
+			continue
+		}
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		members[k] = raw
+	}
+	return json.Marshal(members)
+}
+
+// UnmarshalJSON 类型不匹配的标准成员被忽略(RFC 7807 3.1), 类型不匹配的扩展成员(如第三方API的字符串code)保留在Extensions中.
+func (p *Problem) UnmarshalJSON(data []byte) error {
+	members := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+
+	*p = Problem{}
+	fields := map[string]any{
+		"type": &p.Type, "title": &p.Title, "status": &p.Status, "detail": &p.Detail, "instance": &p.Instance,
+		"code": &p.Code, "messages": &p.Messages, "cause": &p.Cause,
+	}
+	for k, raw := range members {
+		if field, ok := fields[k]; ok {
+			if err := json.Unmarshal(raw, field); err == nil {
+				continue
+			}
+			if k != "code" && k != "messages" && k != "cause" {
+				continue
+			}
+		}
+
+		var v any
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return err
+		}
+		if p.Extensions == nil {
+			p.Extensions = make(map[string]any)
+		}
+		p.Extensions[k] = v
+	}
+	return nil
+}
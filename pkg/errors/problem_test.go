@@ -0,0 +1,60 @@
+package errors_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/wangweihong/gotoolbox/pkg/errors"
+)
+
+func TestProblem(t *testing.T) {
+	Convey("RFC 7807 problem details", t, func() {
+		Convey("编码后还原错误码及服务调用栈", func() {
+			p := errors.ToProblem(errors.NewStatus(ErrCall, "call device service fail"), "/devices/d1")
+			So(p.Type, ShouldEqual, errors.ProblemTypePrefix+"1001")
+			So(p.Title, ShouldEqual, "call error")
+			So(p.Status, ShouldEqual, 500)
+			So(p.Instance, ShouldEqual, "/devices/d1")
+			So(p.Code, ShouldEqual, ErrCall)
+
+			data, err := json.Marshal(p)
+			So(err, ShouldBeNil)
+			got, err := errors.ParseProblem(data)
+			So(err, ShouldBeNil)
+			So(got.Code, ShouldEqual, ErrCall)
+			So(got.Messages, ShouldResemble, p.Messages)
+			So(len(got.Cause), ShouldEqual, 1)
+
+			e := got.Error()
+			So(errors.IsCode(e, ErrCall), ShouldBeTrue)
+			So(errors.IsCode(errors.WithStack(e), ErrCall), ShouldBeTrue)
+			st := errors.ToStatus(e)
+			So(st.Code, ShouldEqual, ErrCall)
+			// 当前服务调用栈 + 下游服务调用栈
+			So(len(st.Cause), ShouldEqual, 2)
+		})
+
+		Convey("第三方API的problem", func() {
+			data := []byte(`{"type":"https://example.com/probs/out-of-credit","title":"You do not have enough credit.",
+				"status":403,"detail":"Your current balance is 30, but that costs 50.","instance":"/account/12345/msgs/abc",
+				"code":"OUT_OF_CREDIT","balance":30}`)
+			p, err := errors.ParseProblem(data)
+			So(err, ShouldBeNil)
+			So(p.Status, ShouldEqual, 403)
+			So(p.Code, ShouldEqual, 0)
+			So(p.Extensions["code"], ShouldEqual, "OUT_OF_CREDIT")
+			So(p.Extensions["balance"], ShouldEqual, 30)
+
+			e := p.Error()
+			So(errors.IsCode(e, errors.Unknown().Code()), ShouldBeTrue)
+			So(e.Error(), ShouldContainSubstring, "Your current balance is 30")
+
+			out, err := json.Marshal(p)
+			So(err, ShouldBeNil)
+			So(string(out), ShouldContainSubstring, `"balance":30`)
+			So(string(out), ShouldContainSubstring, `"code":"OUT_OF_CREDIT"`)
+		})
+	})
+}
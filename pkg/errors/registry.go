@@ -0,0 +1,115 @@
+package errors
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// CodeInfo 注册的错误码信息.
+type CodeInfo struct {
+	Code       int               `json:"code"`
+	HTTPStatus int               `json:"http"`
+	Message    map[string]string `json:"message"`
+	// 注册错误码的包路径
+	Package string `json:"package"`
+}
+
+// Registry 返回所有已注册的错误码, 按错误码排序.
+func Registry() []CodeInfo {
+	codeMux.RLock()
+	defer codeMux.RUnlock()
+
+	infos := make([]CodeInfo, 0, len(codes))
+	for code, coder := range codes {
+		infos = append(infos, CodeInfo{
+			Code:       code,
+			HTTPStatus: coder.HTTPStatus(),
+			Message:    coder.Message(),
+			Package:    codePkgs[code],
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Code < infos[j].Code
+	})
+	return infos
+}
+
+// ExportRegistryJSON 将已注册的错误码以JSON数组格式写入w.
+func ExportRegistryJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(Registry(), "", "  ")
+	if err != nil {
+		return WithStack(err)
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return WithStack(err)
+	}
+	return nil
+}
+
+// ExportRegistryMarkdown 将已注册的错误码以Markdown表格格式写入w.
+func ExportRegistryMarkdown(w io.Writer) error {
+	var buf bytes.Buffer
+	buf.WriteString("| Code | HTTP Code | Description | 中文描述 | Package |\n")
+	buf.WriteString("| ---- | --------- | ----------- | -------- | ------- |\n")
+	for _, info := range Registry() {
+		fmt.Fprintf(&buf, "| %d | %d | %s | %s | %s |\n", info.Code, info.HTTPStatus,
+			escapeCell(info.Message[MessageLangENKey]), escapeCell(info.Message[MessageLangCNKey]), info.Package)
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return WithStack(err)
+	}
+	return nil
+}
+
+func escapeCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+// callerPackage 返回调用注册函数的包路径, 跳过本包内的调用.
+func callerPackage() string {
+	pcs := make([]uintptr, 16)
+	// 跳过runtime.Callers及callerPackage
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	self := selfPackage()
+	for {
+		frame, more := frames.Next()
+		switch pkg := pkgname(frame.Function); pkg {
+		case self:
+		case "runtime":
+			// 本包init中注册
+			return self
+		default:
+			return pkg
+		}
+		if !more {
+			return self
+		}
+	}
+}
+
+// selfPackage 返回本包的包路径.
+func selfPackage() string {
+	pc, _, _, _ := runtime.Caller(0)
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return ""
+	}
+	return pkgname(fn.Name())
+}
+
+// pkgname 从函数全名中提取包路径, 如github.com/a/b.(*T).M返回github.com/a/b.
+func pkgname(name string) string {
+	i := strings.LastIndex(name, "/")
+	j := strings.Index(name[i+1:], ".")
+	if j < 0 {
+		return name
+	}
+	return name[:i+1+j]
+}
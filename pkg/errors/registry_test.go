@@ -0,0 +1,48 @@
+package errors_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/wangweihong/gotoolbox/pkg/errors"
+)
+
+func TestRegistry(t *testing.T) {
+	Convey("导出错误码注册表", t, func() {
+		infos := errors.Registry()
+		So(len(infos), ShouldBeGreaterThanOrEqualTo, 3)
+		So(infos[0].Code, ShouldEqual, errors.Success().Code())
+		So(infos[0].Package, ShouldEqual, "github.com/wangweihong/gotoolbox/pkg/errors")
+		So(infos[1].Code, ShouldEqual, errors.Unknown().Code())
+
+		var call *errors.CodeInfo
+		for i := range infos {
+			if infos[i].Code == ErrCall {
+				call = &infos[i]
+			}
+		}
+		So(call, ShouldNotBeNil)
+		So(call.HTTPStatus, ShouldEqual, 500)
+		So(call.Message[errors.MessageLangENKey], ShouldEqual, "call error")
+		So(call.Package, ShouldEqual, "github.com/wangweihong/gotoolbox/pkg/errors_test")
+
+		Convey("JSON", func() {
+			var buf bytes.Buffer
+			So(errors.ExportRegistryJSON(&buf), ShouldBeNil)
+			var got []errors.CodeInfo
+			So(json.Unmarshal(buf.Bytes(), &got), ShouldBeNil)
+			So(got, ShouldResemble, infos)
+		})
+
+		Convey("Markdown", func() {
+			var buf bytes.Buffer
+			So(errors.ExportRegistryMarkdown(&buf), ShouldBeNil)
+			So(buf.String(), ShouldStartWith, "| Code | HTTP Code |")
+			So(buf.String(), ShouldContainSubstring,
+				"| 1001 | 500 | call error | 请求失败 | github.com/wangweihong/gotoolbox/pkg/errors_test |")
+		})
+	})
+}
@@ -118,6 +118,15 @@ func ToStatus(err error) *Status {
 		st.code = e.code
 		st.cause = e.cause
 
+	// error is generate from WithCode/WrapCode
+	case *withCode:
+		st.stack = e.StackTrace().Stack()
+		st.code = e.code
+		// 继承下游服务的调用栈, 如Problem.Error还原的错误
+		if inner, ok := e.err.(*status); ok {
+			st.cause = inner.cause
+		}
+
 	// error is generate from github.com/pkg/errors
 	case StdStackTracer:
 		st.stack = toStackTrace(e.StackTrace()).Stack()
@@ -210,6 +219,10 @@ func FromError(err error) *status {
 		newStack := MergeStack(callersDepth(-1, 4), e.stack)
 		st.stack = newStack
 		st.code = e.code
+	// error is generate from WithCode/WrapCode
+	case *withCode:
+		st.stack = e.StackTrace().Stack()
+		st.code = e.code
 	// error is generate from github.com/pkg/errors
 	case StdStackTracer:
 		est := toStackTrace(e.StackTrace())
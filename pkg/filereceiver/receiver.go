@@ -1,36 +1,65 @@
 package filereceiver
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 
+	"github.com/wangweihong/gotoolbox/pkg/hash"
 	"github.com/wangweihong/gotoolbox/pkg/mathutil"
 )
 
 const (
+	// 分片接收记录文件后缀, 记录文件中每个bit表示对应分片是否已接收
 	filePartitionExt = ".part"
 
 	PartitionSize1MB = 1024 * 1024
 )
 
 type fileReceiver struct {
+	lock sync.Mutex
 	// 文件路径
 	path string
 	// 文件总大小
 	totalSize int64
 	// 分片大小
 	partitionSize int64
-	// 分片索引 int64
+	// 分片数量
 	partitionIndex int64
 	// 文件是否已经创建
 	fileCreate bool
+	// 已接收分片位图
+	bitmap []byte
+	// 正在写入的分片, 同一分片不允许并发写入
+	writing map[int64]bool
 }
 
 type FileReceiver interface {
+	// Receive 接收指定索引的分片数据. 除最后一个分片外, 分片大小必须等于partitionSize
 	Receive(data []byte, index int64) error
+	// ReceiveFrom 从r中读取指定索引的分片数据并直接写入文件, 无需将分片缓存在内存中.
+	// 数据长度与分片大小不一致时返回错误, 该分片记录为未接收.
+	// 不同分片可以并发接收, 同一分片正在接收时返回错误
+	ReceiveFrom(r io.Reader, index int64) error
+	// PartitionCount 分片数量
+	PartitionCount() int64
+	// Missing 返回尚未接收的分片索引
+	Missing() []int64
+	// Complete 所有分片是否已接收
+	Complete() bool
+	// Verify 校验所有分片已接收且文件摘要与digest(十六进制)一致, 成功后删除分片接收记录.
+	// hasher为nil时不校验摘要.
+	Verify(hasher hash.Hasher, digest string) error
+	// Path 文件路径
+	Path() string
 }
 
+// NewFileReceiver 创建文件接收器.
+// 如果文件及分片接收记录已存在且大小一致, 则从记录中恢复已接收的分片, 以支持重启后续传.
 func NewFileReceiver(dir, filename string, totalSize, partitionSize int64) (FileReceiver, error) {
 	path := filepath.Join(dir, filename)
 
@@ -38,28 +67,110 @@ func NewFileReceiver(dir, filename string, totalSize, partitionSize int64) (File
 		return nil, fmt.Errorf("invalid filepath")
 	}
 
-	if totalSize == 0 {
-		return nil, fmt.Errorf("totalSize cannot be 0")
+	if totalSize <= 0 {
+		return nil, fmt.Errorf("totalSize must be positive")
 	}
 
-	if partitionSize == 0 {
-		return nil, fmt.Errorf("partition Size cannot be 0")
+	if partitionSize <= 0 {
+		return nil, fmt.Errorf("partition Size must be positive")
 	}
 
 	partitionIndex := totalSize / partitionSize
 	if totalSize%partitionSize != 0 {
 		partitionIndex += 1
 	}
-	return &fileReceiver{
+	f := &fileReceiver{
 		path:           path,
 		totalSize:      totalSize,
 		partitionSize:  partitionSize,
 		partitionIndex: partitionIndex,
-	}, nil
+		bitmap:         make([]byte, (partitionIndex+7)/8),
+		writing:        make(map[int64]bool),
+	}
+	f.restore()
+	return f, nil
+}
+
+// restore 从分片接收记录中恢复状态, 记录或文件大小不一致时忽略.
+func (f *fileReceiver) restore() {
+	bitmap, err := os.ReadFile(f.statePath())
+	if err != nil || len(bitmap) != len(f.bitmap) {
+		return
+	}
+
+	fs, err := os.Stat(f.path)
+	if err != nil || fs.Size() != f.totalSize {
+		return
+	}
+
+	f.bitmap = bitmap
+	f.fileCreate = true
+}
+
+func (f *fileReceiver) statePath() string {
+	return f.path + filePartitionExt
+}
+
+func (f *fileReceiver) Path() string {
+	return f.path
+}
+
+func (f *fileReceiver) PartitionCount() int64 {
+	return f.partitionIndex
+}
+
+func (f *fileReceiver) received(index int64) bool {
+	return f.bitmap[index/8]&(1<<(index%8)) != 0
+}
+
+func (f *fileReceiver) Missing() []int64 {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	missing := make([]int64, 0)
+	for i := int64(0); i < f.partitionIndex; i++ {
+		if !f.received(i) {
+			missing = append(missing, i)
+		}
+	}
+	return missing
+}
+
+func (f *fileReceiver) Complete() bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for i := int64(0); i < f.partitionIndex; i++ {
+		if !f.received(i) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *fileReceiver) Verify(hasher hash.Hasher, digest string) error {
+	if !f.Complete() {
+		return fmt.Errorf("file %v is incomplete, %v partitions missing", f.path, len(f.Missing()))
+	}
+
+	if hasher != nil {
+		sum, err := hasher.Sum(f.path)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(sum, digest) {
+			return fmt.Errorf("checksum mismatch for %v, expect %v, actual %v", f.path, digest, sum)
+		}
+	}
+
+	if err := os.Remove(f.statePath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
 }
 
 func (f *fileReceiver) Receive(data []byte, index int64) error {
-	if index > f.partitionIndex {
+	if index < 0 || index >= f.partitionIndex {
 		return fmt.Errorf("invalid partition index")
 	}
 
@@ -68,42 +179,141 @@ func (f *fileReceiver) Receive(data []byte, index int64) error {
 		return fmt.Errorf("data exceed partition size %vk", mathutil.ParseSizeByteToStr(uint64(f.partitionSize)))
 	}
 
-	offset := index * f.partitionSize
-
+	offset, expectLen := f.partition(index)
 	if offset+dataLen > f.totalSize {
 		return fmt.Errorf("exceed file size")
 	}
 
-	var fp *os.File
-	var err error
-	if !f.fileCreate {
-		fp, err = os.Create(f.path)
-		if err != nil {
-			return err
-		}
-		if err := fp.Truncate(f.totalSize); err != nil {
-			return err
-		}
-		f.fileCreate = true
-	} else {
-		fp, err = os.OpenFile(f.path, os.O_RDWR, 0755)
-		if err != nil {
-			return err
+	// 分片大小必须完整, 否则记录为已接收的分片中会存在空洞
+	if dataLen != expectLen {
+		return fmt.Errorf("partition %v size %v not match expect size %v", index, dataLen, expectLen)
+	}
+
+	return f.ReceiveFrom(bytes.NewReader(data), index)
+}
+
+// ReceiveFrom 锁只保护分片状态, 数据在锁外写入, 不同分片的偏移互不重叠.
+func (f *fileReceiver) ReceiveFrom(r io.Reader, index int64) error {
+	if index < 0 || index >= f.partitionIndex {
+		return fmt.Errorf("invalid partition index")
+	}
+	offset, expectLen := f.partition(index)
+
+	fp, err := f.begin(index)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	err = f.write(fp, r, index, offset, expectLen)
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	delete(f.writing, index)
+	if err != nil {
+		return err
+	}
+	return f.setReceived(index, true)
+}
+
+// begin 打开文件并将分片标记为正在写入. 数据写入过程中失败时, 该分片的内容已不可信, 因此先记录为未接收.
+func (f *fileReceiver) begin(index int64) (*os.File, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.writing[index] {
+		return nil, fmt.Errorf("partition %v is being received", index)
+	}
+	fp, err := f.open()
+	if err != nil {
+		return nil, err
+	}
+	if f.received(index) {
+		if err := f.setReceived(index, false); err != nil {
+			fp.Close()
+			return nil, err
 		}
 	}
+	f.writing[index] = true
+	return fp, nil
+}
 
-	if _, err := fp.Seek(offset, 0); err != nil {
+// write 将分片数据写入文件并落盘, 数据落盘后再记录分片, 避免异常退出时记录了未写入的分片.
+func (f *fileReceiver) write(fp *os.File, r io.Reader, index, offset, expectLen int64) error {
+	n, err := io.Copy(io.NewOffsetWriter(fp, offset), io.LimitReader(r, expectLen))
+	if err != nil {
 		return err
 	}
+	// 分片大小必须完整, 否则记录为已接收的分片中会存在空洞
+	if n != expectLen {
+		return fmt.Errorf("partition %v size %v not match expect size %v", index, n, expectLen)
+	}
+	if _, err := io.ReadFull(r, make([]byte, 1)); err == nil {
+		return fmt.Errorf("data exceed partition size %vk", mathutil.ParseSizeByteToStr(uint64(f.partitionSize)))
+	}
+	return fp.Sync()
+}
+
+// partition 返回分片在文件中的偏移及大小.
+func (f *fileReceiver) partition(index int64) (offset, size int64) {
+	offset = index * f.partitionSize
+	size = f.partitionSize
+	if index == f.partitionIndex-1 {
+		size = f.totalSize - offset
+	}
+	return offset, size
+}
+
+// open 打开文件, 首次接收时创建文件.
+func (f *fileReceiver) open() (*os.File, error) {
+	if f.fileCreate {
+		return os.OpenFile(f.path, os.O_RDWR, 0755)
+	}
+
+	// 重新接收时清除旧的分片接收记录
+	if err := os.Remove(f.statePath()); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	fp, err := os.Create(f.path)
+	if err != nil {
+		return nil, err
+	}
+	if err := fp.Truncate(f.totalSize); err != nil {
+		fp.Close()
+		return nil, err
+	}
+	f.fileCreate = true
+	return fp, nil
+}
+
+// setReceived 记录分片是否已接收, 并持久化对应的位图字节.
+func (f *fileReceiver) setReceived(index int64, received bool) error {
+	if received {
+		f.bitmap[index/8] |= 1 << (index % 8)
+	} else {
+		f.bitmap[index/8] &^= 1 << (index % 8)
+	}
 
-	n, err := fp.Write(data)
+	sp, err := os.OpenFile(f.statePath(), os.O_RDWR|os.O_CREATE, 0644)
 	if err != nil {
 		return err
 	}
+	defer sp.Close()
 
-	if int64(n) != dataLen {
-		return fmt.Errorf("write data len no match datalen")
+	fs, err := sp.Stat()
+	if err != nil {
+		return err
+	}
+	// 新建的记录文件需要写入完整位图
+	if fs.Size() != int64(len(f.bitmap)) {
+		if err := sp.Truncate(0); err != nil {
+			return err
+		}
+		_, err = sp.WriteAt(f.bitmap, 0)
+		return err
 	}
 
-	return nil
+	_, err = sp.WriteAt(f.bitmap[index/8:index/8+1], index/8)
+	return err
 }
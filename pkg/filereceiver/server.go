@@ -0,0 +1,340 @@
+package filereceiver
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/wangweihong/gotoolbox/pkg/hash"
+)
+
+// 分片上传协议, 所有请求/响应体均为JSON(分片数据除外):
+//
+//	POST {prefix}/uploads                    初始化上传, 相同uploadId重复初始化时恢复上传. 请求体UploadInitRequest, 响应UploadStatus
+//	GET  {prefix}/uploads/{id}               查询上传状态, 响应UploadStatus
+//	PUT  {prefix}/uploads/{id}/parts/{index} 上传分片, 请求体为分片原始数据
+//	POST {prefix}/uploads/{id}/complete      完成上传并校验摘要. 请求体UploadCompleteRequest, 响应UploadStatus
+const (
+	uploadsPath = "/uploads"
+)
+
+// 服务端默认限制, 避免客户端通过初始化请求占用过多内存或磁盘.
+const (
+	DefaultMaxUploadSize     = 16 * 1024 * 1024 * 1024
+	DefaultMaxPartitionSize  = 64 * 1024 * 1024
+	DefaultMaxPartitionCount = 100000
+	DefaultMaxActiveUploads  = 1000
+)
+
+var uploadIDRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+type UploadInitRequest struct {
+	UploadID      string `json:"uploadId"`
+	FileName      string `json:"fileName"`
+	TotalSize     int64  `json:"totalSize"`
+	PartitionSize int64  `json:"partitionSize"`
+}
+
+type UploadCompleteRequest struct {
+	// 文件摘要(十六进制), 由服务端摘要算法校验
+	Digest string `json:"digest"`
+}
+
+type UploadStatus struct {
+	UploadID       string  `json:"uploadId"`
+	PartitionCount int64   `json:"partitionCount"`
+	Missing        []int64 `json:"missing"`
+	Complete       bool    `json:"complete"`
+}
+
+type uploadErrorResponse struct {
+	Message string `json:"message"`
+}
+
+type upload struct {
+	init     UploadInitRequest
+	receiver FileReceiver
+}
+
+type UploadServerOption func(*UploadServer)
+
+// WithUploadHasher 设置服务端校验文件摘要使用的哈希器, 默认sha256.
+func WithUploadHasher(newHasher func() hash.Hasher) UploadServerOption {
+	return func(s *UploadServer) {
+		s.newHasher = newHasher
+	}
+}
+
+// WithUploadLimits 设置文件最大大小、分片最大大小及最大分片数量, 超出限制的初始化请求返回400.
+// 小于等于0的值使用默认限制.
+func WithUploadLimits(maxTotalSize, maxPartitionSize, maxPartitionCount int64) UploadServerOption {
+	return func(s *UploadServer) {
+		if maxTotalSize > 0 {
+			s.maxTotalSize = maxTotalSize
+		}
+		if maxPartitionSize > 0 {
+			s.maxPartitionSize = maxPartitionSize
+		}
+		if maxPartitionCount > 0 {
+			s.maxPartitionCount = maxPartitionCount
+		}
+	}
+}
+
+// WithUploadMaxActive 设置同时进行(已初始化未完成)的最大上传数, 超出时初始化请求返回429. 小于等于0时使用默认限制.
+func WithUploadMaxActive(n int) UploadServerOption {
+	return func(s *UploadServer) {
+		if n > 0 {
+			s.maxActive = n
+		}
+	}
+}
+
+// WithUploadCompleteHandler 设置文件上传完成并校验通过后的回调.
+func WithUploadCompleteHandler(fn func(uploadID, path string)) UploadServerOption {
+	return func(s *UploadServer) {
+		s.onComplete = fn
+	}
+}
+
+// UploadServer 分片上传服务端, 分片数据保存在{dir}/{uploadId}/{fileName}.
+// 服务重启后, 客户端重新初始化上传即可从分片接收记录中恢复.
+type UploadServer struct {
+	dir        string
+	newHasher  func() hash.Hasher
+	onComplete func(uploadID, path string)
+
+	maxTotalSize      int64
+	maxPartitionSize  int64
+	maxPartitionCount int64
+	maxActive         int
+
+	lock    sync.Mutex
+	uploads map[string]*upload
+	// 正在初始化的上传, 计入同时进行的上传数
+	initializing map[string]bool
+	mux          *http.ServeMux
+}
+
+func NewUploadServer(dir string, opts ...UploadServerOption) *UploadServer {
+	s := &UploadServer{
+		dir: dir,
+		newHasher: func() hash.Hasher {
+			return hash.NewFileStream(sha256.New())
+		},
+		maxTotalSize:      DefaultMaxUploadSize,
+		maxPartitionSize:  DefaultMaxPartitionSize,
+		maxPartitionCount: DefaultMaxPartitionCount,
+		maxActive:         DefaultMaxActiveUploads,
+		uploads:           make(map[string]*upload),
+		initializing:      make(map[string]bool),
+		mux:               http.NewServeMux(),
+	}
+	for _, o := range opts {
+		o(s)
+	}
+
+	s.mux.HandleFunc("POST "+uploadsPath, s.handleInit)
+	s.mux.HandleFunc("GET "+uploadsPath+"/{id}", s.handleStatus)
+	s.mux.HandleFunc("PUT "+uploadsPath+"/{id}/parts/{index}", s.handlePart)
+	s.mux.HandleFunc("POST "+uploadsPath+"/{id}/complete", s.handleComplete)
+	return s
+}
+
+// ServeHTTP 挂载到其他路由时, 使用http.StripPrefix去除前缀.
+func (s *UploadServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *UploadServer) handleInit(w http.ResponseWriter, r *http.Request) {
+	var req UploadInitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeUploadError(w, http.StatusBadRequest, fmt.Errorf("decode request error:%v", err))
+		return
+	}
+	if !uploadIDRegexp.MatchString(req.UploadID) {
+		writeUploadError(w, http.StatusBadRequest, fmt.Errorf("invalid upload id %q", req.UploadID))
+		return
+	}
+	fileName := filepath.Base(req.FileName)
+	if fileName == "." || fileName == ".." || fileName == string(filepath.Separator) {
+		writeUploadError(w, http.StatusBadRequest, fmt.Errorf("invalid file name %q", req.FileName))
+		return
+	}
+	req.FileName = fileName
+	if err := s.checkLimits(req); err != nil {
+		writeUploadError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	u, code, err := s.reserve(req)
+	if err != nil {
+		writeUploadError(w, code, err)
+		return
+	}
+	if u != nil {
+		writeUploadStatus(w, req.UploadID, u.receiver)
+		return
+	}
+
+	// 创建目录及恢复分片接收记录在锁外进行, 避免阻塞其他上传的请求
+	receiver, code, err := s.newReceiver(req)
+
+	s.lock.Lock()
+	delete(s.initializing, req.UploadID)
+	if err == nil {
+		s.uploads[req.UploadID] = &upload{init: req, receiver: receiver}
+	}
+	s.lock.Unlock()
+
+	if err != nil {
+		writeUploadError(w, code, err)
+		return
+	}
+	writeUploadStatus(w, req.UploadID, receiver)
+}
+
+// reserve 返回已存在的上传, 不存在时占用一个上传名额并标记为正在初始化.
+func (s *UploadServer) reserve(req UploadInitRequest) (*upload, int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if u, ok := s.uploads[req.UploadID]; ok {
+		if u.init != req {
+			return nil, http.StatusBadRequest, fmt.Errorf("upload %v exists with different parameters", req.UploadID)
+		}
+		return u, 0, nil
+	}
+	if s.initializing[req.UploadID] {
+		return nil, http.StatusConflict, fmt.Errorf("upload %v is being initialized", req.UploadID)
+	}
+	if len(s.uploads)+len(s.initializing) >= s.maxActive {
+		return nil, http.StatusTooManyRequests, fmt.Errorf("active uploads exceed %v", s.maxActive)
+	}
+	s.initializing[req.UploadID] = true
+	return nil, 0, nil
+}
+
+func (s *UploadServer) newReceiver(req UploadInitRequest) (FileReceiver, int, error) {
+	dir := filepath.Join(s.dir, req.UploadID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	receiver, err := NewFileReceiver(dir, req.FileName, req.TotalSize, req.PartitionSize)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	return receiver, 0, nil
+}
+
+func (s *UploadServer) checkLimits(req UploadInitRequest) error {
+	if req.TotalSize <= 0 || req.TotalSize > s.maxTotalSize {
+		return fmt.Errorf("total size %v out of range (0, %v]", req.TotalSize, s.maxTotalSize)
+	}
+	if req.PartitionSize <= 0 || req.PartitionSize > s.maxPartitionSize {
+		return fmt.Errorf("partition size %v out of range (0, %v]", req.PartitionSize, s.maxPartitionSize)
+	}
+	if count := (req.TotalSize + req.PartitionSize - 1) / req.PartitionSize; count > s.maxPartitionCount {
+		return fmt.Errorf("partition count %v exceed %v", count, s.maxPartitionCount)
+	}
+	return nil
+}
+
+func (s *UploadServer) get(w http.ResponseWriter, r *http.Request) (string, *upload, bool) {
+	id := r.PathValue("id")
+
+	s.lock.Lock()
+	u, ok := s.uploads[id]
+	s.lock.Unlock()
+	if !ok {
+		writeUploadError(w, http.StatusNotFound, fmt.Errorf("upload %v not found", id))
+		return id, nil, false
+	}
+	return id, u, true
+}
+
+func (s *UploadServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	id, u, ok := s.get(w, r)
+	if !ok {
+		return
+	}
+	writeUploadStatus(w, id, u.receiver)
+}
+
+func (s *UploadServer) handlePart(w http.ResponseWriter, r *http.Request) {
+	_, u, ok := s.get(w, r)
+	if !ok {
+		return
+	}
+
+	index, err := strconv.ParseInt(r.PathValue("index"), 10, 64)
+	if err != nil {
+		writeUploadError(w, http.StatusBadRequest, fmt.Errorf("invalid partition index %q", r.PathValue("index")))
+		return
+	}
+
+	if r.ContentLength > u.init.PartitionSize {
+		writeUploadError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("partition data exceed partition size %v", u.init.PartitionSize))
+		return
+	}
+	// 分片数据直接写入文件, 最多读取分片大小+1字节以判断数据是否超长
+	body := http.MaxBytesReader(w, r.Body, u.init.PartitionSize+1)
+	if err := u.receiver.ReceiveFrom(body, index); err != nil {
+		writeUploadError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *UploadServer) handleComplete(w http.ResponseWriter, r *http.Request) {
+	id, u, ok := s.get(w, r)
+	if !ok {
+		return
+	}
+
+	var req UploadCompleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeUploadError(w, http.StatusBadRequest, fmt.Errorf("decode request error:%v", err))
+		return
+	}
+
+	var hasher hash.Hasher
+	if req.Digest != "" {
+		hasher = s.newHasher()
+	}
+	if err := u.receiver.Verify(hasher, req.Digest); err != nil {
+		writeUploadError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.lock.Lock()
+	delete(s.uploads, id)
+	s.lock.Unlock()
+
+	if s.onComplete != nil {
+		s.onComplete(id, u.receiver.Path())
+	}
+	writeUploadStatus(w, id, u.receiver)
+}
+
+func writeUploadStatus(w http.ResponseWriter, id string, receiver FileReceiver) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(UploadStatus{
+		UploadID:       id,
+		PartitionCount: receiver.PartitionCount(),
+		Missing:        receiver.Missing(),
+		Complete:       receiver.Complete(),
+	})
+}
+
+func writeUploadError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(uploadErrorResponse{Message: err.Error()})
+}
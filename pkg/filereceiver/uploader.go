@@ -0,0 +1,223 @@
+package filereceiver
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wangweihong/gotoolbox/pkg/errors"
+	"github.com/wangweihong/gotoolbox/pkg/hash"
+	"github.com/wangweihong/gotoolbox/pkg/httpcli"
+	"github.com/wangweihong/gotoolbox/pkg/wait"
+)
+
+type UploaderOption func(*Uploader)
+
+// WithUploaderPartitionSize 设置分片大小, 默认1MB.
+func WithUploaderPartitionSize(size int64) UploaderOption {
+	return func(u *Uploader) {
+		if size > 0 {
+			u.partitionSize = size
+		}
+	}
+}
+
+// WithUploaderConcurrency 设置并发上传分片数, 默认4.
+func WithUploaderConcurrency(n int) UploaderOption {
+	return func(u *Uploader) {
+		if n > 0 {
+			u.concurrency = n
+		}
+	}
+}
+
+// WithUploaderBackoff 设置分片上传失败时的重试参数, Steps为最大尝试次数.
+func WithUploaderBackoff(backoff wait.Backoff) UploaderOption {
+	return func(u *Uploader) {
+		u.backoff = backoff
+	}
+}
+
+// Uploader 基于httpcli的分片上传客户端, 与UploadServer配合使用.
+// 上传前先向服务端查询缺失的分片, 只上传缺失部分, 因此中断后重新上传同一文件即可续传.
+type Uploader struct {
+	client        *httpcli.Client
+	endpoint      string
+	prefix        string
+	partitionSize int64
+	concurrency   int
+	backoff       wait.Backoff
+}
+
+// NewUploader 创建分片上传客户端. prefix为UploadServer挂载的路径前缀.
+func NewUploader(client *httpcli.Client, endpoint, prefix string, opts ...UploaderOption) *Uploader {
+	u := &Uploader{
+		client:        client,
+		endpoint:      endpoint,
+		prefix:        strings.TrimSuffix(prefix, "/"),
+		partitionSize: PartitionSize1MB,
+		concurrency:   4,
+		backoff: wait.Backoff{
+			Duration: 200 * time.Millisecond,
+			Factor:   2,
+			Jitter:   0.2,
+			Steps:    5,
+			Cap:      10 * time.Second,
+		},
+	}
+	for _, o := range opts {
+		o(u)
+	}
+	return u
+}
+
+// Upload 上传文件, 上传ID由文件摘要生成, 完成后由服务端校验摘要.
+func (u *Uploader) Upload(ctx context.Context, filePath string) (*UploadStatus, error) {
+	fs, err := os.Stat(filePath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	digest, err := hash.NewFileStream(sha256.New()).Sum(filePath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	initReq := UploadInitRequest{
+		UploadID:      digest,
+		FileName:      filepath.Base(filePath),
+		TotalSize:     fs.Size(),
+		PartitionSize: u.partitionSize,
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer file.Close()
+
+	status := &UploadStatus{}
+	if err := u.call(ctx, http.MethodPost, uploadsPath, initReq, status); err != nil {
+		return nil, errors.Wrap(err, "init upload")
+	}
+
+	// 分片全部上传后再次确认服务端状态, 服务端丢失分片时重新上传
+	for round := 0; len(status.Missing) != 0; round++ {
+		if round >= 3 {
+			return status, errors.Errorf("upload %v still missing %d partitions", status.UploadID, len(status.Missing))
+		}
+		if err := u.uploadParts(ctx, file, initReq, status.Missing); err != nil {
+			return status, errors.WithStack(err)
+		}
+		if err := u.call(ctx, http.MethodGet, uploadsPath+"/"+status.UploadID, nil, status); err != nil {
+			return status, errors.Wrap(err, "get upload status")
+		}
+	}
+
+	if err := u.call(ctx, http.MethodPost, uploadsPath+"/"+status.UploadID+"/complete",
+		UploadCompleteRequest{Digest: digest}, status); err != nil {
+		return status, errors.Wrap(err, "complete upload")
+	}
+	return status, nil
+}
+
+// uploadParts 并发上传指定分片.
+func (u *Uploader) uploadParts(ctx context.Context, file *os.File, initReq UploadInitRequest, indexes []int64) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ch := make(chan int64)
+	errCh := make(chan error, len(indexes))
+	wg := sync.WaitGroup{}
+	for i := 0; i < u.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range ch {
+				if err := u.uploadPart(ctx, file, initReq, index); err != nil {
+					errCh <- err
+					// 任一分片最终失败时停止其他分片上传
+					cancel()
+				}
+			}
+		}()
+	}
+
+	for _, index := range indexes {
+		select {
+		case ch <- index:
+		case <-ctx.Done():
+		}
+	}
+	close(ch)
+	wg.Wait()
+	close(errCh)
+
+	errList := make([]error, 0)
+	for err := range errCh {
+		errList = append(errList, err)
+	}
+	return errors.NewAggregate(errList...)
+}
+
+func (u *Uploader) uploadPart(ctx context.Context, file *os.File, initReq UploadInitRequest, index int64) error {
+	offset := index * initReq.PartitionSize
+	size := initReq.PartitionSize
+	if offset+size > initReq.TotalSize {
+		size = initReq.TotalSize - offset
+	}
+
+	data := make([]byte, size)
+	if _, err := file.ReadAt(data, offset); err != nil && err != io.EOF {
+		return errors.WithStack(err)
+	}
+
+	path := fmt.Sprintf("%s/%s/parts/%d", uploadsPath, initReq.UploadID, index)
+	var lastErr error
+	err := wait.ExponentialBackoff(u.backoff, func() (bool, error) {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		lastErr = u.call(ctx, http.MethodPut, path, string(data), nil)
+		return lastErr == nil, nil
+	})
+	if err != nil && lastErr != nil {
+		return errors.Wrapf(lastErr, "upload partition %d", index)
+	}
+	return errors.WithStack(err)
+}
+
+// call 发起请求并将JSON响应解码到reply, 非200状态码返回错误.
+func (u *Uploader) call(ctx context.Context, method, path string, body, reply any) error {
+	builder := httpcli.NewHttpRequestBuilder().
+		WithMethod(method).
+		WithEndpoint(u.endpoint).
+		WithPath(u.prefix + path)
+	if s, ok := body.(string); ok {
+		builder.AddHeaderParam("Content-Type", "application/octet-stream").WithBody("", s)
+	} else if body != nil {
+		builder.AddHeaderParam("Content-Type", "application/json").WithBody("", body)
+	}
+
+	rawResp, err := u.client.Invoke(ctx, builder.Build(), nil, reply)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if rawResp.GetStatusCode() != http.StatusOK {
+		return errors.Errorf("%s %s status code %v, response body:%v", method, path, rawResp.GetStatusCode(), rawResp.GetBody())
+	}
+	if reply != nil {
+		return errors.WithStack(rawResp.Decode(reply))
+	}
+	// 读取响应体以便复用连接
+	rawResp.GetBody()
+	return nil
+}
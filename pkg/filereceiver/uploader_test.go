@@ -0,0 +1,187 @@
+package filereceiver_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/wangweihong/gotoolbox/pkg/filereceiver"
+	"github.com/wangweihong/gotoolbox/pkg/httpcli"
+	"github.com/wangweihong/gotoolbox/pkg/wait"
+)
+
+func TestUploader_Upload(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 1000))
+	src := filepath.Join(t.TempDir(), "image.iso")
+	if err := os.WriteFile(src, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := httpcli.NewClient(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backoff := wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 3}
+
+	Convey("分片上传", t, func() {
+		dir := t.TempDir()
+		var completed string
+		us := filereceiver.NewUploadServer(dir, filereceiver.WithUploadCompleteHandler(func(uploadID, path string) {
+			completed = path
+		}))
+
+		Convey("并发上传分片并校验摘要", func() {
+			server := httptest.NewServer(http.StripPrefix("/api", us))
+			defer server.Close()
+
+			status, err := filereceiver.NewUploader(c, server.URL, "/api",
+				filereceiver.WithUploaderPartitionSize(1024),
+				filereceiver.WithUploaderConcurrency(3),
+				filereceiver.WithUploaderBackoff(backoff)).Upload(context.Background(), src)
+			So(err, ShouldBeNil)
+			So(status.Complete, ShouldBeTrue)
+			So(status.PartitionCount, ShouldEqual, 10)
+
+			data, err := os.ReadFile(completed)
+			So(err, ShouldBeNil)
+			So(data, ShouldResemble, content)
+			_, err = os.Stat(completed + ".part")
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+
+		Convey("分片失败时重试, 中断后只上传缺失分片", func() {
+			var fails, parts int32
+			failing := int32(1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPut {
+					// 分片5始终失败直到恢复, 其他分片首次失败一次
+					if strings.HasSuffix(r.URL.Path, "/parts/5") && atomic.LoadInt32(&failing) == 1 {
+						w.WriteHeader(http.StatusServiceUnavailable)
+						return
+					}
+					if atomic.AddInt32(&fails, 1)%2 == 1 {
+						w.WriteHeader(http.StatusServiceUnavailable)
+						return
+					}
+					atomic.AddInt32(&parts, 1)
+				}
+				us.ServeHTTP(w, r)
+			}))
+			defer server.Close()
+
+			uploader := filereceiver.NewUploader(c, server.URL, "",
+				filereceiver.WithUploaderPartitionSize(1024),
+				filereceiver.WithUploaderConcurrency(1),
+				filereceiver.WithUploaderBackoff(backoff))
+			_, err := uploader.Upload(context.Background(), src)
+			So(err, ShouldNotBeNil)
+			So(completed, ShouldBeEmpty)
+
+			uploaded := atomic.LoadInt32(&parts)
+			So(uploaded, ShouldBeGreaterThan, 0)
+			So(uploaded, ShouldBeLessThan, 10)
+
+			atomic.StoreInt32(&failing, 0)
+			status, err := uploader.Upload(context.Background(), src)
+			So(err, ShouldBeNil)
+			So(status.Complete, ShouldBeTrue)
+			So(atomic.LoadInt32(&parts), ShouldEqual, 10)
+
+			data, err := os.ReadFile(completed)
+			So(err, ShouldBeNil)
+			So(data, ShouldResemble, content)
+		})
+
+		Convey("超出服务端限制的请求被拒绝", func() {
+			limited := filereceiver.NewUploadServer(dir, filereceiver.WithUploadLimits(4096, 1024, 2))
+			server := httptest.NewServer(limited)
+			defer server.Close()
+
+			post := func(body string) int {
+				resp, err := http.Post(server.URL+"/uploads", "application/json", strings.NewReader(body))
+				So(err, ShouldBeNil)
+				resp.Body.Close()
+				return resp.StatusCode
+			}
+			So(post(`{"uploadId":"a","fileName":"f","totalSize":8192,"partitionSize":1024}`), ShouldEqual, http.StatusBadRequest)
+			So(post(`{"uploadId":"a","fileName":"f","totalSize":2048,"partitionSize":2048}`), ShouldEqual, http.StatusBadRequest)
+			So(post(`{"uploadId":"a","fileName":"f","totalSize":3072,"partitionSize":1024}`), ShouldEqual, http.StatusBadRequest)
+			So(post(`{"uploadId":"a","fileName":"f","totalSize":-1,"partitionSize":1024}`), ShouldEqual, http.StatusBadRequest)
+			So(post(`{"uploadId":"a","fileName":"f","totalSize":2048,"partitionSize":1024}`), ShouldEqual, http.StatusOK)
+
+			put := func(index string, data string) int {
+				req, err := http.NewRequest(http.MethodPut, server.URL+"/uploads/a/parts/"+index, strings.NewReader(data))
+				So(err, ShouldBeNil)
+				resp, err := http.DefaultClient.Do(req)
+				So(err, ShouldBeNil)
+				resp.Body.Close()
+				return resp.StatusCode
+			}
+			So(put("0", strings.Repeat("x", 2048)), ShouldEqual, http.StatusRequestEntityTooLarge)
+			So(put("0", strings.Repeat("x", 1023)), ShouldEqual, http.StatusBadRequest)
+			So(put("0", strings.Repeat("x", 1024)), ShouldEqual, http.StatusOK)
+		})
+
+		Convey("限制同时进行的上传数", func() {
+			server := httptest.NewServer(filereceiver.NewUploadServer(dir, filereceiver.WithUploadMaxActive(1)))
+			defer server.Close()
+
+			post := func(id string) int {
+				body := `{"uploadId":"` + id + `","fileName":"f","totalSize":2048,"partitionSize":1024}`
+				resp, err := http.Post(server.URL+"/uploads", "application/json", strings.NewReader(body))
+				So(err, ShouldBeNil)
+				resp.Body.Close()
+				return resp.StatusCode
+			}
+			So(post("a"), ShouldEqual, http.StatusOK)
+			So(post("b"), ShouldEqual, http.StatusTooManyRequests)
+			So(post("a"), ShouldEqual, http.StatusOK)
+		})
+
+		Convey("不同分片并发接收", func() {
+			fr, err := filereceiver.NewFileReceiver(dir, "image.iso", int64(len(content)), 1024)
+			So(err, ShouldBeNil)
+
+			// 分片0的数据未写完时, 其他分片的接收不被阻塞
+			pr, pw := io.Pipe()
+			done := make(chan error, 1)
+			go func() { done <- fr.ReceiveFrom(pr, 0) }()
+			_, err = pw.Write(content[:512])
+			So(err, ShouldBeNil)
+
+			So(fr.Receive(content[1024:2048], 1), ShouldBeNil)
+			So(fr.Receive(content[:1024], 0), ShouldNotBeNil)
+			So(fr.Missing(), ShouldNotContain, int64(1))
+			So(fr.Missing(), ShouldContain, int64(0))
+
+			_, err = pw.Write(content[512:1024])
+			So(err, ShouldBeNil)
+			So(pw.Close(), ShouldBeNil)
+			So(<-done, ShouldBeNil)
+			So(fr.Missing(), ShouldNotContain, int64(0))
+		})
+
+		Convey("服务重启后从分片接收记录恢复", func() {
+			fr, err := filereceiver.NewFileReceiver(dir, "image.iso", int64(len(content)), 1024)
+			So(err, ShouldBeNil)
+			So(fr.Receive(content[:1024], 0), ShouldBeNil)
+			So(fr.Receive(content[9*1024:], 9), ShouldBeNil)
+			So(fr.Receive(content[:10], 1), ShouldNotBeNil)
+			So(fr.ReceiveFrom(strings.NewReader(string(content[:1025])), 2), ShouldNotBeNil)
+
+			restored, err := filereceiver.NewFileReceiver(dir, "image.iso", int64(len(content)), 1024)
+			So(err, ShouldBeNil)
+			So(restored.Missing(), ShouldResemble, []int64{1, 2, 3, 4, 5, 6, 7, 8})
+			So(restored.Verify(nil, ""), ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,305 @@
+package httpcli
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wangweihong/gotoolbox/pkg/clock"
+	"github.com/wangweihong/gotoolbox/pkg/errors"
+	"github.com/wangweihong/gotoolbox/pkg/sets"
+)
+
+// BalancerEndpoint 负载均衡的后端地址.
+type BalancerEndpoint struct {
+	address  string
+	inFlight int64
+
+	lock sync.Mutex
+	// 连续失败次数
+	failures int
+	// 被摘除直到该时间
+	ejectedUntil time.Time
+}
+
+// Address 后端地址, 如http://192.168.1.10:8080.
+func (e *BalancerEndpoint) Address() string {
+	return e.address
+}
+
+// InFlight 正在处理的请求数.
+func (e *BalancerEndpoint) InFlight() int64 {
+	return atomic.LoadInt64(&e.inFlight)
+}
+
+func (e *BalancerEndpoint) healthy(now time.Time) bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return !now.Before(e.ejectedUntil)
+}
+
+// Picker 从候选后端中选择一个. candidates非空.
+type Picker interface {
+	Pick(req *HttpRequest, candidates []*BalancerEndpoint) *BalancerEndpoint
+}
+
+type PickerFunc func(req *HttpRequest, candidates []*BalancerEndpoint) *BalancerEndpoint
+
+func (f PickerFunc) Pick(req *HttpRequest, candidates []*BalancerEndpoint) *BalancerEndpoint {
+	return f(req, candidates)
+}
+
+// RoundRobinPicker 轮询.
+func RoundRobinPicker() Picker {
+	var next uint64
+	return PickerFunc(func(req *HttpRequest, candidates []*BalancerEndpoint) *BalancerEndpoint {
+		n := atomic.AddUint64(&next, 1) - 1
+		return candidates[n%uint64(len(candidates))]
+	})
+}
+
+// RandomPicker 随机.
+func RandomPicker() Picker {
+	return PickerFunc(func(req *HttpRequest, candidates []*BalancerEndpoint) *BalancerEndpoint {
+		return candidates[rand.Intn(len(candidates))] //nolint:gosec
+	})
+}
+
+// LeastInFlightPicker 选择正在处理请求数最少的后端, 相同时选择靠前的后端.
+func LeastInFlightPicker() Picker {
+	return PickerFunc(func(req *HttpRequest, candidates []*BalancerEndpoint) *BalancerEndpoint {
+		picked := candidates[0]
+		for _, e := range candidates[1:] {
+			if e.InFlight() < picked.InFlight() {
+				picked = e
+			}
+		}
+		return picked
+	})
+}
+
+// ConsistentHashPicker 按请求键一致性哈希(rendezvous hashing), 相同键的请求总是落到同一个健康后端,
+// 后端摘除或恢复时只影响该后端上的键.
+// keyFunc为空时使用HttpRequestBuilder.WithBalanceKey设置的键, 未设置时使用请求路径.
+func ConsistentHashPicker(keyFunc func(req *HttpRequest) string) Picker {
+	if keyFunc == nil {
+		keyFunc = func(req *HttpRequest) string {
+			if key := req.GetBalanceKey(); key != "" {
+				return key
+			}
+			return req.GetPath()
+		}
+	}
+	return PickerFunc(func(req *HttpRequest, candidates []*BalancerEndpoint) *BalancerEndpoint {
+		key := keyFunc(req)
+		var picked *BalancerEndpoint
+		var max uint64
+		for _, e := range candidates {
+			h := fnv.New64a()
+			_, _ = h.Write([]byte(key))
+			_, _ = h.Write([]byte{0})
+			_, _ = h.Write([]byte(e.address))
+			if sum := h.Sum64(); picked == nil || sum > max {
+				picked, max = e, sum
+			}
+		}
+		return picked
+	})
+}
+
+// BalancerPolicy 负载均衡策略.
+type BalancerPolicy struct {
+	// 后端选择策略, 默认轮询
+	Picker Picker
+	// 连续失败次数达到该值时摘除后端, 0表示不摘除
+	FailureThreshold int
+	// 摘除时间, 超过后恢复使用
+	EjectDuration time.Duration
+	// 单个请求最多尝试的后端数(包含第一个), 0表示尝试所有后端
+	MaxAttempts int
+	// 判定请求是否失败并切换到下一个后端. 默认连接错误或502/503/504状态码
+	ShouldFailover func(rawResp *HttpResponse, err error) bool
+}
+
+// DefaultBalancerPolicy 默认负载均衡策略: 轮询, 连续失败3次摘除30s, 失败时依次尝试所有后端.
+func DefaultBalancerPolicy() *BalancerPolicy {
+	return &BalancerPolicy{
+		Picker:           RoundRobinPicker(),
+		FailureThreshold: 3,
+		EjectDuration:    30 * time.Second,
+		ShouldFailover:   defaultShouldFailover,
+	}
+}
+
+func defaultShouldFailover(rawResp *HttpResponse, err error) bool {
+	if err != nil {
+		return true
+	}
+	if rawResp == nil || rawResp.Response == nil {
+		return false
+	}
+	switch rawResp.GetStatusCode() {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// 幂等请求方法, 允许切换后端重新发送.
+var failoverMethods = sets.NewString(
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodOptions,
+	http.MethodTrace,
+	http.MethodPut,
+	http.MethodDelete,
+)
+
+// NewBalancer 创建客户端负载均衡器, 通过WithBalancer设置到客户端.
+func NewBalancer(endpoints []string, policy *BalancerPolicy) (*Balancer, error) {
+	return NewBalancerWithClock(endpoints, policy, clock.RealClock{})
+}
+
+func NewBalancerWithClock(endpoints []string, policy *BalancerPolicy, c clock.PassiveClock) (*Balancer, error) {
+	if len(endpoints) == 0 {
+		return nil, errors.New("balancer endpoints is empty")
+	}
+	if policy == nil {
+		policy = DefaultBalancerPolicy()
+	}
+	p := *policy
+	if p.Picker == nil {
+		p.Picker = RoundRobinPicker()
+	}
+	if p.ShouldFailover == nil {
+		p.ShouldFailover = defaultShouldFailover
+	}
+
+	b := &Balancer{policy: p, clock: c}
+	seen := sets.NewString()
+	for _, endpoint := range endpoints {
+		if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+			endpoint = "http://" + endpoint
+		}
+		if seen.Has(endpoint) {
+			continue
+		}
+		seen.Insert(endpoint)
+		b.endpoints = append(b.endpoints, &BalancerEndpoint{address: endpoint})
+	}
+	return b, nil
+}
+
+// Balancer 客户端负载均衡器. 按策略选择后端, 被动标记失败后端, 并在可重试错误时切换到下一个后端.
+type Balancer struct {
+	policy    BalancerPolicy
+	clock     clock.PassiveClock
+	endpoints []*BalancerEndpoint
+}
+
+// Endpoints 返回所有后端.
+func (b *Balancer) Endpoints() []*BalancerEndpoint {
+	return b.endpoints
+}
+
+// IsHealthy 后端当前是否可用(未被摘除).
+func (b *Balancer) IsHealthy(address string) bool {
+	for _, e := range b.endpoints {
+		if e.address == address {
+			return e.healthy(b.clock.Now())
+		}
+	}
+	return false
+}
+
+// pick 从未尝试过的后端中选择, 优先选择健康后端; 所有后端都被摘除时仍然尝试, 避免全部不可用.
+func (b *Balancer) pick(req *HttpRequest, tried map[*BalancerEndpoint]bool) *BalancerEndpoint {
+	now := b.clock.Now()
+	var healthy, ejected []*BalancerEndpoint
+	for _, e := range b.endpoints {
+		if tried[e] {
+			continue
+		}
+		if e.healthy(now) {
+			healthy = append(healthy, e)
+		} else {
+			ejected = append(ejected, e)
+		}
+	}
+	if len(healthy) != 0 {
+		return b.policy.Picker.Pick(req, healthy)
+	}
+	if len(ejected) != 0 {
+		return b.policy.Picker.Pick(req, ejected)
+	}
+	return nil
+}
+
+func (b *Balancer) done(e *BalancerEndpoint, failed bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if !failed {
+		e.failures = 0
+		e.ejectedUntil = time.Time{}
+		return
+	}
+	e.failures++
+	if b.policy.FailureThreshold > 0 && e.failures >= b.policy.FailureThreshold {
+		e.ejectedUntil = b.clock.Now().Add(b.policy.EjectDuration)
+	}
+}
+
+// invoker 包装最终调用. 请求未指定endpoint时由负载均衡器选择后端;
+// 失败时如果请求幂等(或显式允许重试)且请求体可以重复读取, 切换到下一个后端.
+func (b *Balancer) invoker(base Invoker) Invoker {
+	return func(ctx context.Context, req *HttpRequest, arg, reply any, cc *Client, opts ...CallOption) (*HttpResponse, error) {
+		if req.GetEndpoint() != "" {
+			return base(ctx, req, arg, reply, cc, opts...)
+		}
+
+		canFailover := req.IsBodyRewindable() && (req.IsRetryable() || failoverMethods.Has(strings.ToUpper(req.GetMethod())))
+		maxAttempts := b.policy.MaxAttempts
+		if maxAttempts <= 0 || maxAttempts > len(b.endpoints) {
+			maxAttempts = len(b.endpoints)
+		}
+
+		tried := make(map[*BalancerEndpoint]bool, maxAttempts)
+		for attempt := 1; ; attempt++ {
+			e := b.pick(req, tried)
+			tried[e] = true
+
+			// 复制请求并设置后端地址, 不修改调用方的请求
+			r := *req
+			r.endpoint = e.address
+
+			atomic.AddInt64(&e.inFlight, 1)
+			rawResp, err := base(ctx, &r, arg, reply, cc, opts...)
+			atomic.AddInt64(&e.inFlight, -1)
+
+			// 调用方主动撤销的请求不计入统计
+			if ctx.Err() != nil {
+				return rawResp, errors.WithStack(err)
+			}
+			failed := b.policy.ShouldFailover(rawResp, err)
+			b.done(e, failed)
+			if !failed || !canFailover || attempt >= maxAttempts {
+				return rawResp, errors.WithStack(err)
+			}
+
+			logInfoIf(ctx, fmt.Sprintf("balancer failover %s %s from %s, attempt %d/%d, err:%v",
+				req.GetMethod(), req.GetPath(), e.address, attempt, maxAttempts, err))
+			if rawResp != nil && rawResp.Response != nil && rawResp.Response.Body != nil {
+				_, _ = io.Copy(io.Discard, io.LimitReader(rawResp.Response.Body, 4096))
+				_ = rawResp.Response.Body.Close()
+			}
+		}
+	}
+}
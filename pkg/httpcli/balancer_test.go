@@ -0,0 +1,115 @@
+package httpcli_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/wangweihong/gotoolbox/pkg/clock"
+	"github.com/wangweihong/gotoolbox/pkg/httpcli"
+)
+
+func newNamedServer(name string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(name))
+	}))
+}
+
+func TestBalancer(t *testing.T) {
+	Convey("客户端负载均衡", t, func() {
+		nodeA := newNamedServer("a")
+		defer nodeA.Close()
+		nodeB := newNamedServer("b")
+		defer nodeB.Close()
+
+		invoke := func(c *httpcli.Client, builder *httpcli.HttpRequestBuilder) (string, error) {
+			rawResp, err := c.Invoke(context.Background(), builder.Build(), nil, nil)
+			if err != nil {
+				return "", err
+			}
+			return rawResp.GetBody(), nil
+		}
+
+		Convey("轮询", func() {
+			b, err := httpcli.NewBalancer([]string{nodeA.URL, nodeB.URL}, nil)
+			So(err, ShouldBeNil)
+			c, err := httpcli.NewClient(nil, httpcli.WithBalancer(b))
+			So(err, ShouldBeNil)
+
+			var got []string
+			for i := 0; i < 4; i++ {
+				body, err := invoke(c, httpcli.NewHttpRequestBuilder().GET().WithPath("/"))
+				So(err, ShouldBeNil)
+				got = append(got, body)
+			}
+			So(got, ShouldResemble, []string{"a", "b", "a", "b"})
+
+			// 指定endpoint的请求不经过负载均衡
+			body, err := invoke(c, httpcli.NewHttpRequestBuilder().GET().WithEndpoint(nodeB.URL).WithPath("/"))
+			So(err, ShouldBeNil)
+			So(body, ShouldEqual, "b")
+		})
+
+		Convey("一致性哈希", func() {
+			b, err := httpcli.NewBalancer([]string{nodeA.URL, nodeB.URL}, &httpcli.BalancerPolicy{
+				Picker: httpcli.ConsistentHashPicker(nil),
+			})
+			So(err, ShouldBeNil)
+			c, err := httpcli.NewClient(nil, httpcli.WithBalancer(b))
+			So(err, ShouldBeNil)
+
+			for _, key := range []string{"device-1", "device-2", "device-3"} {
+				first, err := invoke(c, httpcli.NewHttpRequestBuilder().GET().WithPath("/").WithBalanceKey(key))
+				So(err, ShouldBeNil)
+				for i := 0; i < 3; i++ {
+					body, err := invoke(c, httpcli.NewHttpRequestBuilder().GET().WithPath("/").WithBalanceKey(key))
+					So(err, ShouldBeNil)
+					So(body, ShouldEqual, first)
+				}
+			}
+		})
+
+		Convey("故障切换及被动健康标记", func() {
+			down := newNamedServer("down")
+			downURL := down.URL
+			down.Close()
+
+			fakeClock := clock.NewFakePassiveClock(time.Now())
+			// 总是优先选择第一个后端
+			first := httpcli.PickerFunc(func(req *httpcli.HttpRequest, candidates []*httpcli.BalancerEndpoint) *httpcli.BalancerEndpoint {
+				return candidates[0]
+			})
+			b, err := httpcli.NewBalancerWithClock([]string{downURL, nodeA.URL}, &httpcli.BalancerPolicy{
+				Picker:           first,
+				FailureThreshold: 2,
+				EjectDuration:    time.Minute,
+			}, fakeClock)
+			So(err, ShouldBeNil)
+			c, err := httpcli.NewClient(nil, httpcli.WithBalancer(b))
+			So(err, ShouldBeNil)
+
+			for i := 0; i < 4; i++ {
+				body, err := invoke(c, httpcli.NewHttpRequestBuilder().GET().WithPath("/"))
+				So(err, ShouldBeNil)
+				So(body, ShouldEqual, "a")
+			}
+			So(b.IsHealthy(downURL), ShouldBeFalse)
+			So(b.IsHealthy(nodeA.URL), ShouldBeTrue)
+
+			// 非幂等请求不切换后端
+			fakeClock.SetTime(fakeClock.Now().Add(time.Minute))
+			So(b.IsHealthy(downURL), ShouldBeTrue)
+			_, err = invoke(c, httpcli.NewHttpRequestBuilder().POST().WithPath("/").WithBody("", "data"))
+			So(err, ShouldNotBeNil)
+
+			// 显式允许重试的非幂等请求切换后端
+			body, err := invoke(c, httpcli.NewHttpRequestBuilder().POST().WithPath("/").WithBody("", "data").WithRetryable(true))
+			So(err, ShouldBeNil)
+			So(body, ShouldEqual, "a")
+		})
+	})
+}
@@ -65,6 +65,7 @@ func (builder *HttpRequestBuilder) WithMethod(method string) *HttpRequestBuilder
 	return builder
 }
 
+// AddQueryParam 添加查询参数, value为[]string时按多个同名参数发送, 如a=1&a=2.
 func (builder *HttpRequestBuilder) AddQueryParam(key string, value any) *HttpRequestBuilder {
 	builder.httpRequest.queryParams[key] = value
 	return builder
@@ -183,6 +184,16 @@ func (builder *HttpRequestBuilder) AddHeaderParam(key string, value string) *Htt
 	return builder
 }
 
+func (builder *HttpRequestBuilder) SetHeaderParam(key string, value string) *HttpRequestBuilder {
+	builder.httpRequest.headerParams.Set(key, value)
+	return builder
+}
+
+func (builder *HttpRequestBuilder) DelHeaderParam(key string) *HttpRequestBuilder {
+	builder.httpRequest.headerParams.Del(key)
+	return builder
+}
+
 func (builder *HttpRequestBuilder) AddBasicAuthHeaderParam(user string, password string) *HttpRequestBuilder {
 	auth := user + ":" + password
 	authEncoded := base64.StdEncoding.EncodeToString([]byte(auth))
@@ -190,6 +201,7 @@ func (builder *HttpRequestBuilder) AddBasicAuthHeaderParam(user string, password
 	return builder
 }
 
+// AddTokenAuthHeaderParam 设置Bearer令牌. 需要自动获取和刷新OAuth2令牌时使用interceptorcli.OAuth2Interceptor.
 func (builder *HttpRequestBuilder) AddTokenAuthHeaderParam(token string) *HttpRequestBuilder {
 	builder.AddHeaderParam("Authorization", "Bearer "+token)
 	return builder
@@ -350,6 +362,19 @@ func (builder *HttpRequestBuilder) WithTimeout(t time.Duration) *HttpRequestBuil
 	return builder
 }
 
+// WithRetryable 允许非幂等请求(如POST/PATCH)被重试拦截器重试.
+// 调用方需确保服务端能够处理重复请求.
+func (builder *HttpRequestBuilder) WithRetryable(retryable bool) *HttpRequestBuilder {
+	builder.httpRequest.retryable = retryable
+	return builder
+}
+
+// WithBalanceKey 设置一致性哈希负载均衡使用的键, 相同键的请求落到同一个后端.
+func (builder *HttpRequestBuilder) WithBalanceKey(key string) *HttpRequestBuilder {
+	builder.httpRequest.balanceKey = key
+	return builder
+}
+
 func (builder *HttpRequestBuilder) Build() *HttpRequest {
 	return builder.httpRequest.fillParamsInPath()
 }
@@ -110,3 +110,12 @@ func TestHttpRequestBuilder_AddPathParamByObjectEmbedded(t *testing.T) {
 		})
 	})
 }
+
+func TestHttpRequestBuilder_AddQueryParamValues(t *testing.T) {
+	Convey("[]string查询参数按多个同名参数发送", t, func() {
+		req, err := httpcli.NewHttpRequestBuilder().GET().WithEndpoint("http://127.0.0.1").WithPath("/items").
+			AddQueryParam("tag", []string{"a", "b"}).Build().ConvertRequest()
+		So(err, ShouldBeNil)
+		So(req.URL.Query()["tag"], ShouldResemble, []string{"a", "b"})
+	})
+}
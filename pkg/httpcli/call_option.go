@@ -12,6 +12,9 @@ type CallInfo struct {
 	timeout            time.Duration
 	httpRequestProcess func(req *http.Request) (*http.Request, error)
 	httpTransport      *http.Transport
+	roundTripper       http.RoundTripper
+	// 追加的请求处理, 在httpRequestProcess及客户端请求处理之后按顺序执行
+	httpRequestProcessors []ProcessRequestFunc
 	// 拦截器列表
 	chainInterceptors []Interceptor
 
@@ -78,6 +81,14 @@ func CallOptionHttpRequestProcess(fun ProcessRequestFunc) CallOption {
 	}
 }
 
+// CallOptionAddHttpRequestProcess 追加http请求发起调用前的处理, 不会覆盖CallOptionHttpRequestProcess设置的处理.
+// 用于拦截器对最终的http请求进行处理, 如请求签名.
+func CallOptionAddHttpRequestProcess(fun ProcessRequestFunc) CallOption {
+	return func(c *CallInfo) {
+		c.httpRequestProcessors = append(c.httpRequestProcessors, fun)
+	}
+}
+
 // CallOptionTransport 通用请求选项.
 func CallOptionTransport(tp *http.Transport) CallOption {
 	return func(c *CallInfo) {
@@ -85,6 +96,13 @@ func CallOptionTransport(tp *http.Transport) CallOption {
 	}
 }
 
+// CallOptionRoundTripper 使用自定义传输层发起本次请求, 如recorder.Recorder.
+func CallOptionRoundTripper(rt http.RoundTripper) CallOption {
+	return func(c *CallInfo) {
+		c.roundTripper = rt
+	}
+}
+
 // CallOptionProxy 请求代理选项.
 // WithProxy(http.ProxyFromEnvironment).
 func CallOptionProxy(proxy func(*http.Request) (*url.URL, error)) CallOption {
@@ -17,6 +17,7 @@ type Client struct {
 	conn              *http.Client
 	config            httpconfig.HttpConfig
 	chainInterceptors []Interceptor
+	balancer          *Balancer
 }
 
 func NewClient(cfg *httpconfig.HttpConfig, options ...Option) (*Client, error) {
@@ -48,6 +49,9 @@ func NewClient(cfg *httpconfig.HttpConfig, options ...Option) (*Client, error) {
 		Transport: tr,
 		Timeout:   c.config.Timeout,
 	}
+	if c.config.RoundTripper != nil {
+		c.conn.Transport = c.config.RoundTripper
+	}
 
 	if c.config.RecordCookies {
 		jar, err := cookiejar.New(nil)
@@ -93,31 +97,51 @@ func (c *Client) Invoke(
 		}()
 	}
 
+	// 文件请求体在重试等所有尝试结束后关闭
+	defer req.closeStreamBody()
+
+	rawResp, err = c.invokeChain(ctx, req, arg, reply, invokeLogWrapper, opts...)
+	return rawResp, errors.WithStack(err)
+}
+
+// invokeChain 依次调用拦截器链, 最后调用finalInvoker.
+func (c *Client) invokeChain(
+	ctx context.Context,
+	req *HttpRequest,
+	arg, reply any,
+	finalInvoker Invoker,
+	opts ...CallOption,
+) (*HttpResponse, error) {
 	ci := &CallInfo{}
 	for _, o := range opts {
 		o(ci)
 	}
 
+	// 负载均衡在拦截器链之后, 每次后端切换都是一次真正的请求
+	if c.balancer != nil {
+		finalInvoker = c.balancer.invoker(finalInvoker)
+	}
+
 	//  允许特定请求单独设置拦截器来覆盖客户端的拦截器
 	chainInterceptors := c.chainInterceptors
 	if ci.chainInterceptors != nil {
 		chainInterceptors = ci.chainInterceptors
 	}
 
-	if chainInterceptors != nil {
-		rawResp, err = chainInterceptors[0].Intercept(
+	if len(chainInterceptors) != 0 {
+		rawResp, err := chainInterceptors[0].Intercept(
 			ctx,
 			req,
 			arg,
 			reply,
 			c,
-			getChainUnaryInvoker(chainInterceptors, 0, invokeLogWrapper),
+			getChainUnaryInvoker(chainInterceptors, 0, finalInvoker),
 			opts...)
 
 		return rawResp, errors.WithStack(err)
 	}
 
-	rawResp, err = invokeLogWrapper(ctx, req, arg, reply, c, opts...)
+	rawResp, err := finalInvoker(ctx, req, arg, reply, c, opts...)
 	return rawResp, errors.WithStack(err)
 }
 
@@ -191,7 +215,14 @@ func invoke(
 		return nil, errors.WithStack(err)
 	}
 
-	resp, err := c.conn.Do(httpReq)
+	conn := c.conn
+	if ci.roundTripper != nil {
+		cc := *c.conn
+		cc.Transport = ci.roundTripper
+		conn = &cc
+	}
+
+	resp, err := conn.Do(httpReq)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -211,10 +242,21 @@ func (c *Client) preRequestProcess(req *http.Request, info *CallInfo) error {
 		}
 	}
 
-	if c.config.HttpHandler == nil || c.config.HttpHandler.RequestHandlers == nil || req == nil {
-		return nil
+	if c.config.HttpHandler != nil && c.config.HttpHandler.RequestHandlers != nil && req != nil {
+		if err := c.config.HttpHandler.RequestHandlers(req); err != nil {
+			return err
+		}
+	}
+
+	// 追加的处理(如请求签名)最后执行, 以包含之前对请求的所有修改
+	if info != nil {
+		for _, fun := range info.httpRequestProcessors {
+			if _, err := fun(req); err != nil {
+				return errors.Wrap(err, "process request before invoke fail")
+			}
+		}
 	}
-	return c.config.HttpHandler.RequestHandlers(req)
+	return nil
 }
 
 func (c *Client) postRequestProcess(resp *http.Response, info *CallInfo) error {
@@ -11,6 +11,7 @@ import (
 	"github.com/wangweihong/gotoolbox/pkg/json"
 
 	"github.com/wangweihong/gotoolbox/pkg/maputil"
+	"github.com/wangweihong/gotoolbox/pkg/redact"
 )
 
 func logEnabled() bool {
@@ -31,7 +32,9 @@ func logInfoIf(ctx context.Context, msg string) {
 	}
 }
 
+// callEntry 调用信息. 请求头、请求及回应体按redact.Default()脱敏.
 func callEntry(start time.Time, req *HttpRequest, rawResp *HttpResponse, arg, reply any, err error) maputil.StringAny {
+	policy := redact.Default()
 	fields := make(map[string]any)
 	fields["req_time_begin"] = start.Format("2006-01-02 15:04:05.000000")
 	fields["req_raw_url"] = req.GetPath()
@@ -47,7 +50,7 @@ func callEntry(start time.Time, req *HttpRequest, rawResp *HttpResponse, arg, re
 	fields["req_time_end"] = end.Format("2006-01-02 15:04:05.000000")
 
 	fields["req_addr"] = req.GetEndpoint()
-	fields["req_url"] = req.GetFullRequestAddress()
+	fields["req_url"] = policy.URL(req.GetFullRequestAddress())
 
 	if rawResp != nil {
 		fields["resp_status"] = rawResp.GetStatusCode()
@@ -55,22 +58,39 @@ func callEntry(start time.Time, req *HttpRequest, rawResp *HttpResponse, arg, re
 		fields["req_media_type"] = rawResp.GetHeader("Content-Type")
 
 		if logHugeEnabled() {
-			fields["resp_body"] = rawResp.GetBody()
-			fields["resp_headers"] = json.ToString(rawResp.GetHeaders())
+			fields["resp_body"] = string(policy.Body(rawResp.GetHeader("Content-Type"), []byte(rawResp.GetBody())))
+			fields["resp_headers"] = json.ToString(policy.Value(rawResp.GetHeaders()))
 			fields["caller"] = callerutil.CallersDepth(32, 4).List()
 		}
 	}
 
 	if logHugeEnabled() {
-		fields["req_headers"] = json.ToString(req.headerParams)
-		fields["req_body"] = json.ToString(req.bodyData)
-		fields["func_arg"] = json.ToString(arg)
-		fields["func_reply"] = json.ToString(reply)
+		fields["req_headers"] = json.ToString(policy.Value(req.headerParams))
+		fields["req_body"] = redactBody(policy, req.headerParams.Get("Content-Type"), req.bodyData)
+		fields["func_arg"] = json.ToString(policy.Value(arg))
+		fields["func_reply"] = json.ToString(policy.Value(reply))
 	}
 	fields["error"] = err
 	return fields
 }
 
+// redactBody 脱敏请求体. 原始数据按Content-Type解析后脱敏, 如表单中的client_secret、json中的password.
+func redactBody(policy *redact.Policy, contentType string, body any) string {
+	switch b := body.(type) {
+	case []byte:
+		return string(policy.Body(contentType, b))
+	case json.RawMessage:
+		return string(policy.Body(contentType, b))
+	case string:
+		return string(policy.Body(contentType, []byte(b)))
+	case *string:
+		if b != nil {
+			return string(policy.Body(contentType, []byte(*b)))
+		}
+	}
+	return json.ToString(policy.Value(body))
+}
+
 func debugCore(ctx context.Context, start time.Time, req *HttpRequest, rawResp *HttpResponse, arg, reply any, err error) {
 	if logEnabled() {
 		fields := callEntry(start, req, rawResp, arg, reply, err)
@@ -96,7 +116,11 @@ type Logger interface {
 	Info(context.Context, map[string]any, string)
 }
 
+// SetLogger 设置调试日志输出, logger2为nil时恢复默认的标准输出.
 func SetLogger(logger2 Logger) {
+	if logger2 == nil {
+		logger2 = fmtLogger{}
+	}
 	debugLogger = logger2
 }
 
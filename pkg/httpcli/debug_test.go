@@ -0,0 +1,66 @@
+package httpcli_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/wangweihong/gotoolbox/pkg/httpcli"
+	"github.com/wangweihong/gotoolbox/pkg/json"
+)
+
+type captureLogger struct {
+	fields []map[string]any
+}
+
+func (l *captureLogger) Info(_ context.Context, fields map[string]any, _ string) {
+	if fields != nil {
+		l.fields = append(l.fields, fields)
+	}
+}
+
+func TestDebugLogRedactBody(t *testing.T) {
+	t.Setenv("HTTPCLI_DEBUG", "1")
+	t.Setenv("HTTPCLI_DEBUG_HUGE", "1")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"at1","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	Convey("调试日志脱敏请求及回应体", t, func() {
+		logger := &captureLogger{}
+		httpcli.SetLogger(logger)
+		defer httpcli.SetLogger(nil)
+
+		c, err := httpcli.NewClient(nil)
+		So(err, ShouldBeNil)
+
+		bodies := []struct {
+			contentType string
+			body        any
+		}{
+			{"application/x-www-form-urlencoded", "grant_type=client_credentials&client_id=c1&client_secret=s1"},
+			{"application/json", []byte(`{"user":"u1","password":"p1"}`)},
+			{"application/json", json.RawMessage(`{"user":"u1","password":"p1"}`)},
+		}
+		for _, b := range bodies {
+			req := httpcli.NewHttpRequestBuilder().POST().WithEndpoint(server.URL).
+				AddHeaderParam("Content-Type", b.contentType).WithBody("", b.body).Build()
+			_, err = c.Invoke(context.Background(), req, nil, nil)
+			So(err, ShouldBeNil)
+		}
+
+		So(len(logger.fields), ShouldBeGreaterThanOrEqualTo, len(bodies))
+		for _, fields := range logger.fields {
+			out := json.ToString(fields)
+			So(out, ShouldNotContainSubstring, "s1")
+			So(out, ShouldNotContainSubstring, "p1")
+			So(out, ShouldNotContainSubstring, "at1")
+		}
+	})
+}
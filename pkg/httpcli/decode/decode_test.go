@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/wangweihong/gotoolbox/pkg/errors"
 	"github.com/wangweihong/gotoolbox/pkg/httpcli/decode"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -42,3 +43,17 @@ func TestUnmarshalManifest(t *testing.T) {
 		So(ba.Message, ShouldEqual, "Hello, XML!")
 	})
 }
+
+func TestProblemParser(t *testing.T) {
+	Convey("application/problem+json解析为带错误码的错误", t, func() {
+		parser, err := decode.NewDefaultParesrFactory().GetParser("application/problem+json; charset=utf-8")
+		So(err, ShouldBeNil)
+		So(parser, ShouldHaveSameTypeAs, &decode.ProblemParser{})
+
+		data := []byte(`{"type":"about:blank","title":"Unknown Error Code","status":500,"detail":"disk full","code":1}`)
+		problem := &errors.Problem{}
+		err = parser.Unmarshal(data, problem)
+		So(errors.IsCode(err, 1), ShouldBeTrue)
+		So(problem.Detail, ShouldEqual, "disk full")
+	})
+}
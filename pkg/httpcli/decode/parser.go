@@ -11,6 +11,8 @@ import (
 
 func NewDefaultParesrFactory() ParserFactory {
 	factory := NewParserFactory()
+	// problem+json需要在通用JSON解析器之前匹配
+	factory.RegisterParser(&ProblemParser{})
 	factory.RegisterParser(&JsonParser{})
 	factory.RegisterParser(&DockerManifestParser{})
 	factory.RegisterParser(&XmlParser{})
@@ -117,6 +119,35 @@ func (p *DockerManifestParser) SupportedTypes() []string {
 	}
 }
 
+// ProblemParser RFC 7807 application/problem+json解析器.
+// 解析成功时返回还原的带错误码的错误, 调用方可以通过errors.IsCode判断下游服务返回的错误码;
+// v为*errors.Problem时同时填充problem details, 为其他类型时按JSON解析.
+type ProblemParser struct{}
+
+func (p *ProblemParser) CanParse(contentType string) bool {
+	return contentType == errors.ProblemContentType
+}
+
+func (p *ProblemParser) Unmarshal(data []byte, v any) error {
+	problem, err := errors.ParseProblem(data)
+	if err != nil {
+		return err
+	}
+
+	switch target := v.(type) {
+	case *errors.Problem:
+		*target = *problem
+	case nil:
+	default:
+		_ = json.Unmarshal(data, v)
+	}
+	return problem.Error()
+}
+
+func (p *ProblemParser) SupportedTypes() []string {
+	return []string{errors.ProblemContentType}
+}
+
 // XML解析器
 type XmlParser struct{}
 
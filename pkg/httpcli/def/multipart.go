@@ -67,10 +67,33 @@ func (f FilePart) Write(w *multipart.Writer, name string) error {
 		return err
 	}
 
-	_, err = io.Copy(writer, f.Content)
+	// 使用分段读取而不移动文件偏移, 保证请求重试时能够重新读取完整文件内容
+	reader, err := NewFileSectionReader(f.Content)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, reader)
 	return err
 }
 
+// NewFileSectionReader 从文件当前偏移开始构建只读分段读取器.
+// 读取时不会修改文件偏移, 因此同一个文件可以被多次读取(如请求重试).
+func NewFileSectionReader(f *os.File) (*io.SectionReader, error) {
+	offset, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	fileInfo, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := fileInfo.Size() - offset
+	if size < 0 {
+		size = 0
+	}
+	return io.NewSectionReader(f, offset, size), nil
+}
+
 type MultiPart struct {
 	Content any
 }
@@ -0,0 +1,342 @@
+package httpcli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/wangweihong/gotoolbox/pkg/errors"
+	"github.com/wangweihong/gotoolbox/pkg/hash"
+)
+
+// DownloadProgressFunc 下载进度回调. written为已写入的总字节数(包含续传前已存在的部分), total未知时为-1.
+type DownloadProgressFunc func(written, total int64)
+
+type DownloadInfo struct {
+	progress   DownloadProgressFunc
+	hasher     hash.Hasher
+	digest     string
+	resume     bool
+	bufferSize int
+	callOpts   []CallOption
+}
+
+type DownloadOption func(*DownloadInfo)
+
+// DownloadOptionProgress 设置下载进度回调.
+func DownloadOptionProgress(fn DownloadProgressFunc) DownloadOption {
+	return func(d *DownloadInfo) {
+		d.progress = fn
+	}
+}
+
+// DownloadOptionChecksum 下载完成后使用hasher计算文件摘要, 并与digest(十六进制)比较.
+// 仅用于下载到文件, hasher如hash.NewFileStream(sha256.New())/hash.NewFileParallelhasher().
+func DownloadOptionChecksum(hasher hash.Hasher, digest string) DownloadOption {
+	return func(d *DownloadInfo) {
+		d.hasher = hasher
+		d.digest = digest
+	}
+}
+
+// DownloadOptionResume 下载到文件时, 如果文件已存在, 通过Range请求从文件末尾继续下载.
+func DownloadOptionResume() DownloadOption {
+	return func(d *DownloadInfo) {
+		d.resume = true
+	}
+}
+
+// DownloadOptionBufferSize 设置拷贝缓冲区大小.
+func DownloadOptionBufferSize(size int) DownloadOption {
+	return func(d *DownloadInfo) {
+		if size > 0 {
+			d.bufferSize = size
+		}
+	}
+}
+
+// DownloadOptionCallOptions 设置下载请求的调用选项.
+func DownloadOptionCallOptions(opts ...CallOption) DownloadOption {
+	return func(d *DownloadInfo) {
+		d.callOpts = append(d.callOpts, opts...)
+	}
+}
+
+func newDownloadInfo(opts ...DownloadOption) *DownloadInfo {
+	di := &DownloadInfo{
+		bufferSize: 256 << 10,
+	}
+	for _, o := range opts {
+		o(di)
+	}
+	return di
+}
+
+// truncater 支持重新写入的目标, 如*os.File.
+type truncater interface {
+	io.Seeker
+	Truncate(size int64) error
+}
+
+// downloadState 记录下载进度, 在多次请求(如重试拦截器重试)之间共享, 每次请求从已写入位置继续.
+type downloadState struct {
+	info    *DownloadInfo
+	w       io.Writer
+	written int64
+	total   int64
+}
+
+// invoker 包装最终调用, 在请求中附加Range头部, 并将响应体流式写入目标.
+// 响应体被写入后替换为空响应体, 外层拦截器读取响应体时不会占用内存.
+func (s *downloadState) invoker(base Invoker) Invoker {
+	return func(ctx context.Context, req *HttpRequest, arg, reply any, cc *Client, opts ...CallOption) (*HttpResponse, error) {
+		if s.written > 0 {
+			req.Builder().SetHeaderParam("Range", fmt.Sprintf("bytes=%d-", s.written))
+			defer func() {
+				req.Builder().DelHeaderParam("Range")
+			}()
+		}
+
+		rawResp, err := base(ctx, req, arg, reply, cc, opts...)
+		if err != nil {
+			return rawResp, errors.WithStack(err)
+		}
+		return rawResp, s.consume(rawResp)
+	}
+}
+
+// maxDownloadErrorBody 出错时保留的响应体大小, 便于外层读取错误信息.
+const maxDownloadErrorBody = 64 << 10
+
+func (s *downloadState) consume(rawResp *HttpResponse) (err error) {
+	resp := rawResp.Response
+	defer func() {
+		if err != nil {
+			releaseBody(resp)
+		}
+	}()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// 服务端不支持Range, 需要从头写入
+		if s.written > 0 {
+			t, ok := s.w.(truncater)
+			if !ok {
+				return errors.Errorf("server does not support range request, cannot restart download at offset %d", s.written)
+			}
+			if err := t.Truncate(0); err != nil {
+				return errors.WithStack(err)
+			}
+			if _, err := t.Seek(0, io.SeekStart); err != nil {
+				return errors.WithStack(err)
+			}
+			s.written = 0
+		}
+		s.total = resp.ContentLength
+	case http.StatusPartialContent:
+		start, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if start != s.written {
+			return errors.Errorf("unexpected content range start %d, expect %d", start, s.written)
+		}
+		s.total = total
+	case http.StatusRequestedRangeNotSatisfiable:
+		// 续传位置已经是文件末尾
+		_, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err == nil && s.written > 0 && total == s.written {
+			s.total = total
+			resp.Body.Close()
+			resp.Body = http.NoBody
+			s.report()
+			return nil
+		}
+		return errors.Errorf("download failed, status code %d", resp.StatusCode)
+	default:
+		return errors.Errorf("download failed, status code %d", resp.StatusCode)
+	}
+
+	defer func() {
+		resp.Body.Close()
+		resp.Body = http.NoBody
+	}()
+
+	buf := make([]byte, s.info.bufferSize)
+	for {
+		n, rerr := resp.Body.Read(buf)
+		if n > 0 {
+			if _, werr := s.w.Write(buf[:n]); werr != nil {
+				return errors.WithStack(werr)
+			}
+			s.written += int64(n)
+			s.report()
+		}
+		if rerr == io.EOF {
+			break
+		}
+		// 读取中断时标记为可重试, 重试拦截器重试时从已写入位置续传
+		if rerr != nil {
+			return errors.Retryable(errors.WithStack(rerr))
+		}
+	}
+
+	if s.total >= 0 && s.written != s.total {
+		return errors.Retryable(errors.WithStack(io.ErrUnexpectedEOF))
+	}
+	return nil
+}
+
+// releaseBody 读取并关闭响应体以释放连接, 保留前maxDownloadErrorBody字节供外层读取错误信息.
+func releaseBody(resp *http.Response) {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxDownloadErrorBody))
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+}
+
+func (s *downloadState) report() {
+	if s.info.progress != nil {
+		s.info.progress(s.written, s.total)
+	}
+}
+
+// parseContentRange 解析`bytes start-end/total`或`bytes */total`, total未知时为-1.
+func parseContentRange(value string) (int64, int64, error) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "bytes ") {
+		return 0, 0, errors.Errorf("invalid content range %q", value)
+	}
+	rangePart, totalPart, ok := strings.Cut(strings.TrimPrefix(value, "bytes "), "/")
+	if !ok {
+		return 0, 0, errors.Errorf("invalid content range %q", value)
+	}
+
+	total := int64(-1)
+	if totalPart != "*" {
+		t, err := strconv.ParseInt(totalPart, 10, 64)
+		if err != nil {
+			return 0, 0, errors.Errorf("invalid content range %q", value)
+		}
+		total = t
+	}
+
+	if rangePart == "*" {
+		return 0, total, nil
+	}
+	startPart, _, _ := strings.Cut(rangePart, "-")
+	start, err := strconv.ParseInt(startPart, 10, 64)
+	if err != nil {
+		return 0, 0, errors.Errorf("invalid content range %q", value)
+	}
+	return start, total, nil
+}
+
+// openDownloadFile 打开下载目标文件, 续传时返回已有文件大小.
+func openDownloadFile(filePath string, resume bool) (*os.File, int64, error) {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return nil, 0, errors.Errorf("mkdirAll %v error:%v", filepath.Dir(filePath), err)
+	}
+
+	flag := os.O_CREATE | os.O_WRONLY
+	if !resume {
+		flag |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(filePath, flag, 0644)
+	if err != nil {
+		return nil, 0, errors.Errorf("open file %v error:%v", filePath, err)
+	}
+
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		file.Close()
+		return nil, 0, errors.WithStack(err)
+	}
+	return file, offset, nil
+}
+
+// verifyDownloadFile 校验下载文件的摘要, 不一致时删除文件避免下次续传基于错误数据.
+func verifyDownloadFile(filePath string, info *DownloadInfo) error {
+	if info.hasher == nil {
+		return nil
+	}
+
+	sum, err := info.hasher.Sum(filePath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if !strings.EqualFold(sum, info.digest) {
+		os.Remove(filePath)
+		return errors.Errorf("checksum mismatch for %v, expect %v, actual %v", filePath, info.digest, sum)
+	}
+	return nil
+}
+
+// Download 通过拦截器链发起请求, 并将响应体流式写入w. 读取响应体中断时返回可重试错误(见errors.IsRetryable),
+// 与重试拦截器配合时, 重试请求会通过Range从已写入位置继续.
+// 返回的HttpResponse的响应体已被消费.
+func (c *Client) Download(ctx context.Context, req *HttpRequest, w io.Writer, opts ...DownloadOption) (*HttpResponse, error) {
+	info := newDownloadInfo(opts...)
+	state := &downloadState{info: info, w: w, total: -1}
+
+	rawResp, err := c.invokeChain(ctx, req, nil, nil, state.invoker(invoke), info.callOpts...)
+	return rawResp, errors.WithStack(err)
+}
+
+// DownloadFile 将响应体下载到文件, 支持断点续传和摘要校验.
+func (c *Client) DownloadFile(ctx context.Context, req *HttpRequest, filePath string, opts ...DownloadOption) (*HttpResponse, error) {
+	info := newDownloadInfo(opts...)
+	file, offset, err := openDownloadFile(filePath, info.resume)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer file.Close()
+
+	state := &downloadState{info: info, w: file, written: offset, total: -1}
+	rawResp, err := c.invokeChain(ctx, req, nil, nil, state.invoker(invoke), info.callOpts...)
+	if err != nil {
+		return rawResp, errors.WithStack(err)
+	}
+	if err := file.Close(); err != nil {
+		return rawResp, errors.WithStack(err)
+	}
+	return rawResp, verifyDownloadFile(filePath, info)
+}
+
+// DownloadWithContext 不经过客户端直接发起请求, 并将响应体流式写入w.
+func (r *HttpRequest) DownloadWithContext(ctx context.Context, w io.Writer, opts ...DownloadOption) (*HttpResponse, error) {
+	info := newDownloadInfo(opts...)
+	state := &downloadState{info: info, w: w, total: -1}
+
+	rawResp, err := state.invoker(requestInvoker)(ctx, r, nil, nil, nil, info.callOpts...)
+	return rawResp, errors.WithStack(err)
+}
+
+// DownloadFileWithContext 不经过客户端直接发起请求, 将响应体下载到文件, 支持断点续传和摘要校验.
+func (r *HttpRequest) DownloadFileWithContext(ctx context.Context, filePath string, opts ...DownloadOption) (*HttpResponse, error) {
+	info := newDownloadInfo(opts...)
+	file, offset, err := openDownloadFile(filePath, info.resume)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer file.Close()
+
+	state := &downloadState{info: info, w: file, written: offset, total: -1}
+	rawResp, err := state.invoker(requestInvoker)(ctx, r, nil, nil, nil, info.callOpts...)
+	if err != nil {
+		return rawResp, errors.WithStack(err)
+	}
+	if err := file.Close(); err != nil {
+		return rawResp, errors.WithStack(err)
+	}
+	return rawResp, verifyDownloadFile(filePath, info)
+}
+
+func requestInvoker(ctx context.Context, req *HttpRequest, arg, reply any, cc *Client, opts ...CallOption) (*HttpResponse, error) {
+	return req.InvokeWithContext(ctx, opts...)
+}
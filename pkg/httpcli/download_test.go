@@ -0,0 +1,187 @@
+package httpcli_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/wangweihong/gotoolbox/pkg/hash"
+	"github.com/wangweihong/gotoolbox/pkg/httpcli"
+	"github.com/wangweihong/gotoolbox/pkg/httpcli/interceptorcli"
+	"github.com/wangweihong/gotoolbox/pkg/wait"
+)
+
+func TestClient_Download(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 1000))
+	sum := sha256.Sum256(content)
+	digest := hex.EncodeToString(sum[:])
+
+	var ranges int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			atomic.AddInt32(&ranges, 1)
+		}
+		http.ServeContent(w, r, "image.iso", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	c, err := httpcli.NewClient(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newReq := func() *httpcli.HttpRequest {
+		return httpcli.NewHttpRequestBuilder().GET().WithEndpoint(server.URL).WithPath("/image.iso").Build()
+	}
+
+	Convey("流式下载", t, func() {
+		Convey("下载到io.Writer并回调进度", func() {
+			buf := &bytes.Buffer{}
+			var lastWritten, lastTotal int64
+			resp, err := c.Download(context.Background(), newReq(), buf,
+				httpcli.DownloadOptionBufferSize(1024),
+				httpcli.DownloadOptionProgress(func(written, total int64) {
+					lastWritten, lastTotal = written, total
+				}))
+			So(err, ShouldBeNil)
+			So(resp.GetStatusCode(), ShouldEqual, http.StatusOK)
+			So(buf.Bytes(), ShouldResemble, content)
+			So(lastWritten, ShouldEqual, len(content))
+			So(lastTotal, ShouldEqual, len(content))
+		})
+
+		Convey("断点续传并校验摘要", func() {
+			fp := filepath.Join(t.TempDir(), "image.iso")
+			So(os.WriteFile(fp, content[:4000], 0o644), ShouldBeNil)
+
+			resp, err := c.DownloadFile(context.Background(), newReq(), fp,
+				httpcli.DownloadOptionResume(),
+				httpcli.DownloadOptionChecksum(hash.NewFileStream(sha256.New()), digest))
+			So(err, ShouldBeNil)
+			So(resp.GetStatusCode(), ShouldEqual, http.StatusPartialContent)
+			So(atomic.LoadInt32(&ranges), ShouldBeGreaterThan, 0)
+
+			data, err := os.ReadFile(fp)
+			So(err, ShouldBeNil)
+			So(data, ShouldResemble, content)
+
+			// 文件已完整时无需再次下载
+			_, err = newReq().DownloadFileWithContext(context.Background(), fp,
+				httpcli.DownloadOptionResume(),
+				httpcli.DownloadOptionChecksum(hash.NewFileStream(sha256.New()), digest))
+			So(err, ShouldBeNil)
+		})
+
+		Convey("摘要不一致时返回错误并删除文件", func() {
+			fp := filepath.Join(t.TempDir(), "image.iso")
+			_, err := c.DownloadFile(context.Background(), newReq(), fp,
+				httpcli.DownloadOptionChecksum(hash.NewFileStream(sha256.New()), "deadbeef"))
+			So(err, ShouldNotBeNil)
+			_, err = os.Stat(fp)
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+	})
+}
+
+func TestClient_DownloadResumeOnRetry(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 1000))
+	var requests int32
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			// 第一次请求只返回一半数据后断开连接
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write(content[:len(content)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "image.iso", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	policy := interceptorcli.DefaultRetryPolicy()
+	policy.Backoff = wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 3}
+	c, err := httpcli.NewClient(nil, httpcli.WithIntercepts(interceptorcli.RetryInterceptor("retry", policy)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Convey("读取响应体中断后通过Range续传", t, func() {
+		buf := &bytes.Buffer{}
+		req := httpcli.NewHttpRequestBuilder().GET().WithEndpoint(server.URL).WithPath("/image.iso").Build()
+		resp, err := c.Download(context.Background(), req, buf)
+		So(err, ShouldBeNil)
+		So(resp.GetStatusCode(), ShouldEqual, http.StatusPartialContent)
+		So(atomic.LoadInt32(&requests), ShouldEqual, 2)
+		So(ranges, ShouldResemble, []string{"bytes=" + strconv.Itoa(len(content)/2) + "-"})
+		So(buf.Bytes(), ShouldResemble, content)
+	})
+}
+
+type closeTracker struct {
+	io.ReadCloser
+	closed *int32
+}
+
+func (c *closeTracker) Close() error {
+	atomic.AddInt32(c.closed, 1)
+	return c.ReadCloser.Close()
+}
+
+type trackTransport struct {
+	closed int32
+}
+
+func (t *trackTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &closeTracker{ReadCloser: resp.Body, closed: &t.closed}
+	return resp, nil
+}
+
+func TestClient_DownloadCloseBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/error":
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("boom"))
+		case "/range":
+			w.Header().Set("Content-Range", "invalid")
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write([]byte("data"))
+		}
+	}))
+	defer server.Close()
+
+	Convey("下载失败时关闭响应体", t, func() {
+		for _, path := range []string{"/error", "/range"} {
+			tr := &trackTransport{}
+			c, err := httpcli.NewClient(nil, httpcli.WithRoundTripper(tr))
+			So(err, ShouldBeNil)
+
+			req := httpcli.NewHttpRequestBuilder().GET().WithEndpoint(server.URL).WithPath(path).Build()
+			resp, err := c.Download(context.Background(), req, &bytes.Buffer{})
+			So(err, ShouldNotBeNil)
+			So(atomic.LoadInt32(&tr.closed), ShouldEqual, 1)
+			if path == "/error" {
+				So(resp.GetBody(), ShouldEqual, "boom")
+			}
+		}
+	})
+}
@@ -19,10 +19,12 @@ const (
 
 type HttpConfig struct {
 	// Timeout 设置整个客户端的超时, 优先级低于每个请求单独的请求
-	Timeout          time.Duration
-	HttpProxy        func(*http.Request) (*url.URL, error)
-	HttpHandler      *httphandler.HttpHandler
-	HttpTransport    *http.Transport
+	Timeout       time.Duration
+	HttpProxy     func(*http.Request) (*url.URL, error)
+	HttpHandler   *httphandler.HttpHandler
+	HttpTransport *http.Transport
+	// RoundTripper 自定义传输层, 如记录/回放传输层. 设置后忽略HttpTransport及TLS/代理配置
+	RoundTripper     http.RoundTripper
 	TlsEnabled       bool
 	SkipTlsVerified  bool
 	ServerCA         string
@@ -10,6 +10,8 @@ import (
 	"net/http"
 	"sort"
 	"strings"
+
+	"github.com/wangweihong/gotoolbox/pkg/redact"
 )
 
 func RequestHandler(req *http.Request) (err error) {
@@ -151,17 +153,11 @@ func FormatHeaders(headers http.Header, separator string) string {
 }
 
 // redactHeaders processes a headers object, returning a redacted list.
+// 敏感头按redact.Default()脱敏.
 func redactHeaders(headers http.Header) (processedHeaders []string) {
-	// sensitiveWords is a list of headers that need to be redacted.
-	sensitiveWords := []string{"token", "authorization"}
-
-	for name, header := range headers {
+	for name, header := range redact.Default().Header(headers) {
 		for _, v := range header {
-			if isSliceContainsStr(sensitiveWords, name) {
-				processedHeaders = append(processedHeaders, fmt.Sprintf("%v: %v", name, "***"))
-			} else {
-				processedHeaders = append(processedHeaders, fmt.Sprintf("%v: %v", name, v))
-			}
+			processedHeaders = append(processedHeaders, fmt.Sprintf("%v: %v", name, v))
 		}
 	}
 	return
@@ -182,7 +178,7 @@ func formatJSON(raw []byte) string {
 		return string(raw)
 	}
 
-	pretty, err := json.MarshalIndent(data, "", "  ")
+	pretty, err := json.MarshalIndent(redact.Default().Value(data), "", "  ")
 	if err != nil {
 		log.Printf("[DEBUG] Unable to re-marshal JSON: %s", err)
 		return string(raw)
@@ -203,17 +199,6 @@ func findJSONIndex(raw []byte) int {
 	return index
 }
 
-func isSliceContainsStr(array []string, str string) bool {
-	str = strings.ToLower(str)
-	for _, s := range array {
-		s = strings.ToLower(s)
-		if strings.Contains(str, s) {
-			return true
-		}
-	}
-	return false
-}
-
 func isStream(header http.Header) bool {
 	contentType := header.Get("Content-Type")
 	if contentType == "" {
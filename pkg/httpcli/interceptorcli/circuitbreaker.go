@@ -0,0 +1,278 @@
+package interceptorcli
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/wangweihong/gotoolbox/pkg/clock"
+	"github.com/wangweihong/gotoolbox/pkg/errors"
+
+	"github.com/wangweihong/gotoolbox/pkg/httpcli"
+	"github.com/wangweihong/gotoolbox/pkg/log"
+	"github.com/wangweihong/gotoolbox/pkg/skipper"
+)
+
+type CircuitState int
+
+const (
+	// CircuitClosed 关闭状态, 请求正常通过
+	CircuitClosed CircuitState = iota
+	// CircuitOpen 打开状态, 请求被快速拒绝
+	CircuitOpen
+	// CircuitHalfOpen 半开状态, 仅允许少量探测请求通过
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerPolicy 熔断策略.
+type CircuitBreakerPolicy struct {
+	// 连续失败次数达到该值时熔断, 0表示不启用该策略
+	ConsecutiveFailures int
+	// 统计窗口内失败率达到该值时熔断, 取值(0,1], 0表示不启用该策略
+	FailureRatio float64
+	// 统计窗口内请求数达到该值后才计算失败率
+	MinRequests int
+	// 关闭状态下的统计窗口, 超过后清空计数. 0表示不清空
+	Interval time.Duration
+	// 熔断打开后的冷却时间, 超过后进入半开状态
+	CoolDown time.Duration
+	// 半开状态允许通过的探测请求数, 全部成功后关闭熔断器
+	HalfOpenMaxRequests int
+	// 判定请求是否失败. 默认连接错误或5xx状态码为失败
+	IsFailure func(rawResp *httpcli.HttpResponse, err error) bool
+}
+
+// DefaultCircuitBreakerPolicy 默认熔断策略: 连续失败5次或10s内至少20个请求且失败率超过50%时熔断, 冷却30s.
+func DefaultCircuitBreakerPolicy() *CircuitBreakerPolicy {
+	return &CircuitBreakerPolicy{
+		ConsecutiveFailures: 5,
+		FailureRatio:        0.5,
+		MinRequests:         20,
+		Interval:            10 * time.Second,
+		CoolDown:            30 * time.Second,
+		HalfOpenMaxRequests: 1,
+		IsFailure:           defaultIsFailure,
+	}
+}
+
+func defaultIsFailure(rawResp *httpcli.HttpResponse, err error) bool {
+	if rawResp != nil && rawResp.Response != nil {
+		return rawResp.GetStatusCode() >= http.StatusInternalServerError
+	}
+	return err != nil
+}
+
+// NewEndpointCircuitBreaker 创建按endpoint+path区分的熔断器.
+func NewEndpointCircuitBreaker(policy *CircuitBreakerPolicy) *EndpointCircuitBreaker {
+	return NewEndpointCircuitBreakerWithClock(policy, clock.RealClock{})
+}
+
+func NewEndpointCircuitBreakerWithClock(policy *CircuitBreakerPolicy, c clock.PassiveClock) *EndpointCircuitBreaker {
+	if policy == nil {
+		policy = DefaultCircuitBreakerPolicy()
+	}
+	p := *policy
+	if p.IsFailure == nil {
+		p.IsFailure = defaultIsFailure
+	}
+	if p.HalfOpenMaxRequests <= 0 {
+		p.HalfOpenMaxRequests = 1
+	}
+
+	return &EndpointCircuitBreaker{
+		policy:   p,
+		clock:    c,
+		breakers: make(map[string]*circuitBreaker),
+	}
+}
+
+type EndpointCircuitBreaker struct {
+	policy CircuitBreakerPolicy
+	clock  clock.PassiveClock
+
+	lock     sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+// State 获取指定endpoint+path的熔断状态.
+func (b *EndpointCircuitBreaker) State(endpoint, path string) CircuitState {
+	cb := b.get(endpoint + path)
+
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	cb.refresh(b.clock.Now())
+	return cb.state
+}
+
+// Reset 清空所有熔断状态.
+func (b *EndpointCircuitBreaker) Reset() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.breakers = make(map[string]*circuitBreaker)
+}
+
+func (b *EndpointCircuitBreaker) get(key string) *circuitBreaker {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	cb, exists := b.breakers[key]
+	if !exists {
+		cb = &circuitBreaker{policy: &b.policy, windowStart: b.clock.Now()}
+		b.breakers[key] = cb
+	}
+	return cb
+}
+
+type circuitBreaker struct {
+	policy *CircuitBreakerPolicy
+
+	lock        sync.Mutex
+	state       CircuitState
+	openedAt    time.Time
+	windowStart time.Time
+	// 统计窗口内请求数/失败数
+	requests int
+	failures int
+	// 连续失败数
+	consecutiveFailures int
+	// 半开状态下已放行/已成功的探测请求数
+	halfOpenRequests  int
+	halfOpenSuccesses int
+}
+
+// refresh 根据时间推进状态: 打开状态冷却结束后进入半开; 关闭状态统计窗口过期后清空计数.
+func (cb *circuitBreaker) refresh(now time.Time) {
+	switch cb.state {
+	case CircuitOpen:
+		if now.Sub(cb.openedAt) >= cb.policy.CoolDown {
+			cb.state = CircuitHalfOpen
+			cb.halfOpenRequests = 0
+			cb.halfOpenSuccesses = 0
+		}
+	case CircuitClosed:
+		if cb.policy.Interval > 0 && now.Sub(cb.windowStart) >= cb.policy.Interval {
+			cb.resetCounts(now)
+		}
+	}
+}
+
+func (cb *circuitBreaker) resetCounts(now time.Time) {
+	cb.windowStart = now
+	cb.requests = 0
+	cb.failures = 0
+	cb.consecutiveFailures = 0
+}
+
+// allow 判断请求是否允许通过.
+func (cb *circuitBreaker) allow(now time.Time) (CircuitState, bool) {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	cb.refresh(now)
+	switch cb.state {
+	case CircuitOpen:
+		return cb.state, false
+	case CircuitHalfOpen:
+		if cb.halfOpenRequests >= cb.policy.HalfOpenMaxRequests {
+			return cb.state, false
+		}
+		cb.halfOpenRequests++
+	}
+	return cb.state, true
+}
+
+// done 记录请求结果.
+func (cb *circuitBreaker) done(now time.Time, failed bool) {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	switch cb.state {
+	case CircuitHalfOpen:
+		if failed {
+			cb.trip(now)
+			return
+		}
+		cb.halfOpenSuccesses++
+		if cb.halfOpenSuccesses >= cb.policy.HalfOpenMaxRequests {
+			cb.state = CircuitClosed
+			cb.resetCounts(now)
+		}
+	case CircuitClosed:
+		cb.requests++
+		if !failed {
+			cb.consecutiveFailures = 0
+			return
+		}
+		cb.failures++
+		cb.consecutiveFailures++
+		if cb.shouldTrip() {
+			cb.trip(now)
+		}
+	}
+}
+
+func (cb *circuitBreaker) shouldTrip() bool {
+	p := cb.policy
+	if p.ConsecutiveFailures > 0 && cb.consecutiveFailures >= p.ConsecutiveFailures {
+		return true
+	}
+	if p.FailureRatio > 0 && cb.requests >= p.MinRequests && cb.requests > 0 {
+		return float64(cb.failures)/float64(cb.requests) >= p.FailureRatio
+	}
+	return false
+}
+
+func (cb *circuitBreaker) trip(now time.Time) {
+	cb.state = CircuitOpen
+	cb.openedAt = now
+	cb.resetCounts(now)
+}
+
+// CircuitBreakerInterceptor 熔断拦截器. 按endpoint+path统计请求失败情况, 熔断打开时直接返回ErrCircuitBreakerOpen错误码.
+// 调用方可以通过errors.IsCode(err, interceptorcli.ErrCircuitBreakerOpen)判断.
+func CircuitBreakerInterceptor(name string, b *EndpointCircuitBreaker, skipperFunc ...skipper.SkipperFunc) httpcli.Interceptor {
+	return httpcli.NewInterceptor(name, func(ctx context.Context, req *httpcli.HttpRequest, arg, reply any, cc *httpcli.Client,
+		invoker httpcli.Invoker, opts ...httpcli.CallOption) (*httpcli.HttpResponse, error) {
+		if skipper.Skip(req.GetPath(), skipperFunc...) {
+			log.F(ctx).Debugf("skip interceptor %s for rawrurl %s", name, req.GetPath())
+
+			return invoker(ctx, req, arg, reply, cc, opts...)
+		}
+
+		if b == nil {
+			return invoker(ctx, req, arg, reply, cc, opts...)
+		}
+
+		key := req.GetEndpoint() + req.GetPath()
+		cb := b.get(key)
+		state, ok := cb.allow(b.clock.Now())
+		if !ok {
+			return nil, errors.WithCode(ErrCircuitBreakerOpen, "circuit breaker for %v is %v", key, state)
+		}
+
+		rawResp, err := invoker(ctx, req, arg, reply, cc, opts...)
+		// 调用方主动撤销的请求不计入统计
+		if ctx.Err() == nil {
+			cb.done(b.clock.Now(), b.policy.IsFailure(rawResp, err))
+		} else if state == CircuitHalfOpen {
+			cb.lock.Lock()
+			cb.halfOpenRequests--
+			cb.lock.Unlock()
+		}
+		return rawResp, errors.WithStack(err)
+	})
+}
@@ -0,0 +1,74 @@
+package interceptorcli_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/wangweihong/gotoolbox/pkg/clock"
+	"github.com/wangweihong/gotoolbox/pkg/errors"
+	"github.com/wangweihong/gotoolbox/pkg/httpcli"
+	"github.com/wangweihong/gotoolbox/pkg/httpcli/interceptorcli"
+)
+
+func TestCircuitBreakerInterceptor(t *testing.T) {
+	Convey("熔断拦截器", t, func() {
+		var healthy int32
+		var count int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&count, 1)
+			if atomic.LoadInt32(&healthy) == 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer server.Close()
+
+		fakeClock := clock.NewFakePassiveClock(time.Now())
+		breaker := interceptorcli.NewEndpointCircuitBreakerWithClock(&interceptorcli.CircuitBreakerPolicy{
+			ConsecutiveFailures: 3,
+			CoolDown:            time.Minute,
+			HalfOpenMaxRequests: 1,
+		}, fakeClock)
+
+		c, err := httpcli.NewClient(nil, httpcli.WithIntercepts(interceptorcli.CircuitBreakerInterceptor("breaker", breaker)))
+		So(err, ShouldBeNil)
+
+		invoke := func() error {
+			req := httpcli.NewHttpRequestBuilder().GET().WithEndpoint(server.URL).WithPath("/device").Build()
+			_, err := c.Invoke(context.Background(), req, nil, nil)
+			return err
+		}
+
+		for i := 0; i < 3; i++ {
+			So(invoke(), ShouldBeNil)
+		}
+		So(breaker.State(server.URL, "/device"), ShouldEqual, interceptorcli.CircuitOpen)
+
+		Convey("熔断打开时快速失败", func() {
+			err := invoke()
+			So(errors.IsCode(err, interceptorcli.ErrCircuitBreakerOpen), ShouldBeTrue)
+			So(atomic.LoadInt32(&count), ShouldEqual, 3)
+			// 其他路径不受影响
+			So(breaker.State(server.URL, "/other"), ShouldEqual, interceptorcli.CircuitClosed)
+		})
+
+		Convey("冷却结束后探测失败重新打开", func() {
+			fakeClock.SetTime(fakeClock.Now().Add(time.Minute))
+			So(breaker.State(server.URL, "/device"), ShouldEqual, interceptorcli.CircuitHalfOpen)
+			So(invoke(), ShouldBeNil)
+			So(breaker.State(server.URL, "/device"), ShouldEqual, interceptorcli.CircuitOpen)
+		})
+
+		Convey("冷却结束后探测成功关闭熔断器", func() {
+			atomic.StoreInt32(&healthy, 1)
+			fakeClock.SetTime(fakeClock.Now().Add(time.Minute))
+			So(invoke(), ShouldBeNil)
+			So(breaker.State(server.URL, "/device"), ShouldEqual, interceptorcli.CircuitClosed)
+		})
+	})
+}
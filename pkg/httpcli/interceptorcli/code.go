@@ -0,0 +1,28 @@
+package interceptorcli
+
+import (
+	"net/http"
+
+	"github.com/wangweihong/gotoolbox/pkg/errors"
+)
+
+// interceptorcli: 拦截器错误码.
+const (
+	// ErrCircuitBreakerOpen 熔断器处于打开状态, 请求被快速拒绝.
+	ErrCircuitBreakerOpen int = iota + 100601
+
+	// ErrOAuth2Token 获取OAuth2访问令牌失败.
+	ErrOAuth2Token
+)
+
+//nolint:gochecknoinits
+func init() {
+	errors.Register(errors.NewCoder(ErrCircuitBreakerOpen, http.StatusInternalServerError, map[string]string{
+		errors.MessageLangCNKey: "服务熔断中",
+		errors.MessageLangENKey: "Circuit breaker is open",
+	}))
+	errors.Register(errors.NewCoder(ErrOAuth2Token, http.StatusUnauthorized, map[string]string{
+		errors.MessageLangCNKey: "获取访问令牌失败",
+		errors.MessageLangENKey: "Failed to obtain OAuth2 access token",
+	}))
+}
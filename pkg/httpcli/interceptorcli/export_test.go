@@ -0,0 +1,8 @@
+package interceptorcli
+
+// VaryLen 返回记录了Vary头部的URL数量, 用于测试Vary记录随缓存条目移除.
+func (c *ResponseCache) VaryLen() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.vary)
+}
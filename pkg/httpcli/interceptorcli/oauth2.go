@@ -0,0 +1,256 @@
+package interceptorcli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wangweihong/gotoolbox/pkg/clock"
+	"github.com/wangweihong/gotoolbox/pkg/errors"
+
+	"github.com/wangweihong/gotoolbox/pkg/httpcli"
+	"github.com/wangweihong/gotoolbox/pkg/log"
+	"github.com/wangweihong/gotoolbox/pkg/skipper"
+)
+
+// OAuth2Policy OAuth2令牌获取策略.
+type OAuth2Policy struct {
+	// 令牌端点地址
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// RefreshToken 非空时使用refresh_token授权获取令牌, 否则使用client_credentials授权
+	RefreshToken string
+	// EndpointParams 令牌请求的额外参数, 如audience
+	EndpointParams url.Values
+	// AuthInParams 客户端凭证放在请求参数中. 默认使用Basic认证头
+	AuthInParams bool
+	// ExpiryDelta 令牌过期前提前刷新的时间
+	ExpiryDelta time.Duration
+	// Client 获取令牌使用的客户端, 为空时直接发送请求. 注意不能包含使用同一令牌源的OAuth2拦截器
+	Client *httpcli.Client
+}
+
+// DefaultOAuth2Policy 默认OAuth2策略: client_credentials授权, 令牌过期前30s刷新.
+func DefaultOAuth2Policy(tokenURL, clientID, clientSecret string, scopes ...string) *OAuth2Policy {
+	return &OAuth2Policy{
+		TokenURL:     tokenURL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       scopes,
+		ExpiryDelta:  30 * time.Second,
+	}
+}
+
+// OAuth2Token 访问令牌.
+type OAuth2Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	// Expiry 过期时间, 零值表示不过期
+	Expiry time.Time `json:"-"`
+}
+
+// Type 返回Authorization头使用的令牌类型, 默认为Bearer.
+func (t *OAuth2Token) Type() string {
+	switch strings.ToLower(t.TokenType) {
+	case "", "bearer":
+		return "Bearer"
+	case "mac":
+		return "MAC"
+	case "basic":
+		return "Basic"
+	}
+	return t.TokenType
+}
+
+// 令牌端点返回的错误, 参考RFC 6749 5.2.
+type oauth2ErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// NewOAuth2TokenSource 创建缓存令牌的令牌源. 令牌在过期前ExpiryDelta时间内会被重新获取.
+func NewOAuth2TokenSource(policy *OAuth2Policy) *OAuth2TokenSource {
+	return NewOAuth2TokenSourceWithClock(policy, clock.RealClock{})
+}
+
+func NewOAuth2TokenSourceWithClock(policy *OAuth2Policy, c clock.PassiveClock) *OAuth2TokenSource {
+	p := *policy
+	return &OAuth2TokenSource{
+		policy:       p,
+		clock:        c,
+		refreshToken: p.RefreshToken,
+	}
+}
+
+type OAuth2TokenSource struct {
+	policy OAuth2Policy
+	clock  clock.PassiveClock
+
+	// 获取令牌期间持有锁, 保证并发请求只获取一次令牌
+	lock  sync.Mutex
+	token *OAuth2Token
+	// 服务端可能在刷新时轮换refresh token
+	refreshToken string
+}
+
+// Token 获取有效的令牌. 缓存的令牌即将过期时重新获取.
+func (s *OAuth2TokenSource) Token(ctx context.Context) (*OAuth2Token, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.valid(s.token) {
+		return s.token, nil
+	}
+
+	token, err := s.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.token = token
+	return token, nil
+}
+
+// Invalidate 废弃指定的访问令牌, 下次调用Token时重新获取.
+// 仅当缓存的令牌与指定令牌一致时废弃, 避免并发请求重复刷新.
+func (s *OAuth2TokenSource) Invalidate(accessToken string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.token != nil && s.token.AccessToken == accessToken {
+		s.token = nil
+	}
+}
+
+func (s *OAuth2TokenSource) valid(token *OAuth2Token) bool {
+	if token == nil || token.AccessToken == "" {
+		return false
+	}
+	if token.Expiry.IsZero() {
+		return true
+	}
+	return s.clock.Now().Add(s.policy.ExpiryDelta).Before(token.Expiry)
+}
+
+func (s *OAuth2TokenSource) fetch(ctx context.Context) (*OAuth2Token, error) {
+	p := &s.policy
+
+	params := url.Values{}
+	for k, v := range p.EndpointParams {
+		params[k] = v
+	}
+	if s.refreshToken != "" {
+		params.Set("grant_type", "refresh_token")
+		params.Set("refresh_token", s.refreshToken)
+	} else {
+		params.Set("grant_type", "client_credentials")
+	}
+	if len(p.Scopes) > 0 {
+		params.Set("scope", strings.Join(p.Scopes, " "))
+	}
+
+	builder := httpcli.NewHttpRequestBuilder().POST().WithEndpoint(p.TokenURL).
+		AddHeaderParam("Content-Type", "application/x-www-form-urlencoded").
+		AddHeaderParam("Accept", "application/json").
+		WithRetryable(true)
+	if p.AuthInParams {
+		params.Set("client_id", p.ClientID)
+		if p.ClientSecret != "" {
+			params.Set("client_secret", p.ClientSecret)
+		}
+	} else {
+		builder.AddBasicAuthHeaderParam(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+	req := builder.WithBody("", params.Encode()).Build()
+
+	var rawResp *httpcli.HttpResponse
+	var err error
+	if p.Client != nil {
+		rawResp, err = p.Client.Invoke(ctx, req, nil, nil)
+	} else {
+		rawResp, err = req.InvokeWithContext(ctx)
+	}
+	if err != nil {
+		return nil, errors.WithCode(ErrOAuth2Token, "request token from %v fail:%v", p.TokenURL, err)
+	}
+	body := rawResp.GetBody()
+
+	if rawResp.GetStatusCode() < 200 || rawResp.GetStatusCode() > 299 {
+		oe := &oauth2ErrorResponse{}
+		if json.Unmarshal([]byte(body), oe) == nil && oe.Error != "" {
+			return nil, errors.WithCode(ErrOAuth2Token, "request token from %v fail, status:%v, error:%v, description:%v",
+				p.TokenURL, rawResp.GetStatusCode(), oe.Error, oe.ErrorDescription)
+		}
+		return nil, errors.WithCode(ErrOAuth2Token, "request token from %v fail, status:%v, body:%v",
+			p.TokenURL, rawResp.GetStatusCode(), body)
+	}
+
+	token := &OAuth2Token{}
+	if err := json.Unmarshal([]byte(body), token); err != nil {
+		return nil, errors.WithCode(ErrOAuth2Token, "decode token response fail:%v", err)
+	}
+	if token.AccessToken == "" {
+		return nil, errors.WithCode(ErrOAuth2Token, "token response missing access_token")
+	}
+	if token.ExpiresIn > 0 {
+		token.Expiry = s.clock.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	if token.RefreshToken != "" {
+		s.refreshToken = token.RefreshToken
+	}
+	return token, nil
+}
+
+// OAuth2Interceptor OAuth2认证拦截器. 从令牌源获取令牌并设置Authorization头, 取代各调用方自行获取缓存令牌并
+// 调用AddTokenAuthHeaderParam的方式.
+// 服务端返回401时废弃当前令牌, 重新获取令牌后重试一次(请求体不可重复读取时不重试).
+// 注意: 拦截器应放在解码/状态码拦截器之前, 以便检查原始响应.
+func OAuth2Interceptor(name string, source *OAuth2TokenSource, skipperFunc ...skipper.SkipperFunc) httpcli.Interceptor {
+	return httpcli.NewInterceptor(name, func(ctx context.Context, req *httpcli.HttpRequest, arg, reply any, cc *httpcli.Client,
+		invoker httpcli.Invoker, opts ...httpcli.CallOption) (*httpcli.HttpResponse, error) {
+		if skipper.Skip(req.GetPath(), skipperFunc...) {
+			log.F(ctx).Debugf("skip interceptor %s for rawrurl %s", name, req.GetPath())
+
+			return invoker(ctx, req, arg, reply, cc, opts...)
+		}
+
+		token, err := source.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		rawResp, err := invoker(ctx, req, arg, reply, cc, withOAuth2Token(opts, token)...)
+		if err != nil || rawResp == nil || rawResp.Response == nil ||
+			rawResp.GetStatusCode() != http.StatusUnauthorized || !req.IsBodyRewindable() {
+			return rawResp, errors.WithStack(err)
+		}
+
+		log.F(ctx).Debugf("interceptor %s got 401 for %s %s, refresh token and retry", name, req.GetMethod(), req.GetPath())
+		drainResponse(rawResp)
+		source.Invalidate(token.AccessToken)
+		token, err = source.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		rawResp, err = invoker(ctx, req, arg, reply, cc, withOAuth2Token(opts, token)...)
+		return rawResp, errors.WithStack(err)
+	})
+}
+
+// withOAuth2Token 在http请求上设置令牌, 不修改调用方的请求.
+func withOAuth2Token(opts []httpcli.CallOption, token *OAuth2Token) []httpcli.CallOption {
+	authOpt := httpcli.CallOptionAddHttpRequestProcess(func(r *http.Request) (*http.Request, error) {
+		r.Header.Set("Authorization", token.Type()+" "+token.AccessToken)
+		return r, nil
+	})
+	// 避免修改调用方opts的底层数组
+	return append(opts[:len(opts):len(opts)], authOpt)
+}
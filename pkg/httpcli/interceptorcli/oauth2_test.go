@@ -0,0 +1,132 @@
+package interceptorcli_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/wangweihong/gotoolbox/pkg/clock"
+	"github.com/wangweihong/gotoolbox/pkg/errors"
+	"github.com/wangweihong/gotoolbox/pkg/httpcli"
+	"github.com/wangweihong/gotoolbox/pkg/httpcli/interceptorcli"
+)
+
+// 令牌桩服务: 每次签发新令牌, 记录最近的授权参数; revoke后之前签发的令牌失效.
+type stubTokenServer struct {
+	lock      sync.Mutex
+	issued    int
+	valid     map[string]bool
+	grantType string
+	refresh   string
+}
+
+func (s *stubTokenServer) tokenHandler(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	user, pass, ok := r.BasicAuth()
+	if !ok || user != "client" || pass != "secret" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":"invalid_client","error_description":"bad credentials"}`))
+		return
+	}
+	_ = r.ParseForm()
+	s.grantType = r.PostForm.Get("grant_type")
+	s.refresh = r.PostForm.Get("refresh_token")
+
+	s.issued++
+	token := fmt.Sprintf("token-%d", s.issued)
+	s.valid[token] = true
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = fmt.Fprintf(w, `{"access_token":%q,"token_type":"bearer","expires_in":3600,"refresh_token":"refresh-%d"}`,
+		token, s.issued)
+}
+
+func (s *stubTokenServer) apiHandler(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 7 || !s.valid[auth[7:]] {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	_, _ = w.Write([]byte(auth[7:]))
+}
+
+func (s *stubTokenServer) revoke() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.valid = map[string]bool{}
+}
+
+func TestOAuth2Interceptor(t *testing.T) {
+	Convey("OAuth2拦截器", t, func() {
+		stub := &stubTokenServer{valid: map[string]bool{}}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/token", stub.tokenHandler)
+		mux.HandleFunc("/api", stub.apiHandler)
+		server := httptest.NewServer(mux)
+		defer server.Close()
+
+		fakeClock := clock.NewFakePassiveClock(time.Now())
+		policy := interceptorcli.DefaultOAuth2Policy(server.URL+"/token", "client", "secret", "read")
+		source := interceptorcli.NewOAuth2TokenSourceWithClock(policy, fakeClock)
+		c, err := httpcli.NewClient(nil, httpcli.WithIntercepts(interceptorcli.OAuth2Interceptor("oauth2", source)))
+		So(err, ShouldBeNil)
+
+		invoke := func() (*httpcli.HttpResponse, error) {
+			req := httpcli.NewHttpRequestBuilder().GET().WithEndpoint(server.URL).WithPath("/api").Build()
+			return c.Invoke(context.Background(), req, nil, nil)
+		}
+
+		rawResp, err := invoke()
+		So(err, ShouldBeNil)
+		So(rawResp.GetStatusCode(), ShouldEqual, http.StatusOK)
+		So(rawResp.GetBody(), ShouldEqual, "token-1")
+		So(stub.grantType, ShouldEqual, "client_credentials")
+
+		Convey("令牌有效期内复用缓存", func() {
+			rawResp, err := invoke()
+			So(err, ShouldBeNil)
+			So(rawResp.GetBody(), ShouldEqual, "token-1")
+			So(stub.issued, ShouldEqual, 1)
+		})
+
+		Convey("令牌即将过期时提前使用refresh token刷新", func() {
+			fakeClock.SetTime(fakeClock.Now().Add(time.Hour - 10*time.Second))
+			rawResp, err := invoke()
+			So(err, ShouldBeNil)
+			So(rawResp.GetBody(), ShouldEqual, "token-2")
+			So(stub.grantType, ShouldEqual, "refresh_token")
+			So(stub.refresh, ShouldEqual, "refresh-1")
+		})
+
+		Convey("401时重新获取令牌并重试一次", func() {
+			stub.revoke()
+			rawResp, err := invoke()
+			So(err, ShouldBeNil)
+			So(rawResp.GetStatusCode(), ShouldEqual, http.StatusOK)
+			So(rawResp.GetBody(), ShouldEqual, "token-2")
+			So(stub.issued, ShouldEqual, 2)
+		})
+	})
+
+	Convey("获取令牌失败", t, func() {
+		stub := &stubTokenServer{valid: map[string]bool{}}
+		server := httptest.NewServer(http.HandlerFunc(stub.tokenHandler))
+		defer server.Close()
+
+		source := interceptorcli.NewOAuth2TokenSource(interceptorcli.DefaultOAuth2Policy(server.URL, "client", "wrong"))
+		_, err := source.Token(context.Background())
+		So(errors.IsCode(err, interceptorcli.ErrOAuth2Token), ShouldBeTrue)
+		So(err.Error(), ShouldContainSubstring, "invalid_client")
+	})
+}
@@ -0,0 +1,410 @@
+package interceptorcli
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wangweihong/gotoolbox/pkg/cache"
+	"github.com/wangweihong/gotoolbox/pkg/clock"
+	"github.com/wangweihong/gotoolbox/pkg/errors"
+
+	"github.com/wangweihong/gotoolbox/pkg/httpcli"
+	"github.com/wangweihong/gotoolbox/pkg/log"
+	"github.com/wangweihong/gotoolbox/pkg/skipper"
+)
+
+const (
+	// CacheStatusHeader 标识响应是否来自缓存, 值为HIT/REVALIDATED/MISS
+	CacheStatusHeader = "X-Httpcli-Cache"
+
+	CacheStatusHit         = "HIT"
+	CacheStatusRevalidated = "REVALIDATED"
+	CacheStatusMiss        = "MISS"
+)
+
+// NewResponseCache 创建GET响应缓存. maxEntries<=0时不限制缓存数量.
+func NewResponseCache(maxEntries int) *ResponseCache {
+	return NewResponseCacheWithClock(maxEntries, clock.RealClock{})
+}
+
+func NewResponseCacheWithClock(maxEntries int, c clock.PassiveClock) *ResponseCache {
+	return &ResponseCache{
+		store:      cache.NewThreadSafeStore(cache.Indexers{}, cache.Indices{}),
+		vary:       make(map[string][]string),
+		refs:       make(map[string]int),
+		order:      list.New(),
+		elems:      make(map[string]*list.Element),
+		maxEntries: maxEntries,
+		clock:      c,
+	}
+}
+
+// ResponseCache 基于ThreadSafeStore的响应缓存, 以请求方法+完整URL及响应Vary列出的请求头作为键.
+type ResponseCache struct {
+	store cache.ThreadSafeStore
+	// 请求方法+完整URL对应的最近一次响应的Vary头部
+	vary map[string][]string
+	// 请求方法+完整URL对应的缓存条目数, 最后一个条目被移除时移除vary中的记录
+	refs map[string]int
+	// 按写入时间排序的缓存键, 超出容量时从头部淘汰
+	order      *list.List
+	elems      map[string]*list.Element
+	maxEntries int
+	clock      clock.PassiveClock
+	// 保证淘汰与写入的原子性
+	lock sync.Mutex
+}
+
+type cacheEntry struct {
+	// 请求方法+完整URL
+	primary      string
+	statusCode   int
+	status       string
+	header       http.Header
+	body         []byte
+	storedAt     time.Time
+	expiresAt    time.Time
+	etag         string
+	lastModified string
+}
+
+func (e *cacheEntry) fresh(now time.Time) bool {
+	return now.Before(e.expiresAt)
+}
+
+func (e *cacheEntry) revalidatable() bool {
+	return e.etag != "" || e.lastModified != ""
+}
+
+// toResponse 根据缓存构建新的响应, 每次返回独立的响应体.
+func (e *cacheEntry) toResponse(req *httpcli.HttpRequest, cacheStatus string) *httpcli.HttpResponse {
+	header := e.header.Clone()
+	header.Set(CacheStatusHeader, cacheStatus)
+	resp := &http.Response{
+		Status:        e.status,
+		StatusCode:    e.statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+	}
+	return httpcli.NewHttpResponse(req, resp)
+}
+
+// Len 返回缓存条目数.
+func (c *ResponseCache) Len() int {
+	return len(c.store.ListKeys())
+}
+
+// Purge 清空缓存.
+func (c *ResponseCache) Purge() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.store.Replace(map[string]interface{}{}, "")
+	c.vary = make(map[string][]string)
+	c.refs = make(map[string]int)
+	c.order.Init()
+	c.elems = make(map[string]*list.Element)
+}
+
+func primaryKey(req *httpcli.HttpRequest) string {
+	return req.GetMethod() + " " + req.GetFullRequestAddress()
+}
+
+// key 返回请求的缓存键: 请求方法+完整URL, 以及该URL的响应Vary列出的请求头的值.
+func (c *ResponseCache) key(req *httpcli.HttpRequest) string {
+	primary := primaryKey(req)
+	c.lock.Lock()
+	names := c.vary[primary]
+	c.lock.Unlock()
+	return varyKey(primary, names, req.GetHeaderParams())
+}
+
+// addVary 记录响应的Vary头部, 并以此计算的缓存键写入缓存.
+func (c *ResponseCache) addVary(req *httpcli.HttpRequest, names []string, entry *cacheEntry) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if len(names) > 0 {
+		c.vary[entry.primary] = names
+	} else {
+		delete(c.vary, entry.primary)
+	}
+	c.addLocked(varyKey(entry.primary, names, req.GetHeaderParams()), entry)
+}
+
+func varyKey(primary string, names []string, header http.Header) string {
+	if len(names) == 0 {
+		return primary
+	}
+	var b strings.Builder
+	b.WriteString(primary)
+	for _, name := range names {
+		b.WriteString("\n")
+		b.WriteString(name)
+		b.WriteString(":")
+		b.WriteString(strings.Join(header.Values(name), ","))
+	}
+	return b.String()
+}
+
+// parseVary 解析响应的Vary头部, 返回规范化并排序的请求头名. Vary: *时返回false, 响应不可缓存.
+func parseVary(header http.Header) ([]string, bool) {
+	var names []string
+	for _, v := range header.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				return nil, false
+			}
+			if name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, true
+}
+
+// authorized 请求是否携带Authorization头部, 包括由内层拦截器或传输层添加的头部.
+func authorized(req *httpcli.HttpRequest, rawResp *httpcli.HttpResponse) bool {
+	if req.GetHeaderParams().Get("Authorization") != "" {
+		return true
+	}
+	return rawResp.Response.Request != nil && rawResp.Response.Request.Header.Get("Authorization") != ""
+}
+
+func (c *ResponseCache) get(key string) *cacheEntry {
+	obj, exists := c.store.Get(key)
+	if !exists {
+		return nil
+	}
+	return obj.(*cacheEntry)
+}
+
+func (c *ResponseCache) add(key string, entry *cacheEntry) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.addLocked(key, entry)
+}
+
+// addLocked 写入缓存, 超出容量时淘汰最早写入的条目. 条目的storedAt为写入时间, 因此写入的条目总在队尾.
+func (c *ResponseCache) addLocked(key string, entry *cacheEntry) {
+	if e, ok := c.elems[key]; ok {
+		c.order.MoveToBack(e)
+		c.store.Add(key, entry)
+		return
+	}
+
+	for c.maxEntries > 0 && c.order.Len() >= c.maxEntries {
+		c.removeLocked(c.order.Front().Value.(string))
+	}
+	c.elems[key] = c.order.PushBack(key)
+	c.refs[entry.primary]++
+	c.store.Add(key, entry)
+}
+
+func (c *ResponseCache) remove(key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.removeLocked(key)
+}
+
+// removeLocked 移除缓存条目, 该URL的最后一个条目被移除时同时移除其Vary记录.
+func (c *ResponseCache) removeLocked(key string) {
+	e, ok := c.elems[key]
+	if !ok {
+		return
+	}
+	c.order.Remove(e)
+	delete(c.elems, key)
+	if entry := c.get(key); entry != nil {
+		if c.refs[entry.primary]--; c.refs[entry.primary] <= 0 {
+			delete(c.refs, entry.primary)
+			delete(c.vary, entry.primary)
+		}
+	}
+	c.store.Delete(key)
+}
+
+// ResponseCacheInterceptor GET响应缓存拦截器.
+// 1. 遵循响应Cache-Control的max-age/no-store/no-cache, 缺少max-age时参考Expires.
+// 2. 缓存过期后通过ETag/If-None-Match或Last-Modified/If-Modified-Since重新校验, 304时复用缓存.
+// 3. 命中缓存时, 通过与HttpResponse.Decode相同的解析器将缓存响应体解码到reply.
+// 4. 缓存键包含响应Vary列出的请求头, Vary: *的响应不缓存; 携带Authorization的请求仅缓存Cache-Control: public的响应.
+// 注意: 应放在状态码拦截器之前, 以便接收304响应.
+func ResponseCacheInterceptor(name string, c *ResponseCache, skipperFunc ...skipper.SkipperFunc) httpcli.Interceptor {
+	return httpcli.NewInterceptor(name, func(ctx context.Context, req *httpcli.HttpRequest, arg, reply any, cc *httpcli.Client,
+		invoker httpcli.Invoker, opts ...httpcli.CallOption) (*httpcli.HttpResponse, error) {
+		if skipper.Skip(req.GetPath(), skipperFunc...) {
+			log.F(ctx).Debugf("skip interceptor %s for rawrurl %s", name, req.GetPath())
+
+			return invoker(ctx, req, arg, reply, cc, opts...)
+		}
+
+		if c == nil || !strings.EqualFold(req.GetMethod(), http.MethodGet) {
+			return invoker(ctx, req, arg, reply, cc, opts...)
+		}
+
+		reqDirectives := parseCacheControl(req.GetHeaderParams().Get("Cache-Control"))
+		if _, ok := reqDirectives["no-store"]; ok {
+			return invoker(ctx, req, arg, reply, cc, opts...)
+		}
+		_, forceRevalidate := reqDirectives["no-cache"]
+
+		key := c.key(req)
+		entry := c.get(key)
+		if entry != nil && !forceRevalidate && entry.fresh(c.clock.Now()) {
+			log.F(ctx).Debugf("interceptor %s cache hit %s", name, key)
+			return cachedReply(entry.toResponse(req, CacheStatusHit), reply)
+		}
+
+		// 缓存过期, 携带校验头部重新请求
+		revalidating := entry != nil && entry.revalidatable()
+		if revalidating {
+			if entry.etag != "" {
+				req.Builder().SetHeaderParam("If-None-Match", entry.etag)
+			}
+			if entry.lastModified != "" {
+				req.Builder().SetHeaderParam("If-Modified-Since", entry.lastModified)
+			}
+			defer func() {
+				req.Builder().DelHeaderParam("If-None-Match").DelHeaderParam("If-Modified-Since")
+			}()
+		}
+
+		rawResp, err := invoker(ctx, req, arg, reply, cc, opts...)
+		if rawResp == nil || rawResp.Response == nil {
+			return rawResp, errors.WithStack(err)
+		}
+
+		if revalidating && rawResp.GetStatusCode() == http.StatusNotModified {
+			// 304响应无响应体, 内层解码拦截器的错误可以忽略
+			drainResponse(rawResp)
+			// 304响应中的头部覆盖缓存中的对应头部
+			updated := *entry
+			updated.header = entry.header.Clone()
+			for k, v := range rawResp.Response.Header {
+				if k == "Content-Length" {
+					continue
+				}
+				updated.header[k] = v
+			}
+			updated.storedAt = c.clock.Now()
+			updated.expiresAt = expiresAt(updated.header, updated.storedAt)
+			c.add(key, &updated)
+			log.F(ctx).Debugf("interceptor %s cache revalidated %s", name, key)
+			return cachedReply(updated.toResponse(req, CacheStatusRevalidated), reply)
+		}
+
+		if err != nil {
+			return rawResp, errors.WithStack(err)
+		}
+
+		if rawResp.GetStatusCode() == http.StatusOK {
+			newEntry := newCacheEntry(primaryKey(req), rawResp, c.clock.Now())
+			names, cacheable := parseVary(rawResp.Response.Header)
+			if newEntry != nil && authorized(req, rawResp) {
+				// 共享缓存不能将某个用户的响应提供给其他用户
+				_, public := parseCacheControl(rawResp.Response.Header.Get("Cache-Control"))["public"]
+				cacheable = cacheable && public
+			}
+			if newEntry != nil && cacheable {
+				c.addVary(req, names, newEntry)
+			} else if entry != nil {
+				c.remove(key)
+			}
+		}
+		rawResp.Response.Header.Set(CacheStatusHeader, CacheStatusMiss)
+		return rawResp, nil
+	})
+}
+
+func cachedReply(rawResp *httpcli.HttpResponse, reply any) (*httpcli.HttpResponse, error) {
+	if reply != nil {
+		if err := rawResp.Decode(reply); err != nil {
+			return rawResp, errors.WithStack(err)
+		}
+	}
+	return rawResp, nil
+}
+
+// newCacheEntry 根据响应构建缓存条目, 响应不可缓存时返回nil.
+func newCacheEntry(primary string, rawResp *httpcli.HttpResponse, now time.Time) *cacheEntry {
+	header := rawResp.Response.Header
+	directives := parseCacheControl(header.Get("Cache-Control"))
+	if _, ok := directives["no-store"]; ok {
+		return nil
+	}
+
+	entry := &cacheEntry{
+		primary:      primary,
+		statusCode:   rawResp.GetStatusCode(),
+		status:       rawResp.GetStatus(),
+		header:       header.Clone(),
+		body:         []byte(rawResp.GetBody()),
+		storedAt:     now,
+		expiresAt:    expiresAt(header, now),
+		etag:         header.Get("ETag"),
+		lastModified: header.Get("Last-Modified"),
+	}
+	entry.header.Del(CacheStatusHeader)
+
+	// 既不新鲜也无法校验的响应没有缓存价值
+	if !entry.fresh(now) && !entry.revalidatable() {
+		return nil
+	}
+	return entry
+}
+
+// expiresAt 根据Cache-Control/Expires计算过期时间. no-cache时立即过期.
+func expiresAt(header http.Header, now time.Time) time.Time {
+	directives := parseCacheControl(header.Get("Cache-Control"))
+	if _, ok := directives["no-cache"]; ok {
+		return now
+	}
+
+	if v, ok := directives["max-age"]; ok {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+			return now.Add(time.Duration(seconds) * time.Second)
+		}
+		return now
+	}
+
+	if v := header.Get("Expires"); v != "" {
+		if t, err := http.ParseTime(v); err == nil {
+			if date, err := http.ParseTime(header.Get("Date")); err == nil {
+				// 以服务端时间计算有效期, 避免客户端时钟偏差
+				return now.Add(t.Sub(date))
+			}
+			return t
+		}
+	}
+	return now
+}
+
+// parseCacheControl 解析Cache-Control头部, 返回指令及其值.
+func parseCacheControl(value string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(part, "=")
+		directives[strings.ToLower(strings.TrimSpace(k))] = strings.Trim(strings.TrimSpace(v), `"`)
+	}
+	return directives
+}
@@ -0,0 +1,163 @@
+package interceptorcli_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/wangweihong/gotoolbox/pkg/clock"
+	"github.com/wangweihong/gotoolbox/pkg/httpcli"
+	"github.com/wangweihong/gotoolbox/pkg/httpcli/interceptorcli"
+)
+
+func TestResponseCacheInterceptor(t *testing.T) {
+	type Inventory struct {
+		Name string `json:"name"`
+	}
+
+	Convey("响应缓存拦截器", t, func() {
+		var count, notModified int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&count, 1)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				atomic.AddInt32(&notModified, 1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("ETag", `"v1"`)
+			name := "switch"
+			switch r.URL.Path {
+			case "/nostore":
+				w.Header().Set("Cache-Control", "no-store")
+			case "/vary":
+				w.Header().Set("Cache-Control", "max-age=60")
+				w.Header().Set("Vary", "Accept-Language")
+				name = r.Header.Get("Accept-Language")
+			case "/public":
+				w.Header().Set("Cache-Control", "public, max-age=60")
+			default:
+				w.Header().Set("Cache-Control", "max-age=60")
+			}
+			_, _ = w.Write([]byte(`{"name":"` + name + `"}`))
+		}))
+		defer server.Close()
+
+		fakeClock := clock.NewFakePassiveClock(time.Now())
+		rc := interceptorcli.NewResponseCacheWithClock(10, fakeClock)
+		c, err := httpcli.NewClient(nil, httpcli.WithIntercepts(
+			interceptorcli.DecodeResponseInterceptor("decode"),
+			interceptorcli.ResponseCacheInterceptor("cache", rc),
+		))
+		So(err, ShouldBeNil)
+
+		getWithHeader := func(path, key, value string) (*httpcli.HttpResponse, *Inventory, error) {
+			reply := &Inventory{}
+			builder := httpcli.NewHttpRequestBuilder().GET().WithEndpoint(server.URL).WithPath(path)
+			if key != "" {
+				builder.AddHeaderParam(key, value)
+			}
+			resp, err := c.Invoke(context.Background(), builder.Build(), nil, reply)
+			return resp, reply, err
+		}
+		get := func(path string) (*httpcli.HttpResponse, *Inventory, error) {
+			return getWithHeader(path, "", "")
+		}
+
+		Convey("max-age内命中缓存并解码", func() {
+			_, _, err := get("/inventory")
+			So(err, ShouldBeNil)
+			resp, reply, err := get("/inventory")
+			So(err, ShouldBeNil)
+			So(resp.GetHeader(interceptorcli.CacheStatusHeader), ShouldEqual, interceptorcli.CacheStatusHit)
+			So(reply.Name, ShouldEqual, "switch")
+			So(atomic.LoadInt32(&count), ShouldEqual, 1)
+		})
+
+		Convey("过期后通过ETag重新校验", func() {
+			_, _, err := get("/inventory")
+			So(err, ShouldBeNil)
+			fakeClock.SetTime(fakeClock.Now().Add(2 * time.Minute))
+			resp, reply, err := get("/inventory")
+			So(err, ShouldBeNil)
+			So(resp.GetStatusCode(), ShouldEqual, http.StatusOK)
+			So(resp.GetHeader(interceptorcli.CacheStatusHeader), ShouldEqual, interceptorcli.CacheStatusRevalidated)
+			So(reply.Name, ShouldEqual, "switch")
+			So(atomic.LoadInt32(&notModified), ShouldEqual, 1)
+
+			// 校验后重新计算有效期
+			_, _, err = get("/inventory")
+			So(err, ShouldBeNil)
+			So(atomic.LoadInt32(&count), ShouldEqual, 2)
+		})
+
+		Convey("缓存键包含Vary列出的请求头", func() {
+			_, reply, err := getWithHeader("/vary", "Accept-Language", "en")
+			So(err, ShouldBeNil)
+			So(reply.Name, ShouldEqual, "en")
+			_, reply, err = getWithHeader("/vary", "Accept-Language", "zh")
+			So(err, ShouldBeNil)
+			So(reply.Name, ShouldEqual, "zh")
+
+			resp, reply, err := getWithHeader("/vary", "Accept-Language", "en")
+			So(err, ShouldBeNil)
+			So(resp.GetHeader(interceptorcli.CacheStatusHeader), ShouldEqual, interceptorcli.CacheStatusHit)
+			So(reply.Name, ShouldEqual, "en")
+			So(atomic.LoadInt32(&count), ShouldEqual, 2)
+		})
+
+		Convey("携带Authorization的请求只缓存public响应", func() {
+			_, _, err := getWithHeader("/inventory", "Authorization", "Bearer user1")
+			So(err, ShouldBeNil)
+			So(rc.Len(), ShouldEqual, 0)
+			resp, _, err := getWithHeader("/inventory", "Authorization", "Bearer user2")
+			So(err, ShouldBeNil)
+			So(resp.GetHeader(interceptorcli.CacheStatusHeader), ShouldEqual, interceptorcli.CacheStatusMiss)
+
+			_, _, err = getWithHeader("/public", "Authorization", "Bearer user1")
+			So(err, ShouldBeNil)
+			resp, _, err = getWithHeader("/public", "Authorization", "Bearer user2")
+			So(err, ShouldBeNil)
+			So(resp.GetHeader(interceptorcli.CacheStatusHeader), ShouldEqual, interceptorcli.CacheStatusHit)
+			So(atomic.LoadInt32(&count), ShouldEqual, 3)
+		})
+
+		Convey("超出容量时淘汰最早写入的条目并移除其Vary记录", func() {
+			getVary := func(i int) *httpcli.HttpResponse {
+				req := httpcli.NewHttpRequestBuilder().GET().WithEndpoint(server.URL).WithPath("/vary").
+					AddQueryParam("i", strconv.Itoa(i)).AddHeaderParam("Accept-Language", "en").Build()
+				resp, err := c.Invoke(context.Background(), req, nil, &Inventory{})
+				So(err, ShouldBeNil)
+				return resp
+			}
+			for i := 0; i < 15; i++ {
+				getVary(i)
+			}
+			So(rc.Len(), ShouldEqual, 10)
+			So(rc.VaryLen(), ShouldEqual, 10)
+
+			So(getVary(14).GetHeader(interceptorcli.CacheStatusHeader), ShouldEqual, interceptorcli.CacheStatusHit)
+			So(getVary(0).GetHeader(interceptorcli.CacheStatusHeader), ShouldEqual, interceptorcli.CacheStatusMiss)
+			So(rc.VaryLen(), ShouldEqual, 10)
+
+			rc.Purge()
+			So(rc.VaryLen(), ShouldEqual, 0)
+		})
+
+		Convey("no-store响应不缓存", func() {
+			_, _, err := get("/nostore")
+			So(err, ShouldBeNil)
+			_, reply, err := get("/nostore")
+			So(err, ShouldBeNil)
+			So(reply.Name, ShouldEqual, "switch")
+			So(atomic.LoadInt32(&count), ShouldEqual, 2)
+			So(rc.Len(), ShouldEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,207 @@
+package interceptorcli
+
+import (
+	"context"
+	stderrors "errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/wangweihong/gotoolbox/pkg/errors"
+	"github.com/wangweihong/gotoolbox/pkg/sets"
+	"github.com/wangweihong/gotoolbox/pkg/wait"
+
+	"github.com/wangweihong/gotoolbox/pkg/httpcli"
+	"github.com/wangweihong/gotoolbox/pkg/log"
+	"github.com/wangweihong/gotoolbox/pkg/skipper"
+)
+
+// 幂等请求方法, 默认允许重试.
+var idempotentMethods = sets.NewString(
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodOptions,
+	http.MethodTrace,
+	http.MethodPut,
+	http.MethodDelete,
+)
+
+// RetryPolicy 重试策略.
+type RetryPolicy struct {
+	// 最大尝试次数(包含第一次请求), 小于等于1时不重试
+	MaxAttempts int
+	// 重试间隔退避参数. 每次请求独立复制, 不会相互影响
+	Backoff wait.Backoff
+	// 需要重试的HTTP状态码
+	RetryStatusCodes []int
+	// 服务端Retry-After指定的最长等待时间, 超过该时间则不再重试. 0表示不限制
+	MaxRetryAfter time.Duration
+}
+
+// DefaultRetryPolicy 默认重试策略: 最多尝试3次, 100ms开始指数退避并增加抖动,
+// 连接错误及429/502/503/504状态码时重试.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 3,
+		Backoff: wait.Backoff{
+			Duration: 100 * time.Millisecond,
+			Factor:   2,
+			Jitter:   0.2,
+			Steps:    10,
+			Cap:      10 * time.Second,
+		},
+		RetryStatusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		MaxRetryAfter: time.Minute,
+	}
+}
+
+// RetryInterceptor 重试拦截器. 在连接错误、被标记为可重试的错误(见errors.IsRetryable)或指定状态码时重新调用后续拦截器链.
+// 1. 仅重试幂等请求, 非幂等请求需通过HttpRequestBuilder.WithRetryable显式允许.
+// 2. 请求体不可重复读取(如流式表单文件)时不重试.
+// 3. 优先使用服务端Retry-After指定的等待时间.
+// 注意: 重试拦截器应放在解码/状态码拦截器之前, 以便检查原始响应.
+func RetryInterceptor(name string, policy *RetryPolicy, skipperFunc ...skipper.SkipperFunc) httpcli.Interceptor {
+	if policy == nil {
+		policy = DefaultRetryPolicy()
+	}
+	retryCodes := sets.NewInt(policy.RetryStatusCodes...)
+
+	return httpcli.NewInterceptor(name, func(ctx context.Context, req *httpcli.HttpRequest, arg, reply any, cc *httpcli.Client,
+		invoker httpcli.Invoker, opts ...httpcli.CallOption) (*httpcli.HttpResponse, error) {
+		if skipper.Skip(req.GetPath(), skipperFunc...) {
+			log.F(ctx).Debugf("skip interceptor %s for rawrurl %s", name, req.GetPath())
+
+			return invoker(ctx, req, arg, reply, cc, opts...)
+		}
+
+		if !IsRequestRetryable(req) {
+			return invoker(ctx, req, arg, reply, cc, opts...)
+		}
+
+		backoff := policy.Backoff
+		for attempt := 1; ; attempt++ {
+			rawResp, err := invoker(ctx, req, arg, reply, cc, opts...)
+			if attempt >= policy.MaxAttempts {
+				return rawResp, errors.WithStack(err)
+			}
+
+			retry, retryAfter := policy.shouldRetry(ctx, retryCodes, rawResp, err)
+			if !retry {
+				return rawResp, errors.WithStack(err)
+			}
+
+			delay := backoff.Step()
+			if retryAfter > delay {
+				delay = retryAfter
+			}
+			log.F(ctx).Debugf("interceptor %s retry %s %s after %v, attempt %d/%d, err:%v",
+				name, req.GetMethod(), req.GetPath(), delay, attempt, policy.MaxAttempts, err)
+
+			// 丢弃本次响应, 释放连接
+			drainResponse(rawResp)
+
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, errors.WithStack(ctx.Err())
+			case <-timer.C:
+			}
+		}
+	})
+}
+
+// IsRequestRetryable 请求是否允许重试: 幂等请求或显式允许重试的请求, 且请求体可以重复读取.
+func IsRequestRetryable(req *httpcli.HttpRequest) bool {
+	if !req.IsBodyRewindable() {
+		return false
+	}
+	return req.IsRetryable() || idempotentMethods.Has(strings.ToUpper(req.GetMethod()))
+}
+
+// shouldRetry 判断是否需要重试, 并返回服务端要求的等待时间.
+func (p *RetryPolicy) shouldRetry(ctx context.Context, retryCodes sets.Int, rawResp *httpcli.HttpResponse, err error) (bool, time.Duration) {
+	if ctx.Err() != nil {
+		return false, 0
+	}
+
+	// 收到响应后仍可能失败, 如下载时读取响应体中断, 被标记为可重试的错误直接重试
+	if err != nil && errors.IsRetryable(err) {
+		return true, 0
+	}
+
+	if rawResp != nil && rawResp.Response != nil {
+		if !retryCodes.Has(rawResp.GetStatusCode()) {
+			return false, 0
+		}
+
+		retryAfter, ok := ParseRetryAfter(rawResp.GetHeader("Retry-After"), time.Now())
+		if ok && p.MaxRetryAfter > 0 && retryAfter > p.MaxRetryAfter {
+			return false, 0
+		}
+		return true, retryAfter
+	}
+
+	return IsConnectionError(err) || errors.IsRetryable(err), 0
+}
+
+// ParseRetryAfter 解析Retry-After头部, 支持秒数及HTTP日期两种格式.
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// IsConnectionError 是否为连接类错误(连接拒绝/重置/超时/连接意外断开).
+// context撤销或超时不属于连接错误.
+func IsConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if stderrors.Is(err, context.Canceled) || stderrors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if stderrors.Is(err, io.EOF) || stderrors.Is(err, io.ErrUnexpectedEOF) ||
+		stderrors.Is(err, syscall.ECONNREFUSED) || stderrors.Is(err, syscall.ECONNRESET) ||
+		stderrors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	return stderrors.As(err, &netErr)
+}
+
+func drainResponse(rawResp *httpcli.HttpResponse) {
+	if rawResp == nil || rawResp.Response == nil || rawResp.Response.Body == nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(rawResp.Response.Body, 4096))
+	_ = rawResp.Response.Body.Close()
+}
@@ -0,0 +1,149 @@
+package interceptorcli_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/wangweihong/gotoolbox/pkg/httpcli"
+	"github.com/wangweihong/gotoolbox/pkg/httpcli/interceptorcli"
+	"github.com/wangweihong/gotoolbox/pkg/wait"
+)
+
+func testRetryPolicy() *interceptorcli.RetryPolicy {
+	p := interceptorcli.DefaultRetryPolicy()
+	p.Backoff = wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 10}
+	return p
+}
+
+func TestRetryInterceptor(t *testing.T) {
+	Convey("重试拦截器", t, func() {
+		Convey("状态码503时重试, 直到成功", func() {
+			var count int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&count, 1) < 3 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				_, _ = w.Write([]byte("ok"))
+			}))
+			defer server.Close()
+
+			c, err := httpcli.NewClient(nil, httpcli.WithIntercepts(interceptorcli.RetryInterceptor("retry", testRetryPolicy())))
+			So(err, ShouldBeNil)
+
+			req := httpcli.NewHttpRequestBuilder().GET().WithEndpoint(server.URL).Build()
+			resp, err := c.Invoke(context.Background(), req, nil, nil)
+			So(err, ShouldBeNil)
+			So(resp.GetStatusCode(), ShouldEqual, http.StatusOK)
+			So(resp.GetBody(), ShouldEqual, "ok")
+			So(atomic.LoadInt32(&count), ShouldEqual, 3)
+		})
+
+		Convey("超过最大尝试次数返回最后一次响应", func() {
+			var count int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&count, 1)
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+			}))
+			defer server.Close()
+
+			c, err := httpcli.NewClient(nil, httpcli.WithIntercepts(interceptorcli.RetryInterceptor("retry", testRetryPolicy())))
+			So(err, ShouldBeNil)
+
+			req := httpcli.NewHttpRequestBuilder().GET().WithEndpoint(server.URL).Build()
+			resp, err := c.Invoke(context.Background(), req, nil, nil)
+			So(err, ShouldBeNil)
+			So(resp.GetStatusCode(), ShouldEqual, http.StatusTooManyRequests)
+			So(atomic.LoadInt32(&count), ShouldEqual, 3)
+		})
+
+		Convey("非幂等请求默认不重试, 显式允许后重试并重新发送文件请求体", func() {
+			var count int32
+			var lastBody string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				lastBody = string(b)
+				if atomic.AddInt32(&count, 1) != 3 {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+			}))
+			defer server.Close()
+
+			fp := filepath.Join(t.TempDir(), "body")
+			So(os.WriteFile(fp, []byte("file content"), 0o644), ShouldBeNil)
+			f, err := os.Open(fp)
+			So(err, ShouldBeNil)
+
+			c, err := httpcli.NewClient(nil, httpcli.WithIntercepts(interceptorcli.RetryInterceptor("retry", testRetryPolicy())))
+			So(err, ShouldBeNil)
+
+			req := httpcli.NewHttpRequestBuilder().POST().WithEndpoint(server.URL).WithBody("", f).Build()
+			resp, err := c.Invoke(context.Background(), req, nil, nil)
+			So(err, ShouldBeNil)
+			So(resp.GetStatusCode(), ShouldEqual, http.StatusBadGateway)
+			So(atomic.LoadInt32(&count), ShouldEqual, 1)
+			// 文件请求体在Invoke结束后关闭
+			So(errors.Is(f.Close(), os.ErrClosed), ShouldBeTrue)
+
+			f, err = os.Open(fp)
+			So(err, ShouldBeNil)
+			req = httpcli.NewHttpRequestBuilder().POST().WithEndpoint(server.URL).WithBody("", f).WithRetryable(true).Build()
+			resp, err = c.Invoke(context.Background(), req, nil, nil)
+			So(err, ShouldBeNil)
+			So(resp.GetStatusCode(), ShouldEqual, http.StatusOK)
+			So(atomic.LoadInt32(&count), ShouldEqual, 3)
+			So(lastBody, ShouldEqual, "file content")
+			So(errors.Is(f.Close(), os.ErrClosed), ShouldBeTrue)
+		})
+
+		Convey("连接错误时重试", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			endpoint := server.URL
+			server.Close()
+
+			var count int32
+			counter := httpcli.NewInterceptor("counter", func(ctx context.Context, req *httpcli.HttpRequest, arg, reply any, cc *httpcli.Client,
+				invoker httpcli.Invoker, opts ...httpcli.CallOption) (*httpcli.HttpResponse, error) {
+				atomic.AddInt32(&count, 1)
+				return invoker(ctx, req, arg, reply, cc, opts...)
+			})
+
+			c, err := httpcli.NewClient(nil, httpcli.WithIntercepts(interceptorcli.RetryInterceptor("retry", testRetryPolicy()), counter))
+			So(err, ShouldBeNil)
+
+			req := httpcli.NewHttpRequestBuilder().GET().WithEndpoint(endpoint).Build()
+			_, err = c.Invoke(context.Background(), req, nil, nil)
+			So(err, ShouldNotBeNil)
+			So(atomic.LoadInt32(&count), ShouldEqual, 3)
+		})
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	Convey("解析Retry-After", t, func() {
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		d, ok := interceptorcli.ParseRetryAfter("5", now)
+		So(ok, ShouldBeTrue)
+		So(d, ShouldEqual, 5*time.Second)
+
+		d, ok = interceptorcli.ParseRetryAfter(now.Add(10*time.Second).Format(http.TimeFormat), now)
+		So(ok, ShouldBeTrue)
+		So(d, ShouldEqual, 10*time.Second)
+
+		_, ok = interceptorcli.ParseRetryAfter("abc", now)
+		So(ok, ShouldBeFalse)
+	})
+}
@@ -0,0 +1,370 @@
+package interceptorcli
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wangweihong/gotoolbox/pkg/errors"
+	"github.com/wangweihong/gotoolbox/pkg/hash"
+	"github.com/wangweihong/gotoolbox/pkg/httpcli"
+	"github.com/wangweihong/gotoolbox/pkg/log"
+	"github.com/wangweihong/gotoolbox/pkg/skipper"
+)
+
+// RequestSigner 对最终的http请求签名.
+type RequestSigner func(r *http.Request, t time.Time) error
+
+// SignInterceptor 请求签名拦截器. 签名在请求转换为http请求后执行, 因此包含所有拦截器及客户端请求处理对请求的修改;
+// 与重试拦截器配合时, 每次重试都会重新签名.
+func SignInterceptor(name string, signer RequestSigner, skipperFunc ...skipper.SkipperFunc) httpcli.Interceptor {
+	return httpcli.NewInterceptor(name, func(ctx context.Context, req *httpcli.HttpRequest, arg, reply any, cc *httpcli.Client,
+		invoker httpcli.Invoker, opts ...httpcli.CallOption) (*httpcli.HttpResponse, error) {
+		if skipper.Skip(req.GetPath(), skipperFunc...) {
+			log.F(ctx).Debugf("skip interceptor %s for rawrurl %s", name, req.GetPath())
+
+			return invoker(ctx, req, arg, reply, cc, opts...)
+		}
+
+		signOpt := httpcli.CallOptionAddHttpRequestProcess(func(r *http.Request) (*http.Request, error) {
+			return r, signer(r, time.Now())
+		})
+		// 避免修改调用方opts的底层数组
+		opts = append(opts[:len(opts):len(opts)], signOpt)
+
+		rawResp, err := invoker(ctx, req, arg, reply, cc, opts...)
+		return rawResp, errors.WithStack(err)
+	})
+}
+
+// SigV4Policy AWS Signature Version 4签名配置, 适用于S3兼容存储(如MinIO)等.
+type SigV4Policy struct {
+	AccessKey string
+	SecretKey string
+	// SessionToken 临时凭证的会话令牌, 可选
+	SessionToken string
+	Region       string
+	// Service 服务名, 如s3
+	Service string
+	// UnsignedPayload 不计算请求体摘要, 用于流式上传等无法重复读取请求体的场景. 仅S3支持
+	UnsignedPayload bool
+}
+
+// SigV4Interceptor AWS Signature Version 4签名拦截器.
+func SigV4Interceptor(name string, policy SigV4Policy, skipperFunc ...skipper.SkipperFunc) httpcli.Interceptor {
+	return SignInterceptor(name, func(r *http.Request, t time.Time) error {
+		return SignV4(r, policy, t)
+	}, skipperFunc...)
+}
+
+const (
+	sigV4Algorithm       = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat      = "20060102T150405Z"
+	sigV4DateFormat      = "20060102"
+	sigV4UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// 不参与签名的头部, 这些头部可能被代理或传输层修改.
+var sigV4IgnoredHeaders = map[string]bool{
+	"authorization":   true,
+	"user-agent":      true,
+	"x-amzn-trace-id": true,
+	"expect":          true,
+}
+
+// SignV4 使用AWS Signature Version 4对请求签名, 设置Authorization/X-Amz-Date等头部.
+func SignV4(r *http.Request, policy SigV4Policy, t time.Time) error {
+	t = t.UTC()
+	amzDate := t.Format(sigV4TimeFormat)
+	date := t.Format(sigV4DateFormat)
+	isS3 := policy.Service == "s3"
+
+	payloadHash := sigV4UnsignedPayload
+	if !policy.UnsignedPayload || !isS3 {
+		body, err := readSignBody(r)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		payloadHash, _ = hash.NewSha256().Sum(string(body))
+	}
+
+	r.Header.Del("Authorization")
+	r.Header.Set("X-Amz-Date", amzDate)
+	if isS3 {
+		r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+	if policy.SessionToken != "" {
+		r.Header.Set("X-Amz-Security-Token", policy.SessionToken)
+	}
+
+	signedHeaders, headers := canonicalHeaders(r, func(key string) bool {
+		return !sigV4IgnoredHeaders[key]
+	})
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		canonicalURI(r.URL, !isS3),
+		canonicalQuery(r.URL),
+		headers,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, policy.Region, policy.Service, "aws4_request"}, "/")
+	canonicalHash, _ := hash.NewSha256().Sum(canonicalRequest)
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, canonicalHash}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+policy.SecretKey), date)
+	key = hmacSHA256(key, policy.Region)
+	key = hmacSHA256(key, policy.Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := fmt.Sprintf("%x", hmacSHA256(key, stringToSign))
+
+	r.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, policy.AccessKey, scope, signedHeaders, signature))
+	return nil
+}
+
+// HMACSignPolicy 通用HMAC-SHA256头部签名配置. 默认规范请求为:
+//
+//	Method\nCanonicalURI\nCanonicalQuery\nCanonicalHeaders\nSignedHeaders\nHex(SHA256(Body))
+//
+// 待签名字符串为`Algorithm\nTimestamp\nHex(SHA256(CanonicalRequest))`, 签名为pkg/hash HmacSum的base64结果,
+// 签名头部默认为`Algorithm Access=AccessKey, SignedHeaders=h1;h2, Signature=signature`.
+type HMACSignPolicy struct {
+	AccessKey string
+	SecretKey string
+	// Algorithm 算法标识, 默认HMAC-SHA256
+	Algorithm string
+	// TimestampHeader 时间戳头部, 默认X-Date
+	TimestampHeader string
+	// TimestampFormat 时间戳格式, 默认20060102T150405Z
+	TimestampFormat string
+	// SignatureHeader 签名头部, 默认Authorization
+	SignatureHeader string
+	// SignedHeaders 参与签名的头部, host及时间戳头部总是参与签名. 为空时所有头部参与签名
+	SignedHeaders []string
+	// ExcludeQuery 规范请求不包含查询参数
+	ExcludeQuery bool
+	// ExcludeBody 规范请求不包含请求体摘要
+	ExcludeBody bool
+	// EscapePathTwice 路径每段编码两次
+	EscapePathTwice bool
+	// CanonicalRequest 自定义规范请求, 设置后忽略上述规范化规则
+	CanonicalRequest func(r *http.Request, body []byte, signedHeaders string) string
+	// Authorization 自定义签名头部的值
+	Authorization func(accessKey, signedHeaders, signature string) string
+}
+
+// DefaultHMACSignPolicy 默认HMAC签名配置.
+func DefaultHMACSignPolicy(accessKey, secretKey string) HMACSignPolicy {
+	return HMACSignPolicy{
+		AccessKey:       accessKey,
+		SecretKey:       secretKey,
+		Algorithm:       "HMAC-SHA256",
+		TimestampHeader: "X-Date",
+		TimestampFormat: sigV4TimeFormat,
+		SignatureHeader: "Authorization",
+	}
+}
+
+// HMACSignInterceptor 通用HMAC头部签名拦截器.
+func HMACSignInterceptor(name string, policy HMACSignPolicy, skipperFunc ...skipper.SkipperFunc) httpcli.Interceptor {
+	return SignInterceptor(name, func(r *http.Request, t time.Time) error {
+		return SignHMAC(r, policy, t)
+	}, skipperFunc...)
+}
+
+// SignHMAC 按policy对请求签名.
+func SignHMAC(r *http.Request, policy HMACSignPolicy, t time.Time) error {
+	def := DefaultHMACSignPolicy(policy.AccessKey, policy.SecretKey)
+	if policy.Algorithm == "" {
+		policy.Algorithm = def.Algorithm
+	}
+	if policy.TimestampHeader == "" {
+		policy.TimestampHeader = def.TimestampHeader
+	}
+	if policy.TimestampFormat == "" {
+		policy.TimestampFormat = def.TimestampFormat
+	}
+	if policy.SignatureHeader == "" {
+		policy.SignatureHeader = def.SignatureHeader
+	}
+
+	timestamp := t.UTC().Format(policy.TimestampFormat)
+	r.Header.Del(policy.SignatureHeader)
+	r.Header.Set(policy.TimestampHeader, timestamp)
+
+	include := func(key string) bool { return key != strings.ToLower(policy.SignatureHeader) }
+	if len(policy.SignedHeaders) != 0 {
+		keys := map[string]bool{"host": true, strings.ToLower(policy.TimestampHeader): true}
+		for _, k := range policy.SignedHeaders {
+			keys[strings.ToLower(k)] = true
+		}
+		include = func(key string) bool { return keys[key] }
+	}
+	signedHeaders, headers := canonicalHeaders(r, include)
+
+	var body []byte
+	if policy.CanonicalRequest != nil || !policy.ExcludeBody {
+		var err error
+		if body, err = readSignBody(r); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	var canonicalRequest string
+	if policy.CanonicalRequest != nil {
+		canonicalRequest = policy.CanonicalRequest(r, body, signedHeaders)
+	} else {
+		query := ""
+		if !policy.ExcludeQuery {
+			query = canonicalQuery(r.URL)
+		}
+		bodyHash := ""
+		if !policy.ExcludeBody {
+			bodyHash, _ = hash.NewSha256().Sum(string(body))
+		}
+		canonicalRequest = strings.Join([]string{
+			r.Method, canonicalURI(r.URL, policy.EscapePathTwice), query, headers, signedHeaders, bodyHash,
+		}, "\n")
+	}
+
+	canonicalHash, _ := hash.NewSha256().Sum(canonicalRequest)
+	signature, err := hash.NewSha256().HmacSum(strings.Join([]string{policy.Algorithm, timestamp, canonicalHash}, "\n"), policy.SecretKey)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	value := fmt.Sprintf("%s Access=%s, SignedHeaders=%s, Signature=%s", policy.Algorithm, policy.AccessKey, signedHeaders, signature)
+	if policy.Authorization != nil {
+		value = policy.Authorization(policy.AccessKey, signedHeaders, signature)
+	}
+	r.Header.Set(policy.SignatureHeader, value)
+	return nil
+}
+
+// hmacSHA256 SigV4派生签名密钥需要原始字节, 不能使用base64编码的HmacSum结果.
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// readSignBody 读取请求体用于计算摘要, 并恢复请求体.
+func readSignBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	if r.GetBody != nil {
+		rc, err := r.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
+
+// canonicalHeaders 返回参与签名的头部列表(小写, 分号分隔)及规范头部.
+func canonicalHeaders(r *http.Request, include func(key string) bool) (string, string) {
+	values := make(map[string]string)
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+	values["host"] = host
+
+	for k, vs := range r.Header {
+		key := strings.ToLower(k)
+		if key == "host" || !include(key) {
+			continue
+		}
+		trimmed := make([]string, 0, len(vs))
+		for _, v := range vs {
+			trimmed = append(trimmed, strings.Join(strings.Fields(v), " "))
+		}
+		values[key] = strings.Join(trimmed, ",")
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	b := &strings.Builder{}
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteString(":")
+		b.WriteString(values[k])
+		b.WriteString("\n")
+	}
+	return strings.Join(keys, ";"), b.String()
+}
+
+// canonicalURI 按RFC 3986编码路径, 保留'/'. twice为true时每段编码两次.
+func canonicalURI(u *url.URL, twice bool) string {
+	path := u.Path
+	if path == "" {
+		return "/"
+	}
+	path = uriEncode(path, false)
+	if twice {
+		path = uriEncode(path, false)
+	}
+	return path
+}
+
+// canonicalQuery 按参数名及参数值排序并编码查询参数.
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(query))
+	for _, k := range keys {
+		vs := append([]string{}, query[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			pairs = append(pairs, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode 除A-Za-z0-9-_.~外的字符均编码为%XX.
+func uriEncode(s string, encodeSlash bool) string {
+	b := &strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(b, "%%%02X", c)
+	}
+	return b.String()
+}
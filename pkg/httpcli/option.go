@@ -58,6 +58,20 @@ func WithTransport(tp *http.Transport) Option {
 	}
 }
 
+// WithRoundTripper 使用自定义传输层, 如recorder.Recorder.
+func WithRoundTripper(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.config.RoundTripper = rt
+	}
+}
+
+// WithBalancer 设置客户端负载均衡器. 未指定endpoint的请求由负载均衡器选择后端.
+func WithBalancer(b *Balancer) Option {
+	return func(c *Client) {
+		c.balancer = b
+	}
+}
+
 func WithOTEL() Option {
 	return func(c *Client) {
 		c.config.EnableOTEL = true
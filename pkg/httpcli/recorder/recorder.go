@@ -0,0 +1,444 @@
+package recorder
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/wangweihong/gotoolbox/pkg/errors"
+	"github.com/wangweihong/gotoolbox/pkg/redact"
+)
+
+type Mode int
+
+const (
+	// ModeReplay 只从磁带回放, 没有匹配的记录时返回错误, 不访问网络.
+	ModeReplay Mode = iota
+	// ModeRecord 访问真实服务并记录所有请求/响应, 覆盖已有磁带.
+	ModeRecord
+	// ModeReplayOrRecord 磁带文件存在时回放, 否则记录.
+	ModeReplayOrRecord
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeReplay:
+		return "replay"
+	case ModeRecord:
+		return "record"
+	case ModeReplayOrRecord:
+		return "replay-or-record"
+	}
+	return "unknown"
+}
+
+const (
+	bodyEncodingBase64 = "base64"
+)
+
+// RecordedRequest 记录的请求.
+type RecordedRequest struct {
+	Method       string      `json:"method"`
+	URL          string      `json:"url"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"bodyEncoding,omitempty"`
+	// 请求体sha256摘要, 用于按请求体匹配
+	BodyHash string `json:"bodyHash,omitempty"`
+}
+
+// RecordedResponse 记录的响应.
+type RecordedResponse struct {
+	StatusCode   int         `json:"statusCode"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"bodyEncoding,omitempty"`
+}
+
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// Cassette 磁带, 保存一组按顺序记录的请求/响应.
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// MatchFunc 判断请求是否与记录的请求匹配. body为请求体数据.
+type MatchFunc func(r *http.Request, body []byte, recorded *RecordedRequest) bool
+
+// MatchMethod 匹配请求方法.
+func MatchMethod(r *http.Request, _ []byte, recorded *RecordedRequest) bool {
+	return r.Method == recorded.Method
+}
+
+// MatchPath 匹配请求主机及路径.
+func MatchPath(r *http.Request, _ []byte, recorded *RecordedRequest) bool {
+	u, err := parseURL(recorded.URL)
+	if err != nil {
+		return false
+	}
+	return r.URL.Host == u.Host && r.URL.Path == u.Path
+}
+
+// MatchQuery 匹配查询参数, 不区分参数顺序.
+func MatchQuery(r *http.Request, _ []byte, recorded *RecordedRequest) bool {
+	u, err := parseURL(recorded.URL)
+	if err != nil {
+		return false
+	}
+	return r.URL.Query().Encode() == u.Query().Encode()
+}
+
+// MatchBodyHash 匹配请求体摘要.
+func MatchBodyHash(_ *http.Request, body []byte, recorded *RecordedRequest) bool {
+	return bodyHash(body) == recorded.BodyHash
+}
+
+// DefaultMatchers 默认按请求方法/路径/查询参数匹配.
+func DefaultMatchers() []MatchFunc {
+	return []MatchFunc{MatchMethod, MatchPath, MatchQuery}
+}
+
+type Option func(*Recorder)
+
+// WithMatchers 设置回放时的请求匹配规则, 所有规则均匹配才认为请求匹配.
+func WithMatchers(matchers ...MatchFunc) Option {
+	return func(r *Recorder) {
+		r.matchers = matchers
+	}
+}
+
+// WithRealTransport 设置记录时访问真实服务的传输层, 默认http.DefaultTransport.
+func WithRealTransport(rt http.RoundTripper) Option {
+	return func(r *Recorder) {
+		if rt != nil {
+			r.real = rt
+		}
+	}
+}
+
+// WithIgnoreHeaders 记录时不保存指定的请求/响应头部, 如Authorization等敏感信息.
+func WithIgnoreHeaders(keys ...string) Option {
+	return func(r *Recorder) {
+		for _, k := range keys {
+			r.ignoreHeaders = append(r.ignoreHeaders, http.CanonicalHeaderKey(k))
+		}
+	}
+}
+
+// WithRedactPolicy 设置记录时头部、URL及请求/响应体的脱敏策略, 默认使用redact.Default().
+func WithRedactPolicy(policy *redact.Policy) Option {
+	return func(r *Recorder) {
+		r.policy = policy
+	}
+}
+
+// WithoutRedact 记录时原样保存头部、URL及请求/响应体, 不脱敏. 注意磁带中将包含凭证.
+func WithoutRedact() Option {
+	return func(r *Recorder) {
+		r.noRedact = true
+	}
+}
+
+// Recorder 实现http.RoundTripper的记录/回放传输层.
+// 通过httpcli.WithRoundTripper/httpcli.CallOptionRoundTripper使用, 使依赖外部服务的测试可离线确定性运行.
+// 磁带文件为JSON格式, 记录时默认脱敏Authorization/Cookie/API Key等敏感头部, URL中的敏感查询参数,
+// 以及表单/JSON请求及响应体中的敏感字段(如client_secret、password、access_token). 回放时按脱敏后的URL匹配.
+type Recorder struct {
+	lock          sync.Mutex
+	path          string
+	mode          Mode
+	real          http.RoundTripper
+	matchers      []MatchFunc
+	ignoreHeaders []string
+	// 脱敏策略, 为空时使用redact.Default()
+	policy   *redact.Policy
+	noRedact bool
+	cassette *Cassette
+	// 回放时已使用的记录
+	used map[int]bool
+}
+
+// New 创建记录/回放传输层. ModeReplay模式下磁带文件必须存在.
+func New(cassettePath string, mode Mode, opts ...Option) (*Recorder, error) {
+	r := &Recorder{
+		path:     cassettePath,
+		mode:     mode,
+		real:     http.DefaultTransport,
+		matchers: DefaultMatchers(),
+		cassette: &Cassette{},
+		used:     make(map[int]bool),
+	}
+	for _, o := range opts {
+		o(r)
+	}
+
+	if r.mode == ModeReplayOrRecord {
+		r.mode = ModeRecord
+		if _, err := os.Stat(cassettePath); err == nil {
+			r.mode = ModeReplay
+		}
+	}
+
+	if r.mode == ModeReplay {
+		cassette, err := Load(cassettePath)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		r.cassette = cassette
+	}
+	return r, nil
+}
+
+// Mode 实际工作模式.
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// Cassette 返回当前磁带.
+func (r *Recorder) Cassette() *Cassette {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.cassette
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if r.mode == ModeReplay {
+		return r.replay(req, body)
+	}
+	return r.record(req, body)
+}
+
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	// 优先使用未回放过的记录, 以支持相同请求多次调用返回不同响应(如重试); 记录用尽后重复回放最后一个匹配的记录
+	matched := -1
+	// 磁带中的URL已脱敏, 使用脱敏后的URL匹配
+	matchReq := r.redactURL(req)
+	for i, interaction := range r.cassette.Interactions {
+		if !r.match(matchReq, body, &interaction.Request) {
+			continue
+		}
+		matched = i
+		if !r.used[i] {
+			break
+		}
+	}
+	if matched < 0 {
+		return nil, errors.Errorf("no recorded interaction matches %s %s in cassette %v", req.Method, req.URL.String(), r.path)
+	}
+	r.used[matched] = true
+
+	return r.cassette.Interactions[matched].Response.toResponse(req)
+}
+
+func (r *Recorder) match(req *http.Request, body []byte, recorded *RecordedRequest) bool {
+	for _, m := range r.matchers {
+		if !m(req, body, recorded) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	resp, err := r.real.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	interaction := &Interaction{
+		Request: RecordedRequest{
+			Method:   req.Method,
+			URL:      r.redactURL(req).URL.String(),
+			Header:   r.filterHeader(req.Header),
+			BodyHash: bodyHash(body),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     r.filterHeader(resp.Header),
+		},
+	}
+	interaction.Request.Body, interaction.Request.BodyEncoding = encodeBody(r.redactBody(req.Header, body))
+	interaction.Response.Body, interaction.Response.BodyEncoding = encodeBody(r.redactBody(resp.Header, respBody))
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	// 每次记录后保存, 测试异常退出时不会丢失已记录的数据
+	if err := r.cassette.Save(r.path); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return resp, nil
+}
+
+// redactPolicy 返回脱敏策略, 关闭脱敏时返回nil.
+func (r *Recorder) redactPolicy() *redact.Policy {
+	if r.noRedact {
+		return nil
+	}
+	if r.policy == nil {
+		return redact.Default()
+	}
+	return r.policy
+}
+
+func (r *Recorder) filterHeader(header http.Header) http.Header {
+	h := header.Clone()
+	for _, k := range r.ignoreHeaders {
+		h.Del(k)
+	}
+	if policy := r.redactPolicy(); policy != nil {
+		return policy.Header(h)
+	}
+	return h
+}
+
+// redactURL 返回URL脱敏后的请求浅拷贝.
+func (r *Recorder) redactURL(req *http.Request) *http.Request {
+	policy := r.redactPolicy()
+	if policy == nil {
+		return req
+	}
+	u, err := url.Parse(policy.URL(req.URL.String()))
+	if err != nil {
+		return req
+	}
+	redacted := *req
+	redacted.URL = u
+	return &redacted
+}
+
+// redactBody 脱敏表单/JSON等文本请求或响应体. 没有敏感内容时原样返回, 避免重新编码改变回放的内容.
+func (r *Recorder) redactBody(header http.Header, body []byte) []byte {
+	policy := r.redactPolicy()
+	if policy == nil || len(body) == 0 || !utf8.Valid(body) {
+		return body
+	}
+	contentType := header.Get("Content-Type")
+	redacted := policy.Body(contentType, body)
+	// 使用不同的mask再次脱敏, 结果相同说明没有内容被替换
+	if bytes.Equal(redacted, policy.With(redact.WithMask(policy.Mask()+"#")).Body(contentType, body)) {
+		return body
+	}
+	return redacted
+}
+
+// Load 加载磁带文件.
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	cassette := &Cassette{}
+	if err := json.Unmarshal(data, cassette); err != nil {
+		return nil, errors.Errorf("decode cassette %v error:%v", path, err)
+	}
+	return cassette, nil
+}
+
+// Save 保存磁带到文件. 先写入临时文件再重命名, 避免保存中断导致磁带损坏.
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.WithStack(err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(tmp, path))
+}
+
+func (rr *RecordedResponse) toResponse(req *http.Request) (*http.Response, error) {
+	body, err := decodeBody(rr.Body, rr.BodyEncoding)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &http.Response{
+		Status:        strings.TrimSpace(http.StatusText(rr.StatusCode)),
+		StatusCode:    rr.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rr.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func parseURL(rawURL string) (*url.URL, error) {
+	return url.Parse(rawURL)
+}
+
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func bodyHash(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// encodeBody 文本数据原样保存, 便于阅读和修改磁带; 二进制数据使用base64编码.
+func encodeBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), bodyEncodingBase64
+}
+
+func decodeBody(body, encoding string) ([]byte, error) {
+	if encoding == bodyEncodingBase64 {
+		return base64.StdEncoding.DecodeString(body)
+	}
+	return []byte(body), nil
+}
@@ -0,0 +1,215 @@
+package recorder_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/wangweihong/gotoolbox/pkg/httpcli"
+	"github.com/wangweihong/gotoolbox/pkg/httpcli/interceptorcli"
+	"github.com/wangweihong/gotoolbox/pkg/httpcli/recorder"
+)
+
+func TestRecorder(t *testing.T) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&count, 1)
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"count":%d,"query":%q,"body":%q}`, n, r.URL.RawQuery, string(body))
+	}))
+	defer server.Close()
+
+	type reply struct {
+		Count int    `json:"count"`
+		Query string `json:"query"`
+		Body  string `json:"body"`
+	}
+
+	call := func(c *httpcli.Client, query, body string) (*reply, error) {
+		builder := httpcli.NewHttpRequestBuilder().POST().WithEndpoint(server.URL).WithPath("/vendor/api")
+		if query != "" {
+			builder.AddQueryParam("name", query)
+		}
+		req := builder.WithBody("", body).Build()
+
+		r := &reply{}
+		resp, err := c.Invoke(context.Background(), req, nil, r)
+		if err != nil {
+			return nil, err
+		}
+		return r, resp.Decode(r)
+	}
+
+	Convey("记录/回放传输层", t, func() {
+		cassette := filepath.Join(t.TempDir(), "vendor.json")
+		atomic.StoreInt32(&count, 0)
+
+		rec, err := recorder.New(cassette, recorder.ModeReplayOrRecord, recorder.WithIgnoreHeaders("Authorization"))
+		So(err, ShouldBeNil)
+		So(rec.Mode(), ShouldEqual, recorder.ModeRecord)
+
+		c, err := httpcli.NewClient(nil, httpcli.WithRoundTripper(rec))
+		So(err, ShouldBeNil)
+		for _, q := range []string{"a", "a", "b"} {
+			_, err := call(c, q, "body-"+q)
+			So(err, ShouldBeNil)
+		}
+		So(atomic.LoadInt32(&count), ShouldEqual, 3)
+
+		loaded, err := recorder.Load(cassette)
+		So(err, ShouldBeNil)
+		So(len(loaded.Interactions), ShouldEqual, 3)
+
+		Convey("回放时不访问网络, 相同请求按记录顺序返回", func() {
+			rep, err := recorder.New(cassette, recorder.ModeReplayOrRecord)
+			So(err, ShouldBeNil)
+			So(rep.Mode(), ShouldEqual, recorder.ModeReplay)
+
+			c, err := httpcli.NewClient(nil, httpcli.WithRoundTripper(rep))
+			So(err, ShouldBeNil)
+
+			r, err := call(c, "b", "")
+			So(err, ShouldBeNil)
+			So(r.Count, ShouldEqual, 3)
+
+			r, err = call(c, "a", "")
+			So(err, ShouldBeNil)
+			So(r.Count, ShouldEqual, 1)
+			r, err = call(c, "a", "")
+			So(err, ShouldBeNil)
+			So(r.Count, ShouldEqual, 2)
+			// 记录用尽后重复返回最后一个匹配记录
+			r, err = call(c, "a", "")
+			So(err, ShouldBeNil)
+			So(r.Count, ShouldEqual, 2)
+
+			_, err = call(c, "c", "")
+			So(err, ShouldNotBeNil)
+			So(atomic.LoadInt32(&count), ShouldEqual, 3)
+		})
+
+		Convey("按请求体摘要匹配", func() {
+			rep, err := recorder.New(cassette, recorder.ModeReplay,
+				recorder.WithMatchers(recorder.MatchMethod, recorder.MatchPath, recorder.MatchBodyHash))
+			So(err, ShouldBeNil)
+
+			r, err := httpcli.NewHttpRequestBuilder().POST().WithEndpoint(server.URL).WithPath("/vendor/api").
+				WithBody("", "body-b").Build().Invoke(httpcli.CallOptionRoundTripper(rep))
+			So(err, ShouldBeNil)
+			So(r.GetBody(), ShouldContainSubstring, `"count":3`)
+
+			_, err = httpcli.NewHttpRequestBuilder().POST().WithEndpoint(server.URL).WithPath("/vendor/api").
+				WithBody("", "body-x").Build().Invoke(httpcli.CallOptionRoundTripper(rep))
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("磁带不存在时回放失败", func() {
+			_, err := recorder.New(filepath.Join(t.TempDir(), "missing.json"), recorder.ModeReplay)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestRecorderRedact(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1"})
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	record := func(opts ...recorder.Option) *recorder.Interaction {
+		cassette := filepath.Join(t.TempDir(), "vendor.json")
+		rec, err := recorder.New(cassette, recorder.ModeRecord, opts...)
+		So(err, ShouldBeNil)
+
+		_, err = httpcli.NewHttpRequestBuilder().GET().WithEndpoint(server.URL).WithPath("/vendor/api").
+			AddHeaderParam("Authorization", "Bearer t1").AddHeaderParam("X-Api-Key", "k1").
+			Build().Invoke(httpcli.CallOptionRoundTripper(rec))
+		So(err, ShouldBeNil)
+
+		loaded, err := recorder.Load(cassette)
+		So(err, ShouldBeNil)
+		So(len(loaded.Interactions), ShouldEqual, 1)
+		return loaded.Interactions[0]
+	}
+
+	Convey("记录时默认脱敏敏感头部", t, func() {
+		interaction := record()
+		So(interaction.Request.Header.Get("Authorization"), ShouldEqual, "******")
+		So(interaction.Request.Header.Get("X-Api-Key"), ShouldEqual, "******")
+		So(interaction.Response.Header.Get("Set-Cookie"), ShouldEqual, "******")
+		So(interaction.Response.Header.Get("Content-Type"), ShouldEqual, "application/json")
+
+		Convey("显式关闭脱敏", func() {
+			interaction := record(recorder.WithoutRedact())
+			So(interaction.Request.Header.Get("Authorization"), ShouldEqual, "Bearer t1")
+			So(interaction.Response.Header.Get("Set-Cookie"), ShouldContainSubstring, "s1")
+		})
+	})
+
+	Convey("记录OAuth2令牌请求时脱敏URL及请求/响应体", t, func() {
+		tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if r.URL.Path == "/token" {
+				_, _ = w.Write([]byte(`{"access_token":"at-secret","token_type":"bearer","expires_in":3600}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"name":"n1"}`))
+		}))
+		defer tokenServer.Close()
+
+		cassette := filepath.Join(t.TempDir(), "oauth2.json")
+		fetch := func(mode recorder.Mode) (*interceptorcli.OAuth2Token, string) {
+			rec, err := recorder.New(cassette, mode)
+			So(err, ShouldBeNil)
+			client, err := httpcli.NewClient(nil, httpcli.WithRoundTripper(rec))
+			So(err, ShouldBeNil)
+
+			policy := interceptorcli.DefaultOAuth2Policy(tokenServer.URL+"/token", "c1", "cs-secret")
+			policy.AuthInParams = true
+			policy.Client = client
+			token, err := interceptorcli.NewOAuth2TokenSource(policy).Token(context.Background())
+			So(err, ShouldBeNil)
+
+			resp, err := httpcli.NewHttpRequestBuilder().GET().WithEndpoint(tokenServer.URL).WithPath("/user").
+				AddQueryParam("access_token", "at-secret").AddQueryParam("page", "1").
+				Build().Invoke(httpcli.CallOptionRoundTripper(rec))
+			So(err, ShouldBeNil)
+			return token, resp.GetBody()
+		}
+
+		token, body := fetch(recorder.ModeRecord)
+		So(token.AccessToken, ShouldEqual, "at-secret")
+		So(body, ShouldEqual, `{"name":"n1"}`)
+
+		data, err := os.ReadFile(cassette)
+		So(err, ShouldBeNil)
+		So(string(data), ShouldNotContainSubstring, "cs-secret")
+		So(string(data), ShouldNotContainSubstring, "at-secret")
+
+		loaded, err := recorder.Load(cassette)
+		So(err, ShouldBeNil)
+		So(loaded.Interactions, ShouldHaveLength, 2)
+		So(loaded.Interactions[0].Request.Body, ShouldContainSubstring, "client_id=c1")
+		So(loaded.Interactions[0].Request.Body, ShouldContainSubstring, "client_secret=%2A%2A%2A%2A%2A%2A")
+		So(loaded.Interactions[1].Request.URL, ShouldContainSubstring, "page=1")
+		// 没有敏感内容的响应体原样保存
+		So(loaded.Interactions[1].Response.Body, ShouldEqual, `{"name":"n1"}`)
+
+		Convey("回放时按脱敏后的URL匹配", func() {
+			token, body := fetch(recorder.ModeReplay)
+			So(token.AccessToken, ShouldEqual, "******")
+			So(body, ShouldEqual, `{"name":"n1"}`)
+		})
+	})
+}
@@ -38,6 +38,10 @@ type HttpRequest struct {
 
 	autoFilledPathParams map[string]string
 	timeout              time.Duration
+	// 非幂等请求(如POST)是否允许重试
+	retryable bool
+	// 一致性哈希负载均衡使用的键
+	balanceKey string
 }
 
 // 填充路径参数.
@@ -135,6 +139,28 @@ func (r *HttpRequest) GetTimeout() time.Duration {
 	return r.timeout
 }
 
+// GetBalanceKey 获取一致性哈希负载均衡使用的键.
+func (r *HttpRequest) GetBalanceKey() string {
+	return r.balanceKey
+}
+
+// IsRetryable 请求是否显式允许重试. 幂等请求默认允许重试, 不需要设置.
+func (r *HttpRequest) IsRetryable() bool {
+	return r.retryable
+}
+
+// IsBodyRewindable 请求体能否被重复读取.
+// 普通请求体在每次转换时重新序列化, 文件请求体通过分段读取不移动文件偏移, 均可重复读取;
+// 流式表单文件(def.StreamFilePart)只能读取一次.
+func (r *HttpRequest) IsBodyRewindable() bool {
+	for _, v := range r.formParams {
+		if _, ok := v.(*def.StreamFilePart); ok {
+			return false
+		}
+	}
+	return true
+}
+
 // 实现真正的流式表单传输
 func (r *HttpRequest) convertFormBody(ctx context.Context) (*http.Request, error) {
 	// 创建管道实现流式传输
@@ -163,6 +189,14 @@ func (r *HttpRequest) convertFormBody(ctx context.Context) (*http.Request, error
 	return req, nil
 }
 
+// closeStreamBody 关闭文件请求体. 文件请求体在所有尝试(含重试)结束后由Invoke关闭,
+// 与直接将文件作为http请求体时由传输层关闭的所有权一致; 直接使用ConvertRequest时由调用方关闭.
+func (r *HttpRequest) closeStreamBody() {
+	if file, ok := r.bodyData.(*os.File); ok {
+		_ = file.Close()
+	}
+}
+
 // 处理文件流
 func (r *HttpRequest) convertStreamBody(ctx context.Context, file *os.File) (*http.Request, error) {
 	// 获取文件信息用于设置Content-Type
@@ -170,17 +204,25 @@ func (r *HttpRequest) convertStreamBody(ctx context.Context, file *os.File) (*ht
 	if err != nil {
 		return nil, err
 	}
+	// 从文件当前偏移开始分段读取, 不移动文件偏移也不关闭文件, 以便请求重试时能重新发送完整内容, 文件由closeStreamBody关闭
+	reader, err := def.NewFileSectionReader(file)
+	if err != nil {
+		return nil, err
+	}
 	// 创建请求并设置Content-Length
-	req, err := http.NewRequestWithContext(ctx, r.GetMethod(), r.GetEndpoint(), file)
+	req, err := http.NewRequestWithContext(ctx, r.GetMethod(), r.GetEndpoint(), io.NopCloser(reader))
 	if err != nil {
 		return nil, err
 	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(io.NewSectionReader(reader, 0, reader.Size())), nil
+	}
 
 	// 设置内容类型
 	if contentType := mime.TypeByExtension(filepath.Ext(fileInfo.Name())); contentType != "" {
 		req.Header.Set("Content-Type", contentType)
 	}
-	req.ContentLength = fileInfo.Size()
+	req.ContentLength = reader.Size()
 
 	return req, nil
 }
@@ -344,6 +386,13 @@ func (r *HttpRequest) fillQueryParams(req *http.Request) {
 
 	q := req.URL.Query()
 	for key, value := range r.GetQueryParams() {
+		// []string按多个同名参数发送
+		if values, ok := value.([]string); ok {
+			for _, v := range values {
+				q.Add(key, v)
+			}
+			continue
+		}
 		q.Add(key, typeutil.ConvertInterfaceToString(value))
 	}
 
@@ -386,9 +435,12 @@ func (r *HttpRequest) InvokeWithContext(ctx context.Context, opts ...CallOption)
 	}
 	tr.Proxy = ci.HttpProxy
 	rt = tr
+	if ci.roundTripper != nil {
+		rt = ci.roundTripper
+	}
 
 	if ci.enableOTEL {
-		rt = otelhttp.NewTransport(tr)
+		rt = otelhttp.NewTransport(rt)
 	}
 
 	c := http.Client{
@@ -397,6 +449,7 @@ func (r *HttpRequest) InvokeWithContext(ctx context.Context, opts ...CallOption)
 	}
 
 	resp, err := c.Do(httpReq)
+	r.closeStreamBody()
 	if err != nil {
 		return nil, err
 	}
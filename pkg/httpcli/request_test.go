@@ -340,6 +340,7 @@ func TestUploadChunkFileFromFormData(t *testing.T) {
 
 				So(err, ShouldBeNil)
 			}
+			So(fr.Verify(nil, ""), ShouldBeNil)
 		})
 	})
 }
@@ -16,7 +16,7 @@ type Account struct {
 	Email      string
 	CreateTime time.Time
 	Phone      string
-	Password   string
+	Password   string `log:"sensitive"`
 	Group      []string
 	DN         string
 }
@@ -37,7 +37,7 @@ type Config struct {
 	Addr       string // 地址
 	Port       int    // 端口
 	BindDN     string // 用户名
-	BindPasswd string // 密码
+	BindPasswd string `log:"sensitive"` // 密码
 	EnableTls  bool   // 启动TLS
 }
 
@@ -1,6 +1,10 @@
 package main
 
-import "github.com/wangweihong/gotoolbox/pkg/log"
+import (
+	"time"
+
+	"github.com/wangweihong/gotoolbox/pkg/log"
+)
 
 func main() {
 	log.Debug("in default options, I don't print")
@@ -15,4 +19,14 @@ func main() {
 	defer log.Flush()
 
 	log.Debug("I will print after changed")
+
+	// 运行时修改级别, 无需重新初始化. 也可以通过log.LevelHandler()暴露HTTP接口:
+	// http.Handle("/debug/loglevel", log.LevelHandler())
+	log.Levels().SetLevel(log.InfoLevel, 0)
+	log.Debug("I don't print after level changed to info")
+
+	// 仅打开syncer子系统的debug日志, 10分钟后恢复
+	log.Levels().SetNameLevel("syncer", log.DebugLevel, 10*time.Minute)
+	log.WithName("syncer").Debug("syncer debug log will print")
+	log.WithName("api").Debug("api debug log won't print")
 }
@@ -0,0 +1,11 @@
+package log
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// WrapCore 按选项包装core, 用于测试采样、限速及去重的效果.
+func (o *Options) WrapCore(core zapcore.Core) zapcore.Core {
+	return zap.New(core, o.wrapCore(nil)).Core()
+}
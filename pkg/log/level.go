@@ -0,0 +1,336 @@
+package log
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/wangweihong/gotoolbox/pkg/json"
+)
+
+// LevelController 运行时日志级别控制, 支持全局级别及按日志器名称(WithName)设置级别.
+// 名称级别作用于该名称及其子名称, 如"syncer"同时作用于"syncer.user", 以最长匹配的名称为准.
+// 设置级别时可以指定有效期, 过期后恢复为设置前的级别.
+type LevelController struct {
+	mu     sync.RWMutex
+	global levelEntry
+	names  map[string]*levelEntry
+
+	// 所有级别中的最低级别, 用于快速判断
+	minLevel atomic.Int32
+	// 是否设置了名称级别
+	hasNames atomic.Bool
+}
+
+type levelEntry struct {
+	level    zapcore.Level
+	expireAt time.Time
+	timer    *time.Timer
+	// 每次设置递增, 避免已停止但已触发的定时器恢复级别
+	gen uint64
+	// 过期后恢复的级别, 为nil时名称级别过期后被移除
+	revert *zapcore.Level
+}
+
+// LevelInfo 级别及有效期.
+type LevelInfo struct {
+	Level    string     `json:"level"`
+	ExpireAt *time.Time `json:"expireAt,omitempty"`
+}
+
+func NewLevelController(level zapcore.Level) *LevelController {
+	c := &LevelController{
+		global: levelEntry{level: level},
+		names:  make(map[string]*levelEntry),
+	}
+	c.minLevel.Store(int32(level))
+	return c
+}
+
+// Level 返回全局级别.
+func (c *LevelController) Level() zapcore.Level {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.global.level
+}
+
+// SetLevel 设置全局级别. ttl大于0时, 过期后恢复为设置前的级别.
+func (c *LevelController) SetLevel(level zapcore.Level, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(&c.global, level, ttl, func(gen uint64) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.global.gen != gen {
+			return
+		}
+		if c.global.revert != nil {
+			c.global.level = *c.global.revert
+		}
+		c.global.clearTTL()
+		c.updateMinLevel()
+	})
+	c.updateMinLevel()
+}
+
+// SetNameLevel 设置指定名称日志器的级别. ttl大于0时, 过期后恢复为设置前的级别.
+func (c *LevelController) SetNameLevel(name string, level zapcore.Level, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.names[name]
+	if !ok {
+		e = &levelEntry{}
+		c.names[name] = e
+	}
+	c.set(e, level, ttl, func(gen uint64) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.names[name] != e || e.gen != gen {
+			return
+		}
+		if e.revert == nil {
+			delete(c.names, name)
+		} else {
+			e.level = *e.revert
+			e.clearTTL()
+		}
+		c.updateMinLevel()
+	})
+	// 新建的名称级别过期后移除, 而不是恢复成零值级别
+	if !ok {
+		e.revert = nil
+	}
+	c.updateMinLevel()
+}
+
+// set 设置级别. 有效期内再次设置不会改变恢复的级别.
+func (c *LevelController) set(e *levelEntry, level zapcore.Level, ttl time.Duration, expire func(gen uint64)) {
+	e.gen++
+	if e.timer != nil {
+		e.timer.Stop()
+	}
+	if ttl <= 0 {
+		e.level = level
+		e.clearTTL()
+		return
+	}
+
+	if e.timer == nil {
+		old := e.level
+		e.revert = &old
+	}
+	e.level = level
+	e.expireAt = time.Now().Add(ttl)
+	gen := e.gen
+	e.timer = time.AfterFunc(ttl, func() { expire(gen) })
+}
+
+func (e *levelEntry) clearTTL() {
+	if e.timer != nil {
+		e.timer.Stop()
+	}
+	e.timer = nil
+	e.revert = nil
+	e.expireAt = time.Time{}
+}
+
+// ResetNameLevel 移除指定名称的级别, 恢复使用全局级别.
+func (c *LevelController) ResetNameLevel(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.names[name]; ok {
+		e.clearTTL()
+		delete(c.names, name)
+	}
+	c.updateMinLevel()
+}
+
+// NameLevels 返回所有名称级别.
+func (c *LevelController) NameLevels() map[string]LevelInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	levels := make(map[string]LevelInfo, len(c.names))
+	for name, e := range c.names {
+		levels[name] = e.info()
+	}
+	return levels
+}
+
+func (e *levelEntry) info() LevelInfo {
+	info := LevelInfo{Level: e.level.String()}
+	if !e.expireAt.IsZero() {
+		t := e.expireAt
+		info.ExpireAt = &t
+	}
+	return info
+}
+
+func (c *LevelController) updateMinLevel() {
+	min := c.global.level
+	for _, e := range c.names {
+		if e.level < min {
+			min = e.level
+		}
+	}
+	c.minLevel.Store(int32(min))
+	c.hasNames.Store(len(c.names) > 0)
+}
+
+// LevelOf 返回指定名称日志器的生效级别.
+func (c *LevelController) LevelOf(name string) zapcore.Level {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for {
+		if e, ok := c.names[name]; ok {
+			return e.level
+		}
+		i := strings.LastIndex(name, ".")
+		if i < 0 {
+			return c.global.level
+		}
+		name = name[:i]
+	}
+}
+
+// Enabled 指定名称日志器是否输出该级别的日志.
+func (c *LevelController) Enabled(name string, level zapcore.Level) bool {
+	if level < zapcore.Level(c.minLevel.Load()) {
+		return false
+	}
+	// 未设置名称级别时最低级别即全局级别
+	if !c.hasNames.Load() {
+		return true
+	}
+	return level >= c.LevelOf(name)
+}
+
+// WrapCore 使用控制器的级别过滤日志. core本身的级别应不高于所有可能设置的级别.
+func (c *LevelController) WrapCore(core zapcore.Core) zapcore.Core {
+	return &levelCore{Core: core, levels: c}
+}
+
+type levelCore struct {
+	zapcore.Core
+	levels *LevelController
+}
+
+// Enabled 任意名称的日志器输出该级别时返回true, 具体名称在Check时判断.
+func (c *levelCore) Enabled(level zapcore.Level) bool {
+	return level >= zapcore.Level(c.levels.minLevel.Load()) && c.Core.Enabled(level)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), levels: c.levels}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.levels.Enabled(ent.LoggerName, ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+// levelRequest 修改级别的请求. name为空时修改全局级别, ttl为空表示永久生效.
+type levelRequest struct {
+	Name  string `json:"name,omitempty"`
+	Level string `json:"level"`
+	TTL   string `json:"ttl,omitempty"`
+}
+
+type levelResponse struct {
+	Level string               `json:"level"`
+	Names map[string]LevelInfo `json:"names,omitempty"`
+	Error string               `json:"error,omitempty"`
+}
+
+// ServeHTTP 查看及修改日志级别.
+//
+//	GET                                                 查看全局级别及所有名称级别
+//	PUT    {"level":"debug"}                            修改全局级别
+//	PUT    {"name":"syncer","level":"debug","ttl":"10m"} 修改名称级别, 10分钟后恢复
+//	DELETE ?name=syncer                                 移除名称级别
+func (c *LevelController) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req levelRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			c.writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+		var level zapcore.Level
+		if err := level.UnmarshalText([]byte(req.Level)); err != nil || req.Level == "" {
+			c.writeError(w, http.StatusBadRequest, "invalid level: "+req.Level)
+			return
+		}
+		var ttl time.Duration
+		if req.TTL != "" {
+			var err error
+			if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl < 0 {
+				c.writeError(w, http.StatusBadRequest, "invalid ttl: "+req.TTL)
+				return
+			}
+		}
+		if req.Name == "" {
+			c.SetLevel(level, ttl)
+		} else {
+			c.SetNameLevel(req.Name, level, ttl)
+		}
+	case http.MethodDelete:
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			c.writeError(w, http.StatusBadRequest, "name is required")
+			return
+		}
+		c.ResetNameLevel(name)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		c.writeError(w, http.StatusMethodNotAllowed, "only GET, PUT and DELETE are supported")
+		return
+	}
+
+	c.write(w, http.StatusOK, c.response())
+}
+
+func (c *LevelController) response() levelResponse {
+	return levelResponse{Level: c.Level().String(), Names: c.NameLevels()}
+}
+
+func (c *LevelController) writeError(w http.ResponseWriter, code int, msg string) {
+	resp := c.response()
+	resp.Error = msg
+	c.write(w, code, resp)
+}
+
+func (c *LevelController) write(w http.ResponseWriter, code int, resp levelResponse) {
+	data, _ := json.Marshal(resp)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(data)
+}
+
+// Levels 返回全局日志器的级别控制器.
+func Levels() *LevelController {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return std.levels
+}
+
+// LevelHandler 查看及修改全局日志器级别的http.Handler, 见LevelController.ServeHTTP.
+// 重新Init后仍作用于新的全局日志器.
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Levels().ServeHTTP(w, r)
+	})
+}
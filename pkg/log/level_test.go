@@ -0,0 +1,115 @@
+package log_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/wangweihong/gotoolbox/pkg/json"
+	"github.com/wangweihong/gotoolbox/pkg/log"
+)
+
+func TestLevelController(t *testing.T) {
+	levels := log.NewLevelController(zapcore.InfoLevel)
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(levels.WrapCore(core))
+	syncer := logger.Named("syncer")
+	userSyncer := syncer.Named("user")
+	api := logger.Named("api")
+
+	syncer.Debug("debug")
+	assert.Equal(t, 0, logs.Len())
+
+	// 仅打开syncer及其子日志器的debug日志
+	levels.SetNameLevel("syncer", zapcore.DebugLevel, 0)
+	syncer.Debug("debug")
+	userSyncer.Debug("debug")
+	api.Debug("debug")
+	logger.Debug("debug")
+	assert.Equal(t, 2, logs.Len())
+	assert.Equal(t, zapcore.DebugLevel, levels.LevelOf("syncer.user"))
+	assert.Equal(t, zapcore.InfoLevel, levels.LevelOf("api"))
+
+	// 子日志器单独设置级别
+	levels.SetNameLevel("syncer.user", zapcore.ErrorLevel, 0)
+	userSyncer.Warn("warn")
+	assert.Equal(t, 2, logs.Len())
+
+	levels.ResetNameLevel("syncer")
+	levels.ResetNameLevel("syncer.user")
+	syncer.Debug("debug")
+	assert.Equal(t, 2, logs.Len())
+	assert.Empty(t, levels.NameLevels())
+}
+
+func TestLevelController_TTL(t *testing.T) {
+	levels := log.NewLevelController(zapcore.InfoLevel)
+
+	levels.SetNameLevel("syncer", zapcore.DebugLevel, 50*time.Millisecond)
+	levels.SetLevel(zapcore.DebugLevel, 50*time.Millisecond)
+	// 有效期内再次设置, 过期后仍恢复为最初的级别
+	levels.SetLevel(zapcore.WarnLevel, 50*time.Millisecond)
+	assert.True(t, levels.Enabled("syncer", zapcore.DebugLevel))
+	assert.NotNil(t, levels.NameLevels()["syncer"].ExpireAt)
+	assert.Equal(t, zapcore.WarnLevel, levels.Level())
+
+	assert.Eventually(t, func() bool {
+		return levels.Level() == zapcore.InfoLevel && len(levels.NameLevels()) == 0
+	}, time.Second, 10*time.Millisecond)
+	assert.False(t, levels.Enabled("syncer", zapcore.DebugLevel))
+	assert.True(t, levels.Enabled("syncer", zapcore.InfoLevel))
+
+	// 永久设置的名称级别在临时设置过期后恢复
+	levels.SetNameLevel("api", zapcore.WarnLevel, 0)
+	levels.SetNameLevel("api", zapcore.DebugLevel, 20*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return levels.LevelOf("api") == zapcore.WarnLevel
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestLevelHandler(t *testing.T) {
+	levels := log.NewLevelController(zapcore.InfoLevel)
+
+	do := func(method, target, body string) (int, map[string]any) {
+		w := httptest.NewRecorder()
+		levels.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		resp := map[string]any{}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+
+	code, resp := do(http.MethodPut, "/", `{"level":"warn"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "warn", resp["level"])
+
+	code, resp = do(http.MethodPut, "/", `{"name":"syncer","level":"debug","ttl":"10m"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "debug", resp["names"].(map[string]any)["syncer"].(map[string]any)["level"])
+	assert.Equal(t, zapcore.DebugLevel, levels.LevelOf("syncer"))
+
+	code, resp = do(http.MethodPut, "/", `{"level":"verbose"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.NotEmpty(t, resp["error"])
+
+	code, _ = do(http.MethodDelete, "/?name=syncer", "")
+	assert.Equal(t, http.StatusOK, code)
+	code, resp = do(http.MethodGet, "/", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Nil(t, resp["names"])
+
+	code, _ = do(http.MethodPost, "/", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+
+	// 全局日志器
+	assert.NotNil(t, log.Levels())
+	w := httptest.NewRecorder()
+	log.LevelHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -130,6 +130,9 @@ type zapLogger struct {
 	// deals with our desire to have multiple verbosity levels.
 	zapLogger *zap.Logger
 	infoLogger
+
+	// 运行时级别控制, 仅New创建的日志器有效
+	levels *LevelController
 }
 
 // handleFields converts a bunch of arbitrary key-value pairs into Zap fields.  It takes
@@ -247,22 +250,23 @@ func New(opts *Options) *zapLogger {
 	}
 
 	loggerConfig := &zap.Config{
-		Level:             zap.NewAtomicLevelAt(zapLevel),
+		// 级别由LevelController控制
+		Level:             zap.NewAtomicLevelAt(zapcore.DebugLevel),
 		Development:       opts.Development,
 		DisableCaller:     opts.DisableCaller,
 		DisableStacktrace: opts.DisableStacktrace,
-		Sampling: &zap.SamplingConfig{
-			Initial:    100,
-			Thereafter: 100,
-		},
-		Encoding:         opts.Format,
-		EncoderConfig:    encoderConfig,
-		OutputPaths:      opts.OutputPaths,
-		ErrorOutputPaths: opts.ErrorOutputPaths,
+		Encoding:          opts.Format,
+		EncoderConfig:     encoderConfig,
+		OutputPaths:       opts.outputPaths(opts.OutputPaths),
+		ErrorOutputPaths:  opts.outputPaths(opts.ErrorOutputPaths),
+	}
+	if opts.ReopenOnSIGHUP {
+		ReopenOnSIGHUP()
 	}
 
+	levels := NewLevelController(zapLevel)
 	var err error
-	l, err := loggerConfig.Build(zap.AddStacktrace(zapcore.PanicLevel), zap.AddCallerSkip(1))
+	l, err := loggerConfig.Build(zap.AddStacktrace(zapcore.PanicLevel), zap.AddCallerSkip(1), opts.wrapCore(levels))
 	if err != nil {
 		panic(err)
 	}
@@ -272,6 +276,7 @@ func New(opts *Options) *zapLogger {
 			log:   l,
 			level: zap.InfoLevel,
 		},
+		levels: levels,
 	}
 	klog.InitLogger(l)
 	zap.RedirectStdLog(l)
@@ -386,6 +391,35 @@ func NewLogger(l *zap.Logger) Logger {
 	}
 }
 
+// NewWithCore 创建输出到core的日志器, 与New创建的日志器一样按redact.Default()脱敏, 级别由LevelController控制.
+// 用于输出到自定义的core, 如测试中将日志记录在内存中(见logtest).
+func NewWithCore(core zapcore.Core, level Level) *zapLogger {
+	levels := NewLevelController(level)
+	l := zap.New(levels.WrapCore(NewRedactCore(core, nil)), zap.AddCaller(), zap.AddCallerSkip(1))
+	return &zapLogger{
+		zapLogger: l,
+		infoLogger: infoLogger{
+			log:   l,
+			level: zap.InfoLevel,
+		},
+		levels: levels,
+	}
+}
+
+// ReplaceGlobal 替换全局日志器, 返回的函数恢复原来的全局日志器.
+func ReplaceGlobal(logger *zapLogger) func() {
+	mu.Lock()
+	defer mu.Unlock()
+	prev := std
+	std = logger
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		std = prev
+	}
+}
+
 // ZapLogger used for other log wrapper such as klog.
 func ZapLogger() *zap.Logger {
 	return std.zapLogger
@@ -0,0 +1,174 @@
+// Package logtest 将日志记录在内存中, 用于测试中断言输出的日志.
+package logtest
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/wangweihong/gotoolbox/pkg/log"
+)
+
+// ObservedEntry 内存中记录的日志.
+type ObservedEntry struct {
+	Level      log.Level
+	LoggerName string
+	Message    string
+	Time       time.Time
+	Caller     zapcore.EntryCaller
+	// 日志字段, 包括WithValues/WithFields/WithFieldPair等添加的上下文字段
+	Fields map[string]any
+}
+
+// ObservedLogs 记录在内存中的日志, 用于测试中断言输出的日志.
+type ObservedLogs struct {
+	logs *observer.ObservedLogs
+}
+
+// NewObserved 创建将日志记录在内存中的日志器. 与log.New创建的日志器一样按redact.Default()脱敏.
+func NewObserved(level log.Level) (log.Logger, *ObservedLogs) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	return log.NewWithCore(core, level), &ObservedLogs{logs: logs}
+}
+
+// ObserveGlobal 将全局日志器替换成内存日志器, 包级别的日志函数(如log.F(ctx).Info)输出的日志也会被记录.
+// 返回的函数恢复原来的全局日志器.
+func ObserveGlobal(level log.Level) (*ObservedLogs, func()) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	restore := log.ReplaceGlobal(log.NewWithCore(core, level))
+	return &ObservedLogs{logs: logs}, restore
+}
+
+// All 返回所有日志.
+func (o *ObservedLogs) All() []ObservedEntry {
+	return toObservedEntries(o.logs.All())
+}
+
+// TakeAll 返回并清空所有日志.
+func (o *ObservedLogs) TakeAll() []ObservedEntry {
+	return toObservedEntries(o.logs.TakeAll())
+}
+
+func (o *ObservedLogs) Len() int {
+	return o.logs.Len()
+}
+
+// Filter 返回满足条件的日志.
+func (o *ObservedLogs) Filter(fn func(ObservedEntry) bool) []ObservedEntry {
+	var entries []ObservedEntry
+	for _, e := range o.All() {
+		if fn(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// FilterMessage 返回消息包含msg的日志.
+func (o *ObservedLogs) FilterMessage(msg string) []ObservedEntry {
+	return o.Filter(func(e ObservedEntry) bool {
+		return strings.Contains(e.Message, msg)
+	})
+}
+
+// FilterField 返回字段key的值等于value的日志.
+func (o *ObservedLogs) FilterField(key string, value any) []ObservedEntry {
+	return o.Filter(func(e ObservedEntry) bool {
+		return e.FieldEquals(key, value)
+	})
+}
+
+// ContainsMessage 是否有消息包含msg的日志.
+func (o *ObservedLogs) ContainsMessage(msg string) bool {
+	return len(o.FilterMessage(msg)) > 0
+}
+
+// FieldEquals 是否有字段key的值等于value的日志.
+func (o *ObservedLogs) FieldEquals(key string, value any) bool {
+	return len(o.FilterField(key, value)) > 0
+}
+
+// CountAtLevel 返回指定级别的日志条数.
+func (o *ObservedLogs) CountAtLevel(level log.Level) int {
+	return len(o.Filter(func(e ObservedEntry) bool {
+		return e.Level == level
+	}))
+}
+
+// FieldEquals 字段key的值是否等于value. 不同类型的数值按数值比较, 如int(1)与int64(1)相等, 而"1"与1不相等.
+func (e ObservedEntry) FieldEquals(key string, value any) bool {
+	v, ok := e.Fields[key]
+	if !ok {
+		return false
+	}
+	if reflect.DeepEqual(v, value) {
+		return true
+	}
+	return numberEquals(reflect.ValueOf(v), reflect.ValueOf(value))
+}
+
+// numberEquals 两个值均为数值时比较数值是否相等.
+func numberEquals(a, b reflect.Value) bool {
+	switch {
+	case isInt(a) && isInt(b):
+		return a.Int() == b.Int()
+	case isUint(a) && isUint(b):
+		return a.Uint() == b.Uint()
+	case isInt(a) && isUint(b):
+		return a.Int() >= 0 && uint64(a.Int()) == b.Uint()
+	case isUint(a) && isInt(b):
+		return b.Int() >= 0 && a.Uint() == uint64(b.Int())
+	}
+	fa, ok := toFloat(a)
+	if !ok {
+		return false
+	}
+	fb, ok := toFloat(b)
+	return ok && fa == fb
+}
+
+func isInt(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isUint(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+func toFloat(v reflect.Value) (float64, bool) {
+	switch {
+	case isInt(v):
+		return float64(v.Int()), true
+	case isUint(v):
+		return float64(v.Uint()), true
+	case v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+func toObservedEntries(logged []observer.LoggedEntry) []ObservedEntry {
+	entries := make([]ObservedEntry, 0, len(logged))
+	for _, l := range logged {
+		entries = append(entries, ObservedEntry{
+			Level:      l.Level,
+			LoggerName: l.LoggerName,
+			Message:    l.Message,
+			Time:       l.Time,
+			Caller:     l.Caller,
+			Fields:     l.ContextMap(),
+		})
+	}
+	return entries
+}
@@ -0,0 +1,62 @@
+package logtest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wangweihong/gotoolbox/pkg/log"
+	"github.com/wangweihong/gotoolbox/pkg/log/logtest"
+)
+
+func TestObserved(t *testing.T) {
+	logger, logs := logtest.NewObserved(log.InfoLevel)
+
+	logger.Debug("debug")
+	logger.Info("sync user", log.Int("count", 3))
+	logger.WithName("syncer").Warnw("sync failed", "user", "u1")
+
+	logger.WithValues("traceID", "t1", "method", "GET").Error("request failed")
+
+	assert.Equal(t, 3, logs.Len())
+	assert.True(t, logs.ContainsMessage("sync"))
+	assert.False(t, logs.ContainsMessage("debug"))
+	assert.Len(t, logs.FilterMessage("sync"), 2)
+	assert.True(t, logs.FieldEquals("count", 3))
+	assert.True(t, logs.FieldEquals("user", "u1"))
+	assert.True(t, logs.FieldEquals("traceID", "t1"))
+	assert.True(t, logs.FieldEquals("method", "GET"))
+	assert.False(t, logs.FieldEquals("count", 4))
+	assert.True(t, logs.FieldEquals("count", int32(3)))
+	assert.True(t, logs.FieldEquals("count", 3.0))
+	assert.False(t, logs.FieldEquals("count", "3"))
+	assert.Equal(t, 1, logs.CountAtLevel(log.InfoLevel))
+	assert.Equal(t, 1, logs.CountAtLevel(log.WarnLevel))
+	assert.Equal(t, 0, logs.CountAtLevel(log.DebugLevel))
+
+	entries := logs.FilterField("user", "u1")
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "syncer", entries[0].LoggerName)
+
+	assert.Len(t, logs.TakeAll(), 3)
+	assert.Equal(t, 0, logs.Len())
+}
+
+func TestObserveGlobal(t *testing.T) {
+	logs, restore := logtest.ObserveGlobal(log.InfoLevel)
+
+	ctx := log.WithFieldPair(context.Background(), "traceID", "t1")
+	log.F(ctx).Infof("call %s", "api")
+	log.Debug("global")
+	log.Levels().SetLevel(log.DebugLevel, 0)
+	log.Debug("global")
+
+	assert.True(t, logs.ContainsMessage("call api"))
+	assert.True(t, logs.FieldEquals("traceID", "t1"))
+	assert.Equal(t, 1, logs.CountAtLevel(log.DebugLevel))
+
+	restore()
+	log.Info("after restore")
+	assert.False(t, logs.ContainsMessage("after restore"))
+}
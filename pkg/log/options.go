@@ -2,9 +2,12 @@ package log
 
 import (
 	"fmt"
+	"math"
 	"strings"
+	"time"
 
 	"github.com/wangweihong/gotoolbox/pkg/json"
+	"github.com/wangweihong/gotoolbox/pkg/redact"
 
 	"github.com/spf13/pflag"
 	"go.uber.org/zap"
@@ -21,6 +24,20 @@ const (
 	flagErrorOutputPaths  = "log.error-output-paths"
 	flagDevelopment       = "log.development"
 	flagName              = "log.name"
+	flagSamplingInitial   = "log.sampling-initial"
+	flagSamplingAfter     = "log.sampling-thereafter"
+	flagSamplingInterval  = "log.sampling-interval"
+	flagRateLimit         = "log.rate-limit"
+	flagRateLimitBurst    = "log.rate-limit-burst"
+	flagDedupWindow       = "log.dedup-window"
+	flagRotateMaxSize     = "log.rotate-max-size"
+	flagRotateInterval    = "log.rotate-interval"
+	flagRotateMaxBackups  = "log.rotate-max-backups"
+	flagRotateMaxAge      = "log.rotate-max-age"
+	flagRotateCompress    = "log.rotate-compress"
+	flagReopenOnSIGHUP    = "log.reopen-on-sighup"
+	flagDisableRedact     = "log.disable-redact"
+	flagRedactKeys        = "log.redact-keys"
 
 	consoleFormat = "console"
 	jsonFormat    = "json"
@@ -39,6 +56,33 @@ type Options struct {
 	EnableColor       bool     `json:"enable-color"       mapstructure:"enable-color"`
 	Development       bool     `json:"development"        mapstructure:"development"`
 	Name              string   `json:"name"               mapstructure:"name"`
+
+	// 日志采样: 每个采样周期内, 同一级别的同一消息先输出前SamplingInitial条, 之后每SamplingThereafter条输出一条.
+	// SamplingInitial为0时不采样
+	SamplingInitial    int           `json:"sampling-initial"    mapstructure:"sampling-initial"`
+	SamplingThereafter int           `json:"sampling-thereafter" mapstructure:"sampling-thereafter"`
+	SamplingInterval   time.Duration `json:"sampling-interval"   mapstructure:"sampling-interval"`
+	// 每条消息每秒最多输出的日志条数, 0表示不限速
+	RateLimit      float64 `json:"rate-limit"       mapstructure:"rate-limit"`
+	RateLimitBurst int     `json:"rate-limit-burst" mapstructure:"rate-limit-burst"`
+	// 去重窗口: 窗口内的相同消息合并成一条, 窗口结束后输出重复次数. 0表示不去重
+	DedupWindow time.Duration `json:"dedup-window" mapstructure:"dedup-window"`
+
+	// 输出到文件时的轮转配置: 文件超过RotateMaxSize(MB)或每隔RotateInterval轮转, 0表示不轮转
+	RotateMaxSize  int           `json:"rotate-max-size" mapstructure:"rotate-max-size"`
+	RotateInterval time.Duration `json:"rotate-interval" mapstructure:"rotate-interval"`
+	// 最多保留的轮转文件个数及最长保留时间, 0表示不限制
+	RotateMaxBackups int           `json:"rotate-max-backups" mapstructure:"rotate-max-backups"`
+	RotateMaxAge     time.Duration `json:"rotate-max-age"     mapstructure:"rotate-max-age"`
+	// 是否gzip压缩轮转文件
+	RotateCompress bool `json:"rotate-compress" mapstructure:"rotate-compress"`
+	// 收到SIGHUP信号时重新打开日志文件, 配合外部logrotate使用(无需copytruncate)
+	ReopenOnSIGHUP bool `json:"reopen-on-sighup" mapstructure:"reopen-on-sighup"`
+
+	// 关闭日志脱敏. 默认按redact.Default()策略脱敏密码、token等敏感字段
+	DisableRedact bool `json:"disable-redact" mapstructure:"disable-redact"`
+	// 除默认敏感字段名外, 额外需要脱敏的字段名
+	RedactKeys []string `json:"redact-keys" mapstructure:"redact-keys"`
 }
 
 // NewOptions creates an Options object with default parameters.
@@ -52,6 +96,10 @@ func NewOptions() *Options {
 		Development:       false,
 		OutputPaths:       []string{"stdout"},
 		ErrorOutputPaths:  []string{"stderr"},
+
+		SamplingInitial:    100,
+		SamplingThereafter: 100,
+		SamplingInterval:   time.Second,
 	}
 }
 
@@ -69,6 +117,19 @@ func (o *Options) Validate() []error {
 		errs = append(errs, fmt.Errorf("not a valid log format: %q", o.Format))
 	}
 
+	if o.SamplingInitial < 0 || o.SamplingThereafter < 0 || o.SamplingInterval < 0 {
+		errs = append(errs, fmt.Errorf("log sampling options must not be negative"))
+	}
+	if o.RateLimit < 0 || o.RateLimitBurst < 0 {
+		errs = append(errs, fmt.Errorf("log rate limit options must not be negative"))
+	}
+	if o.DedupWindow < 0 {
+		errs = append(errs, fmt.Errorf("not a valid log dedup window: %v", o.DedupWindow))
+	}
+	if o.RotateMaxSize < 0 || o.RotateInterval < 0 || o.RotateMaxBackups < 0 || o.RotateMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("log rotate options must not be negative"))
+	}
+
 	return errs
 }
 
@@ -90,6 +151,30 @@ func (o *Options) AddFlags(fs *pflag.FlagSet) {
 			"the behavior of DPanicLevel and takes stacktraces more liberally.",
 	)
 	fs.StringVar(&o.Name, flagName, o.Name, "The name of the logger.")
+	fs.IntVar(&o.SamplingInitial, flagSamplingInitial, o.SamplingInitial,
+		"Log the first N entries with the same level and message each sampling interval, 0 disables sampling.")
+	fs.IntVar(&o.SamplingThereafter, flagSamplingAfter, o.SamplingThereafter,
+		"After the first entries, log every Mth entry with the same level and message each sampling interval.")
+	fs.DurationVar(&o.SamplingInterval, flagSamplingInterval, o.SamplingInterval, "Log sampling interval.")
+	fs.Float64Var(&o.RateLimit, flagRateLimit, o.RateLimit,
+		"Maximum entries per second with the same level and message, 0 disables rate limiting.")
+	fs.IntVar(&o.RateLimitBurst, flagRateLimitBurst, o.RateLimitBurst, "Burst size of log rate limiting.")
+	fs.DurationVar(&o.DedupWindow, flagDedupWindow, o.DedupWindow,
+		"Collapse identical entries within the window into one entry with a repeat count, 0 disables deduplication.")
+	fs.IntVar(&o.RotateMaxSize, flagRotateMaxSize, o.RotateMaxSize,
+		"Rotate log files when they exceed the size in megabytes, 0 disables size-based rotation.")
+	fs.DurationVar(&o.RotateInterval, flagRotateInterval, o.RotateInterval,
+		"Rotate log files every interval, e.g. 24h, 0 disables time-based rotation.")
+	fs.IntVar(&o.RotateMaxBackups, flagRotateMaxBackups, o.RotateMaxBackups,
+		"Maximum number of rotated log files to retain, 0 retains all.")
+	fs.DurationVar(&o.RotateMaxAge, flagRotateMaxAge, o.RotateMaxAge,
+		"Maximum age of rotated log files to retain, 0 retains all.")
+	fs.BoolVar(&o.RotateCompress, flagRotateCompress, o.RotateCompress, "Compress rotated log files with gzip.")
+	fs.BoolVar(&o.ReopenOnSIGHUP, flagReopenOnSIGHUP, o.ReopenOnSIGHUP, "Reopen log files on SIGHUP.")
+	fs.BoolVar(&o.DisableRedact, flagDisableRedact, o.DisableRedact,
+		"Disable masking of sensitive fields such as passwords and tokens.")
+	fs.StringSliceVar(&o.RedactKeys, flagRedactKeys, o.RedactKeys,
+		"Additional field names to mask besides the default sensitive names.")
 }
 
 func (o *Options) String() string {
@@ -114,11 +199,7 @@ func (o *Options) Build() error {
 		Development:       o.Development,
 		DisableCaller:     o.DisableCaller,
 		DisableStacktrace: o.DisableStacktrace,
-		Sampling: &zap.SamplingConfig{
-			Initial:    100,
-			Thereafter: 100,
-		},
-		Encoding: o.Format,
+		Encoding:          o.Format,
 		EncoderConfig: zapcore.EncoderConfig{
 			MessageKey:     "message",
 			LevelKey:       "level",
@@ -133,10 +214,13 @@ func (o *Options) Build() error {
 			EncodeCaller:   zapcore.ShortCallerEncoder,
 			EncodeName:     zapcore.FullNameEncoder,
 		},
-		OutputPaths:      o.OutputPaths,
-		ErrorOutputPaths: o.ErrorOutputPaths,
+		OutputPaths:      o.outputPaths(o.OutputPaths),
+		ErrorOutputPaths: o.outputPaths(o.ErrorOutputPaths),
+	}
+	if o.ReopenOnSIGHUP {
+		ReopenOnSIGHUP()
 	}
-	logger, err := zc.Build(zap.AddStacktrace(zapcore.PanicLevel))
+	logger, err := zc.Build(zap.AddStacktrace(zapcore.PanicLevel), o.wrapCore(nil))
 	if err != nil {
 		return err
 	}
@@ -145,3 +229,62 @@ func (o *Options) Build() error {
 
 	return nil
 }
+
+// wrapCore 依次增加脱敏、采样、限速、去重及运行时级别控制. levels为nil时不控制级别.
+func (o *Options) wrapCore(levels *LevelController) zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		if !o.DisableRedact {
+			var policy *redact.Policy
+			if len(o.RedactKeys) > 0 {
+				policy = redact.Default().With(redact.WithKeys(o.RedactKeys...))
+			}
+			core = NewRedactCore(core, policy)
+		}
+		if o.SamplingInitial > 0 {
+			thereafter := o.SamplingThereafter
+			if thereafter <= 0 {
+				// 超过前N条后不再输出
+				thereafter = math.MaxInt
+			}
+			interval := o.SamplingInterval
+			if interval <= 0 {
+				interval = time.Second
+			}
+			core = zapcore.NewSamplerWithOptions(core, interval, o.SamplingInitial, thereafter)
+		}
+		if o.RateLimit > 0 {
+			core = NewRateLimitCore(core, o.RateLimit, o.RateLimitBurst)
+		}
+		if o.DedupWindow > 0 {
+			core = NewDedupCore(core, o.DedupWindow)
+		}
+		if levels != nil {
+			core = levels.WrapCore(core)
+		}
+		return core
+	})
+}
+
+// outputPaths 开启轮转或SIGHUP重新打开时, 文件输出路径转换成轮转文件URL.
+func (o *Options) outputPaths(paths []string) []string {
+	if o.RotateMaxSize <= 0 && o.RotateInterval <= 0 && !o.ReopenOnSIGHUP {
+		return paths
+	}
+	cfg := RotateConfig{
+		MaxSize:        int64(o.RotateMaxSize) * 1024 * 1024,
+		RotateInterval: o.RotateInterval,
+		MaxBackups:     o.RotateMaxBackups,
+		MaxAge:         o.RotateMaxAge,
+		Compress:       o.RotateCompress,
+		LocalTime:      true,
+	}
+	out := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if path == "stdout" || path == "stderr" || strings.Contains(path, "://") {
+			out = append(out, path)
+			continue
+		}
+		out = append(out, RotateURL(path, cfg))
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+package log
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/wangweihong/gotoolbox/pkg/redact"
+)
+
+// NewRedactCore 按脱敏策略处理日志消息及字段: 敏感字段名的值替换成mask, 字符串替换匹配敏感内容正则的部分,
+// 结构体/map等反射字段按字段名及`log:"sensitive"`标签脱敏. policy为nil时使用redact.Default().
+// 需要作为最内层的core, 使外层的采样/限速等core的Check生效.
+func NewRedactCore(core zapcore.Core, policy *redact.Policy) zapcore.Core {
+	return &redactCore{Core: core, policy: policy}
+}
+
+type redactCore struct {
+	zapcore.Core
+	policy *redact.Policy
+}
+
+func (c *redactCore) getPolicy() *redact.Policy {
+	if c.policy != nil {
+		return c.policy
+	}
+	return redact.Default()
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{
+		Core:   c.Core.With(redactFields(c.getPolicy(), fields)),
+		policy: c.policy,
+	}
+}
+
+func (c *redactCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *redactCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	p := c.getPolicy()
+	ent.Message = p.String(ent.Message)
+	return c.Core.Write(ent, redactFields(p, fields))
+}
+
+func redactFields(p *redact.Policy, fields []zapcore.Field) []zapcore.Field {
+	if len(fields) == 0 {
+		return fields
+	}
+	out := make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		out[i] = redactField(p, f)
+	}
+	return out
+}
+
+func redactField(p *redact.Policy, f zapcore.Field) zapcore.Field {
+	if p.IsSensitiveKey(f.Key) && f.Type != zapcore.SkipType && f.Type != zapcore.NamespaceType {
+		return zap.String(f.Key, p.Mask())
+	}
+	switch f.Type {
+	case zapcore.StringType:
+		f.String = p.String(f.String)
+	case zapcore.ReflectType:
+		f.Interface = p.Value(f.Interface)
+	}
+	return f
+}
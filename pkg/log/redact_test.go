@@ -0,0 +1,61 @@
+package log_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/wangweihong/gotoolbox/pkg/log"
+	"github.com/wangweihong/gotoolbox/pkg/log/logtest"
+	"github.com/wangweihong/gotoolbox/pkg/redact"
+)
+
+type ldapConfig struct {
+	Addr       string
+	BindDN     string
+	BindPasswd string
+	PrivateKey string `json:"key"`
+	CA         string `log:"sensitive"`
+}
+
+func TestRedactCore(t *testing.T) {
+	var buf bytes.Buffer
+	enc := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	core := zapcore.NewCore(enc, zapcore.AddSync(&buf), zapcore.DebugLevel)
+	logger := zap.New(log.NewRedactCore(core, redact.NewPolicy(redact.WithKeys("phone"))))
+
+	cfg := ldapConfig{Addr: "ldap://127.0.0.1", BindDN: "cn=admin", BindPasswd: "p1", PrivateKey: "k1", CA: "c1"}
+	logger.With(zap.String("token", "t1")).Info("connect ldap with Bearer abcdefghijklmnopqrstuvwx",
+		zap.Any("config", cfg),
+		zap.String("phone", "13800000000"),
+		zap.Any("params", map[string]any{"user": "u1", "password": "p2"}),
+	)
+
+	out := buf.String()
+	for _, s := range []string{"t1", "p1", "k1", "c1", "13800000000", "p2", "abcdefghijklmnopqrstuvwx"} {
+		assert.NotContains(t, out, s)
+	}
+	assert.Contains(t, out, "cn=admin")
+	assert.Contains(t, out, `"user":"u1"`)
+	assert.Equal(t, "p1", cfg.BindPasswd)
+
+	// 普通文本中的basic/bearer不被替换
+	buf.Reset()
+	logger.Info("basic validation failed for bearer account")
+	assert.Contains(t, buf.String(), "basic validation failed for bearer account")
+}
+
+func TestRedactFields(t *testing.T) {
+	cfg := ldapConfig{BindDN: "cn=admin", BindPasswd: "p1"}
+	assert.False(t, strings.Contains(log.Every("config", cfg).String, "p1"))
+	assert.False(t, strings.Contains(log.Pretty("config", cfg).String, "p1"))
+
+	logger, logs := logtest.NewObserved(log.InfoLevel)
+	logger.Info("login", log.String("password", "p1"), log.Any("config", &cfg))
+	assert.True(t, logs.FieldEquals("password", redact.DefaultMask))
+	assert.Equal(t, redact.DefaultMask, logs.All()[0].Fields["config"].(*ldapConfig).BindPasswd)
+}
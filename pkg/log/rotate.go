@@ -0,0 +1,544 @@
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wangweihong/gotoolbox/pkg/clock"
+)
+
+const (
+	// RotateScheme 轮转文件输出的URL scheme, 如rotate:///var/log/app.log?max-size=104857600&compress=true.
+	RotateScheme = "rotate"
+
+	backupTimeFormat = "2006-01-02T15-04-05.000"
+	compressSuffix   = ".gz"
+)
+
+// RotateConfig 日志文件轮转配置.
+type RotateConfig struct {
+	// 文件超过该大小(字节)时轮转, 0表示不按大小轮转
+	MaxSize int64
+	// 按周期轮转, 如24h表示每天轮转, 周期与时间边界对齐. 0表示不按时间轮转
+	RotateInterval time.Duration
+	// 最多保留的轮转文件个数, 0表示不限制
+	MaxBackups int
+	// 轮转文件最长保留时间, 0表示不限制
+	MaxAge time.Duration
+	// 是否gzip压缩轮转文件
+	Compress bool
+	// 轮转文件名及周期对齐使用本地时间, 默认使用UTC
+	LocalTime bool
+}
+
+// RotatingFile 支持按大小/时间轮转、压缩及保留策略的日志文件, 实现zap.Sink.
+// 轮转通过重命名当前文件并创建新文件完成, 不会丢失日志.
+type RotatingFile struct {
+	filename string
+	clock    clock.PassiveClock
+
+	mu         sync.Mutex
+	cfg        RotateConfig
+	file       *os.File
+	size       int64
+	nextRotate time.Time
+	// Close后拒绝写入及轮转, 不再启动后台清理
+	closed bool
+
+	// 压缩及清理在后台执行, Close时停止
+	millCh chan struct{}
+	millWg sync.WaitGroup
+}
+
+var _ zap.Sink = &RotatingFile{}
+
+func NewRotatingFile(filename string, cfg RotateConfig) *RotatingFile {
+	return NewRotatingFileWithClock(filename, cfg, clock.RealClock{})
+}
+
+func NewRotatingFileWithClock(filename string, cfg RotateConfig, c clock.PassiveClock) *RotatingFile {
+	return &RotatingFile{
+		filename: filename,
+		cfg:      cfg,
+		clock:    c,
+	}
+}
+
+// SetConfig 更新轮转配置, 下次写入时生效.
+func (r *RotatingFile) SetConfig(cfg RotateConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cfg = cfg
+	if r.file != nil {
+		r.nextRotate = r.nextRotateAfter(r.clock.Now())
+	}
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return 0, r.errClosed()
+	}
+	if r.file == nil {
+		if err := r.openExisting(); err != nil {
+			return 0, err
+		}
+	}
+
+	needRotate := r.cfg.MaxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.cfg.MaxSize
+	if !r.nextRotate.IsZero() && !r.clock.Now().Before(r.nextRotate) {
+		needRotate = true
+	}
+	if needRotate {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *RotatingFile) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Sync()
+}
+
+// Close 关闭文件, 等待后台压缩及清理完成并停止后台协程. 关闭后写入、轮转及重新打开返回os.ErrClosed,
+// 通过rotate URL打开的文件同时从共享列表中移除, 之后以相同路径打开时创建新的轮转文件.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	err := r.close()
+	millCh := r.millCh
+	r.millCh = nil
+	r.mu.Unlock()
+
+	// closed之后不会再调用mill, Add不会与Wait并发
+	r.millWg.Wait()
+	if millCh != nil {
+		close(millCh)
+	}
+
+	rotatingFilesMu.Lock()
+	if rotatingFiles[r.filename] == r {
+		delete(rotatingFiles, r.filename)
+	}
+	rotatingFilesMu.Unlock()
+	return err
+}
+
+func (r *RotatingFile) errClosed() error {
+	return fmt.Errorf("rotating file %s: %w", r.filename, os.ErrClosed)
+}
+
+// Reopen 关闭并重新打开文件. 用于外部工具(如logrotate)移走日志文件后, 在新文件中继续写入.
+func (r *RotatingFile) Reopen() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return r.errClosed()
+	}
+	if err := r.close(); err != nil {
+		return err
+	}
+	return r.openExisting()
+}
+
+// Rotate 立即轮转当前文件.
+func (r *RotatingFile) Rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return r.errClosed()
+	}
+	return r.rotate()
+}
+
+func (r *RotatingFile) close() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	r.size = 0
+	return err
+}
+
+// openExisting 以追加方式打开文件, 文件不存在时创建.
+func (r *RotatingFile) openExisting() error {
+	if err := os.MkdirAll(filepath.Dir(r.filename), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(r.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	r.file = f
+	r.size = info.Size()
+	// 已有文件从修改时间开始计算周期, 重启跨过周期边界时也能轮转
+	start := r.clock.Now()
+	if info.Size() > 0 && info.ModTime().Before(start) {
+		start = info.ModTime()
+	}
+	r.nextRotate = r.nextRotateAfter(start)
+	return nil
+}
+
+func (r *RotatingFile) nextRotateAfter(t time.Time) time.Time {
+	interval := r.cfg.RotateInterval
+	if interval <= 0 {
+		return time.Time{}
+	}
+	if !r.cfg.LocalTime {
+		t = t.UTC()
+	}
+	// 按时区偏移对齐, 使24h周期在当天零点轮转
+	_, offset := t.Zone()
+	shift := time.Duration(offset) * time.Second
+	return t.Add(shift).Truncate(interval).Add(interval).Add(-shift)
+}
+
+func (r *RotatingFile) rotate() error {
+	if err := r.close(); err != nil {
+		return err
+	}
+	if _, err := os.Stat(r.filename); err == nil {
+		if err := os.Rename(r.filename, r.backupName(r.clock.Now())); err != nil {
+			return err
+		}
+	}
+	if err := r.openExisting(); err != nil {
+		return err
+	}
+	r.mill()
+	return nil
+}
+
+// backupName 返回轮转文件名. 同一毫秒内多次轮转时, 在时间后追加序号避免覆盖已有的轮转文件, 如app-<time>-1.log.
+func (r *RotatingFile) backupName(t time.Time) string {
+	if !r.cfg.LocalTime {
+		t = t.UTC()
+	}
+	dir := filepath.Dir(r.filename)
+	ext := filepath.Ext(r.filename)
+	prefix := strings.TrimSuffix(filepath.Base(r.filename), ext)
+	ts := t.Format(backupTimeFormat)
+	for seq := 0; ; seq++ {
+		name := fmt.Sprintf("%s-%s%s", prefix, ts, ext)
+		if seq > 0 {
+			name = fmt.Sprintf("%s-%s-%d%s", prefix, ts, seq, ext)
+		}
+		path := filepath.Join(dir, name)
+		if !exists(path) && !exists(path+compressSuffix) {
+			return path
+		}
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return !os.IsNotExist(err)
+}
+
+// mill 通知后台执行压缩及清理. 调用者持有r.mu.
+func (r *RotatingFile) mill() {
+	if r.closed {
+		return
+	}
+	if !r.cfg.Compress && r.cfg.MaxBackups <= 0 && r.cfg.MaxAge <= 0 {
+		return
+	}
+	if r.millCh == nil {
+		r.millCh = make(chan struct{}, 1)
+		go func(ch <-chan struct{}) {
+			for range ch {
+				_ = r.millRun()
+				r.millWg.Done()
+			}
+		}(r.millCh)
+	}
+	r.millWg.Add(1)
+	select {
+	case r.millCh <- struct{}{}:
+	default:
+		// 已有待执行的清理
+		r.millWg.Done()
+	}
+}
+
+type backupFile struct {
+	path string
+	t    time.Time
+	seq  int
+}
+
+// millRun 压缩轮转文件, 并删除超过保留个数或保留时间的轮转文件.
+func (r *RotatingFile) millRun() error {
+	r.mu.Lock()
+	cfg := r.cfg
+	r.mu.Unlock()
+
+	backups, err := r.backups(cfg.LocalTime)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Time{}
+	if cfg.MaxAge > 0 {
+		cutoff = r.clock.Now().Add(-cfg.MaxAge)
+	}
+	var errs []string
+	for i, b := range backups {
+		if (cfg.MaxBackups > 0 && i >= cfg.MaxBackups) || (!cutoff.IsZero() && b.t.Before(cutoff)) {
+			if err := os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err.Error())
+			}
+			continue
+		}
+		if cfg.Compress && !strings.HasSuffix(b.path, compressSuffix) {
+			if err := compressFile(b.path); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("mill rotated log files: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// backups 返回所有轮转文件, 按轮转时间从新到旧排序.
+func (r *RotatingFile) backups(localTime bool) ([]backupFile, error) {
+	dir := filepath.Dir(r.filename)
+	ext := filepath.Ext(r.filename)
+	prefix := strings.TrimSuffix(filepath.Base(r.filename), ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	loc := time.UTC
+	if localTime {
+		loc = time.Local
+	}
+
+	backups := make([]backupFile, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := strings.TrimSuffix(e.Name(), compressSuffix)
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		ts := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+		seq := 0
+		t, err := time.ParseInLocation(backupTimeFormat, ts, loc)
+		if err != nil {
+			// 同一毫秒内轮转的文件带有序号
+			i := strings.LastIndex(ts, "-")
+			if i < 0 {
+				continue
+			}
+			if seq, err = strconv.Atoi(ts[i+1:]); err != nil || seq <= 0 {
+				continue
+			}
+			if t, err = time.ParseInLocation(backupTimeFormat, ts[:i], loc); err != nil {
+				continue
+			}
+		}
+		backups = append(backups, backupFile{path: filepath.Join(dir, e.Name()), t: t, seq: seq})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].t.Equal(backups[j].t) {
+			return backups[i].t.After(backups[j].t)
+		}
+		return backups[i].seq > backups[j].seq
+	})
+	return backups, nil
+}
+
+// compressFile gzip压缩文件, 成功后删除原文件.
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := path + compressSuffix + ".tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if err == nil {
+		err = gz.Close()
+	}
+	if cErr := dst.Close(); err == nil {
+		err = cErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path+compressSuffix)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Remove(path)
+}
+
+// 同一路径共享一个轮转文件, 避免多个日志器各自轮转同一文件.
+var (
+	rotatingFiles   = map[string]*RotatingFile{}
+	rotatingFilesMu sync.Mutex
+	reopenOnce      sync.Once
+)
+
+//nolint:gochecknoinits
+func init() {
+	_ = zap.RegisterSink(RotateScheme, newRotateSink)
+}
+
+// newRotateSink 根据rotate URL打开轮转文件.
+func newRotateSink(u *url.URL) (zap.Sink, error) {
+	path := u.Path
+	if path == "" {
+		path = u.Opaque
+	}
+	if path == "" {
+		return nil, fmt.Errorf("rotate sink requires a file path: %s", u.String())
+	}
+
+	q := u.Query()
+	cfg := RotateConfig{
+		Compress:  q.Get("compress") == "true",
+		LocalTime: q.Get("localtime") == "true",
+	}
+	var err error
+	if v := q.Get("max-size"); v != "" {
+		if cfg.MaxSize, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid max-size %q: %w", v, err)
+		}
+	}
+	if v := q.Get("max-backups"); v != "" {
+		if cfg.MaxBackups, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("invalid max-backups %q: %w", v, err)
+		}
+	}
+	if v := q.Get("interval"); v != "" {
+		if cfg.RotateInterval, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("invalid interval %q: %w", v, err)
+		}
+	}
+	if v := q.Get("max-age"); v != "" {
+		if cfg.MaxAge, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("invalid max-age %q: %w", v, err)
+		}
+	}
+
+	path = filepath.Clean(path)
+	rotatingFilesMu.Lock()
+	defer rotatingFilesMu.Unlock()
+	if r, ok := rotatingFiles[path]; ok {
+		r.SetConfig(cfg)
+		return r, nil
+	}
+	r := NewRotatingFile(path, cfg)
+	rotatingFiles[path] = r
+	return r, nil
+}
+
+// RotateURL 生成轮转文件输出的URL, 可以直接作为OutputPaths使用.
+func RotateURL(path string, cfg RotateConfig) string {
+	q := url.Values{}
+	if cfg.MaxSize > 0 {
+		q.Set("max-size", strconv.FormatInt(cfg.MaxSize, 10))
+	}
+	if cfg.RotateInterval > 0 {
+		q.Set("interval", cfg.RotateInterval.String())
+	}
+	if cfg.MaxBackups > 0 {
+		q.Set("max-backups", strconv.Itoa(cfg.MaxBackups))
+	}
+	if cfg.MaxAge > 0 {
+		q.Set("max-age", cfg.MaxAge.String())
+	}
+	if cfg.Compress {
+		q.Set("compress", "true")
+	}
+	if cfg.LocalTime {
+		q.Set("localtime", "true")
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	u := url.URL{Scheme: RotateScheme, Path: filepath.ToSlash(path), RawQuery: q.Encode()}
+	return u.String()
+}
+
+// ReopenFiles 重新打开所有轮转文件.
+func ReopenFiles() error {
+	rotatingFilesMu.Lock()
+	defer rotatingFilesMu.Unlock()
+
+	var errs []string
+	for _, r := range rotatingFiles {
+		if err := r.Reopen(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("reopen log files: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ReopenOnSIGHUP 收到SIGHUP信号时重新打开所有轮转文件. 多次调用只注册一次.
+func ReopenOnSIGHUP() {
+	reopenOnce.Do(func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, syscall.SIGHUP)
+		go func() {
+			for range ch {
+				_ = ReopenFiles()
+			}
+		}()
+	})
+}
@@ -0,0 +1,207 @@
+package log_test
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/wangweihong/gotoolbox/pkg/clock"
+	"github.com/wangweihong/gotoolbox/pkg/log"
+)
+
+func listDir(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestRotatingFile_Size(t *testing.T) {
+	dir := t.TempDir()
+	fakeClock := clock.NewFakePassiveClock(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))
+	r := log.NewRotatingFileWithClock(filepath.Join(dir, "app.log"), log.RotateConfig{
+		MaxSize:    10,
+		MaxBackups: 2,
+		Compress:   true,
+	}, fakeClock)
+
+	for i := 0; i < 4; i++ {
+		_, err := r.Write([]byte("0123456789"))
+		assert.NoError(t, err)
+		fakeClock.SetTime(fakeClock.Now().Add(time.Second))
+	}
+	assert.NoError(t, r.Close())
+
+	// 当前文件 + 最多2个压缩的轮转文件
+	assert.Equal(t, []string{
+		"app-2024-05-01T10-00-02.000.log.gz",
+		"app-2024-05-01T10-00-03.000.log.gz",
+		"app.log",
+	}, listDir(t, dir))
+
+	f, err := os.Open(filepath.Join(dir, "app-2024-05-01T10-00-03.000.log.gz"))
+	assert.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(gz)
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", string(data))
+}
+
+func TestRotatingFile_Interval(t *testing.T) {
+	dir := t.TempDir()
+	fakeClock := clock.NewFakePassiveClock(time.Date(2024, 5, 1, 23, 59, 0, 0, time.UTC))
+	r := log.NewRotatingFileWithClock(filepath.Join(dir, "app.log"), log.RotateConfig{
+		RotateInterval: 24 * time.Hour,
+		MaxAge:         48 * time.Hour,
+	}, fakeClock)
+
+	_, _ = r.Write([]byte("day1\n"))
+	fakeClock.SetTime(time.Date(2024, 5, 2, 0, 0, 1, 0, time.UTC))
+	_, _ = r.Write([]byte("day2\n"))
+	assert.Equal(t, []string{"app-2024-05-02T00-00-01.000.log", "app.log"}, listDir(t, dir))
+
+	// 超过保留时间的轮转文件被删除
+	fakeClock.SetTime(time.Date(2024, 5, 5, 0, 0, 1, 0, time.UTC))
+	_, _ = r.Write([]byte("day5\n"))
+	assert.NoError(t, r.Close())
+	assert.Equal(t, []string{"app-2024-05-05T00-00-01.000.log", "app.log"}, listDir(t, dir))
+}
+
+func TestRotatingFile_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	r := log.NewRotatingFile(path, log.RotateConfig{})
+	_, _ = r.Write([]byte("before\n"))
+
+	// 模拟logrotate移走文件后发送SIGHUP
+	assert.NoError(t, os.Rename(path, path+".1"))
+	assert.NoError(t, r.Reopen())
+	_, _ = r.Write([]byte("after\n"))
+	assert.NoError(t, r.Close())
+
+	data, _ := os.ReadFile(path + ".1")
+	assert.Equal(t, "before\n", string(data))
+	data, _ = os.ReadFile(path)
+	assert.Equal(t, "after\n", string(data))
+}
+
+func TestOptions_Rotate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logs", "app.log")
+
+	opts := log.NewOptions()
+	opts.OutputPaths = []string{path}
+	opts.ErrorOutputPaths = []string{"stderr"}
+	opts.RotateMaxSize = 1
+	opts.RotateMaxBackups = 1
+	opts.ReopenOnSIGHUP = true
+	assert.Empty(t, opts.Validate())
+
+	logger := log.New(opts)
+	logger.Info("rotate me")
+	logger.Flush()
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(string(data), "rotate me"))
+
+	assert.NoError(t, os.Rename(path, path+".1"))
+	assert.NoError(t, log.ReopenFiles())
+	logger.Info("after reopen")
+	logger.Flush()
+	data, err = os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(string(data), "after reopen"))
+}
+
+func TestRotatingFile_SameTime(t *testing.T) {
+	dir := t.TempDir()
+	fakeClock := clock.NewFakePassiveClock(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))
+	r := log.NewRotatingFileWithClock(filepath.Join(dir, "app.log"), log.RotateConfig{MaxBackups: 2}, fakeClock)
+
+	// 同一毫秒内多次轮转不覆盖已有的轮转文件
+	for _, s := range []string{"first", "second", "third"} {
+		_, err := r.Write([]byte(s))
+		assert.NoError(t, err)
+		assert.NoError(t, r.Rotate())
+	}
+	assert.NoError(t, r.Close())
+
+	assert.Equal(t, []string{
+		"app-2024-05-01T10-00-00.000-1.log",
+		"app-2024-05-01T10-00-00.000-2.log",
+		"app.log",
+	}, listDir(t, dir))
+	data, _ := os.ReadFile(filepath.Join(dir, "app-2024-05-01T10-00-00.000-2.log"))
+	assert.Equal(t, "third", string(data))
+}
+
+func TestRotatingFile_CloseStopsMill(t *testing.T) {
+	before := runtime.NumGoroutine()
+	r := log.NewRotatingFile(filepath.Join(t.TempDir(), "app.log"), log.RotateConfig{MaxBackups: 1})
+	_, _ = r.Write([]byte("data"))
+	assert.NoError(t, r.Rotate())
+	assert.NoError(t, r.Close())
+
+	// 后台清理协程在Close后退出
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before)
+}
+
+func TestRotatingFile_WriteAfterClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	r := log.NewRotatingFile(path, log.RotateConfig{MaxSize: 1, MaxBackups: 1})
+	_, err := r.Write([]byte("data"))
+	assert.NoError(t, err)
+	assert.NoError(t, r.Close())
+	assert.NoError(t, r.Close())
+	assert.NoError(t, os.Remove(path))
+
+	// 关闭后不再重新打开文件, 也不再轮转
+	_, err = r.Write([]byte("data"))
+	assert.ErrorIs(t, err, os.ErrClosed)
+	assert.ErrorIs(t, r.Rotate(), os.ErrClosed)
+	assert.ErrorIs(t, r.Reopen(), os.ErrClosed)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRotateSink_ReopenAfterClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	u := log.RotateURL(path, log.RotateConfig{MaxBackups: 1})
+
+	sink, closeFn, err := zap.Open(u)
+	assert.NoError(t, err)
+	_, err = sink.Write([]byte("first\n"))
+	assert.NoError(t, err)
+	closeFn()
+
+	// 关闭的轮转文件从共享列表中移除, 相同路径重新打开可以继续写入
+	sink, closeFn, err = zap.Open(u)
+	assert.NoError(t, err)
+	defer closeFn()
+	_, err = sink.Write([]byte("second\n"))
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "first\nsecond\n", string(data))
+}
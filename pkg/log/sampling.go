@@ -0,0 +1,237 @@
+package log
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/wangweihong/gotoolbox/pkg/clock"
+	"github.com/wangweihong/gotoolbox/pkg/rate"
+)
+
+// RepeatedKey 去重日志汇总行中记录重复次数的字段.
+const RepeatedKey = "repeated"
+
+// limiter空闲超过该时间后被清理.
+const rateLimiterIdleTimeout = time.Minute
+
+// entryKey 日志去重/限速的键: 同一日志器同一级别的同一消息.
+type entryKey struct {
+	level   zapcore.Level
+	name    string
+	message string
+}
+
+func keyOf(ent zapcore.Entry) entryKey {
+	return entryKey{level: ent.Level, name: ent.LoggerName, message: ent.Message}
+}
+
+// NewRateLimitCore 对每条消息按令牌桶限速, 每秒最多输出limit条, 允许突发burst条. 超出的日志被丢弃.
+func NewRateLimitCore(core zapcore.Core, limit float64, burst int) zapcore.Core {
+	return NewRateLimitCoreWithClock(core, limit, burst, clock.RealClock{})
+}
+
+func NewRateLimitCoreWithClock(core zapcore.Core, limit float64, burst int, c clock.PassiveClock) zapcore.Core {
+	if burst <= 0 {
+		burst = 1
+	}
+	return &rateLimitCore{
+		Core: core,
+		state: &rateLimitState{
+			limit:    rate.Limit(limit),
+			burst:    burst,
+			clock:    c,
+			limiters: make(map[entryKey]*keyLimiter),
+		},
+	}
+}
+
+type rateLimitCore struct {
+	zapcore.Core
+	// With派生的core共享限速状态
+	state *rateLimitState
+}
+
+type rateLimitState struct {
+	limit rate.Limit
+	burst int
+	clock clock.PassiveClock
+
+	mu        sync.Mutex
+	limiters  map[entryKey]*keyLimiter
+	lastSweep time.Time
+}
+
+type keyLimiter struct {
+	limiter *rate.Limiter
+	last    time.Time
+}
+
+func (s *rateLimitState) allow(key entryKey) bool {
+	now := s.clock.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 定期清理空闲的limiter, 避免消息种类过多时占用内存
+	if now.Sub(s.lastSweep) >= rateLimiterIdleTimeout {
+		for k, l := range s.limiters {
+			if now.Sub(l.last) >= rateLimiterIdleTimeout {
+				delete(s.limiters, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	l, ok := s.limiters[key]
+	if !ok {
+		l = &keyLimiter{limiter: rate.NewLimiter(s.limit, s.burst)}
+		s.limiters[key] = l
+	}
+	l.last = now
+	return l.limiter.AllowN(now, 1)
+}
+
+func (c *rateLimitCore) With(fields []zapcore.Field) zapcore.Core {
+	return &rateLimitCore{
+		Core:  c.Core.With(fields),
+		state: c.state,
+	}
+}
+
+func (c *rateLimitCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(ent.Level) {
+		return ce
+	}
+	if !c.state.allow(keyOf(ent)) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+// NewDedupCore 在window时间窗口内, 同一消息只输出第一条, 其余的被合并.
+// 窗口结束后输出一条带repeated字段的汇总日志, 记录被合并的条数.
+// 窗口结束在下一条日志写入或Sync时检查.
+func NewDedupCore(core zapcore.Core, window time.Duration) zapcore.Core {
+	return NewDedupCoreWithClock(core, window, clock.RealClock{})
+}
+
+func NewDedupCoreWithClock(core zapcore.Core, window time.Duration, c clock.PassiveClock) zapcore.Core {
+	return &dedupCore{
+		Core: core,
+		state: &dedupState{
+			window:  window,
+			clock:   c,
+			entries: make(map[entryKey]*dedupEntry),
+		},
+	}
+}
+
+type dedupCore struct {
+	zapcore.Core
+	// With派生的core共享去重状态
+	state *dedupState
+}
+
+type dedupState struct {
+	window time.Duration
+	clock  clock.PassiveClock
+
+	mu        sync.Mutex
+	entries   map[entryKey]*dedupEntry
+	lastSweep time.Time
+}
+
+type dedupEntry struct {
+	ent   zapcore.Entry
+	start time.Time
+	count int
+	// 输出汇总日志使用的core, 保留With添加的字段
+	core zapcore.Core
+}
+
+// observe 记录日志, 返回是否需要输出, 以及已结束窗口的汇总日志.
+func (s *dedupState) observe(ent zapcore.Entry, core zapcore.Core) (bool, []*dedupEntry) {
+	now := s.clock.Now()
+	key := keyOf(ent)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expired []*dedupEntry
+	if now.Sub(s.lastSweep) >= s.window {
+		expired = s.sweep(now, false)
+		s.lastSweep = now
+	}
+
+	if e, ok := s.entries[key]; ok {
+		if now.Sub(e.start) < s.window {
+			e.count++
+			return false, expired
+		}
+		delete(s.entries, key)
+		if e.count > 0 {
+			expired = append(expired, e)
+		}
+	}
+	s.entries[key] = &dedupEntry{ent: ent, start: now, core: core}
+	return true, expired
+}
+
+// sweep 移除已结束的窗口, 返回有合并日志的窗口. all为true时移除所有窗口.
+func (s *dedupState) sweep(now time.Time, all bool) []*dedupEntry {
+	var expired []*dedupEntry
+	for k, e := range s.entries {
+		if !all && now.Sub(e.start) < s.window {
+			continue
+		}
+		delete(s.entries, k)
+		if e.count > 0 {
+			expired = append(expired, e)
+		}
+	}
+	return expired
+}
+
+// emit 输出汇总日志. 在锁外调用.
+func (s *dedupState) emit(expired []*dedupEntry) {
+	now := s.clock.Now()
+	for _, e := range expired {
+		ent := e.ent
+		ent.Time = now
+		if ce := e.core.Check(ent, nil); ce != nil {
+			ce.Write(zap.Int(RepeatedKey, e.count))
+		}
+	}
+}
+
+func (c *dedupCore) With(fields []zapcore.Field) zapcore.Core {
+	return &dedupCore{
+		Core:  c.Core.With(fields),
+		state: c.state,
+	}
+}
+
+func (c *dedupCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(ent.Level) {
+		return ce
+	}
+	write, expired := c.state.observe(ent, c.Core)
+	c.state.emit(expired)
+	if !write {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+// Sync 输出所有窗口的汇总日志.
+func (c *dedupCore) Sync() error {
+	c.state.mu.Lock()
+	expired := c.state.sweep(c.state.clock.Now(), true)
+	c.state.mu.Unlock()
+	c.state.emit(expired)
+
+	return c.Core.Sync()
+}
@@ -0,0 +1,102 @@
+package log_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/wangweihong/gotoolbox/pkg/clock"
+	"github.com/wangweihong/gotoolbox/pkg/log"
+)
+
+func TestRateLimitCore(t *testing.T) {
+	fakeClock := clock.NewFakePassiveClock(time.Now())
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(log.NewRateLimitCoreWithClock(core, 1, 2, fakeClock))
+
+	for i := 0; i < 10; i++ {
+		logger.Error("reconcile failed")
+		logger.Info("other message")
+	}
+	assert.Equal(t, 2, logs.FilterMessage("reconcile failed").Len())
+	assert.Equal(t, 2, logs.FilterMessage("other message").Len())
+
+	// 每秒补充一个令牌
+	fakeClock.SetTime(fakeClock.Now().Add(time.Second))
+	logger.With(zap.String("key", "a")).Error("reconcile failed")
+	logger.Error("reconcile failed")
+	assert.Equal(t, 3, logs.FilterMessage("reconcile failed").Len())
+}
+
+func TestDedupCore(t *testing.T) {
+	fakeClock := clock.NewFakePassiveClock(time.Now())
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(log.NewDedupCoreWithClock(core, time.Minute, fakeClock)).With(zap.String("syncer", "user"))
+
+	for i := 0; i < 1000; i++ {
+		logger.Error("reconcile failed")
+	}
+	logger.Info("done")
+	assert.Equal(t, 2, logs.Len())
+
+	// 窗口结束后输出重复次数
+	fakeClock.SetTime(fakeClock.Now().Add(time.Minute))
+	logger.Error("reconcile failed")
+	entries := logs.FilterMessage("reconcile failed").All()
+	assert.Equal(t, 3, len(entries))
+	assert.Equal(t, map[string]any{"syncer": "user", log.RepeatedKey: int64(999)}, entries[1].ContextMap())
+	assert.Equal(t, map[string]any{"syncer": "user"}, entries[2].ContextMap())
+
+	// Sync时输出所有窗口的重复次数
+	logger.Error("reconcile failed")
+	assert.NoError(t, logger.Sync())
+	entries = logs.FilterMessage("reconcile failed").All()
+	assert.Equal(t, 4, len(entries))
+	assert.Equal(t, int64(1), entries[3].ContextMap()[log.RepeatedKey])
+}
+
+func TestOptions_Sampling(t *testing.T) {
+	opts := log.NewOptions()
+	opts.OutputPaths = nil
+	opts.ErrorOutputPaths = nil
+	opts.SamplingInitial = -1
+	opts.DedupWindow = -time.Second
+	assert.Len(t, opts.Validate(), 2)
+
+	opts.SamplingInitial = 1
+	opts.SamplingThereafter = 0
+	opts.RateLimit = 10
+	opts.DedupWindow = time.Second
+	assert.Empty(t, opts.Validate())
+
+	tests := []struct {
+		name     string
+		modify   func(o *log.Options)
+		expected int
+	}{
+		{"不采样", func(o *log.Options) { o.SamplingInitial = 0 }, 10},
+		{"只输出前N条", func(o *log.Options) { o.SamplingInitial = 2; o.SamplingThereafter = 0 }, 2},
+		{"之后每N条输出一条", func(o *log.Options) { o.SamplingInitial = 1; o.SamplingThereafter = 3 }, 4},
+		{"限速", func(o *log.Options) { o.SamplingInitial = 0; o.RateLimit = 1; o.RateLimitBurst = 3 }, 3},
+		{"去重", func(o *log.Options) { o.SamplingInitial = 0; o.DedupWindow = time.Minute }, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := log.NewOptions()
+			opts.SamplingInterval = time.Minute
+			tt.modify(opts)
+			assert.Empty(t, opts.Validate())
+
+			core, logs := observer.New(zapcore.InfoLevel)
+			logger := zap.New(opts.WrapCore(core))
+			for i := 0; i < 10; i++ {
+				logger.Info("sampled")
+			}
+			assert.Equal(t, tt.expected, logs.FilterMessage("sampled").Len())
+		})
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"github.com/kr/pretty"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+
+	"github.com/wangweihong/gotoolbox/pkg/redact"
 )
 
 // Defines common log fields.
@@ -101,8 +103,9 @@ var (
 
 // Every constructs a field that carries a pretty string.
 // 5 times performance compared with "Any".
+// 敏感字段按redact.Default()脱敏.
 func Every(key string, val any) Field {
-	byteData, err := json.Marshal(val)
+	byteData, err := json.Marshal(redact.Default().Value(val))
 	if err != nil {
 		return Any(key, val)
 	}
@@ -112,8 +115,9 @@ func Every(key string, val any) Field {
 
 // Pretty log data with reflect type. It can log which type data (and object field' type) is.
 // but **50** times performance compared with "Any".
+// 敏感字段按redact.Default()脱敏.
 func Pretty(key string, val any) Field {
-	s := fmt.Sprintf("%# v", pretty.Formatter(val))
+	s := fmt.Sprintf("%# v", pretty.Formatter(redact.Default().Value(val)))
 	s = strings.Replace(s, "\n", "", -1)
 	s = strings.Replace(s, "\t", "", -1)
 	s = strings.Replace(s, " ", "", -1)
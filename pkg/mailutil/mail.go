@@ -38,7 +38,7 @@ type SMTPServerConfig struct {
 	SMTPServerPort int    //SMTP服务器端口
 	TLSEnabled     bool   //SMTP是否开启TLS服务
 	SMTPAccount    string //SMTP账号
-	SMTPPassword   string //SMTP密码
+	SMTPPassword   string `log:"sensitive"` //SMTP密码
 	SenderName     string //发件人名称: 收件人看到的发件者信息
 	SenderMail     string //发件人地址
 }
@@ -48,7 +48,7 @@ type smtpSender struct {
 	SMTPServerPort int
 	TLSEnabled     bool
 	SMTPAccount    string
-	SMTPPassword   string
+	SMTPPassword   string `log:"sensitive"`
 	SenderName     string
 	SenderMail     string
 }
@@ -0,0 +1,522 @@
+package redact
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"unicode"
+
+	"github.com/wangweihong/gotoolbox/pkg/json"
+)
+
+const (
+	// DefaultMask 敏感信息替换后的值.
+	DefaultMask = "******"
+	// DefaultTag 标记敏感字段的结构体标签, 如`log:"sensitive"`.
+	DefaultTag = "log"
+
+	sensitiveTagValue = "sensitive"
+	// 脱敏时最大递归深度, 避免循环引用
+	maxDepth = 32
+)
+
+// DefaultKeys 默认的敏感字段名. 字段名按驼峰及'-'/'_'/'.'分词后忽略大小写比较,
+// 以敏感字段名(可以由多个词组成)结尾即为敏感字段, 如access_token、X-Api-Key, 而TokenTTL、MaxTokens不是.
+var DefaultKeys = []string{
+	"password", "passwd", "pwd", "secret", "token", "authorization", "cookie", "cookies",
+	"credential", "credentials", "apikey", "accesskey", "secretkey", "privatekey",
+}
+
+// DefaultPatterns 默认的敏感内容正则, 只匹配Authorization头上下文或令牌形式的内容, 避免误伤普通文本.
+// 正则中存在名为secret的分组时只替换该分组.
+var DefaultPatterns = []*regexp.Regexp{
+	// Authorization: Bearer xxx / authorization=Basic xxx
+	regexp.MustCompile(`(?i)\bauthorization"?\s*[:=]\s*"?(?:(?:bearer|basic|digest|token)\s+)?(?P<secret>[^\s",;]+)`),
+	// 令牌形式的bearer token
+	regexp.MustCompile(`(?i)\bbearer\s+(?P<secret>[a-z0-9\-._~+/]{16,}=*)`),
+	// JWT
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{4,}\.eyJ[A-Za-z0-9_-]{4,}\.[A-Za-z0-9_-]*`),
+}
+
+// Policy 脱敏策略: 敏感字段名、敏感内容正则及结构体标签.
+type Policy struct {
+	keys     []string
+	patterns []*regexp.Regexp
+	tag      string
+	mask     string
+}
+
+type PolicyOption func(*Policy)
+
+// WithKeys 增加敏感字段名.
+func WithKeys(keys ...string) PolicyOption {
+	return func(p *Policy) {
+		for _, k := range keys {
+			if k = normalize(k); k != "" {
+				p.keys = append(p.keys, k)
+			}
+		}
+	}
+}
+
+// WithPatterns 增加敏感内容正则.
+func WithPatterns(patterns ...*regexp.Regexp) PolicyOption {
+	return func(p *Policy) {
+		p.patterns = append(p.patterns, patterns...)
+	}
+}
+
+// WithTag 设置标记敏感字段的结构体标签名, 标签值包含sensitive的字段被脱敏.
+func WithTag(tag string) PolicyOption {
+	return func(p *Policy) {
+		p.tag = tag
+	}
+}
+
+// WithMask 设置敏感信息替换后的值.
+func WithMask(mask string) PolicyOption {
+	return func(p *Policy) {
+		p.mask = mask
+	}
+}
+
+// NewPolicy 创建脱敏策略, 默认包含DefaultKeys及DefaultPatterns.
+func NewPolicy(opts ...PolicyOption) *Policy {
+	p := &Policy{
+		tag:      DefaultTag,
+		mask:     DefaultMask,
+		patterns: append([]*regexp.Regexp(nil), DefaultPatterns...),
+	}
+	WithKeys(DefaultKeys...)(p)
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// With 返回增加了选项的策略副本.
+func (p *Policy) With(opts ...PolicyOption) *Policy {
+	np := &Policy{
+		keys:     append([]string(nil), p.keys...),
+		patterns: append([]*regexp.Regexp(nil), p.patterns...),
+		tag:      p.tag,
+		mask:     p.mask,
+	}
+	for _, opt := range opts {
+		opt(np)
+	}
+	return np
+}
+
+func (p *Policy) Mask() string {
+	return p.mask
+}
+
+var defaultPolicy atomic.Pointer[Policy]
+
+func init() {
+	defaultPolicy.Store(NewPolicy())
+}
+
+// Default 返回全局脱敏策略, pkg/log及httpcli调试日志使用该策略.
+func Default() *Policy {
+	return defaultPolicy.Load()
+}
+
+// SetDefault 设置全局脱敏策略.
+func SetDefault(p *Policy) {
+	if p != nil {
+		defaultPolicy.Store(p)
+	}
+}
+
+func normalize(key string) string {
+	return strings.Join(splitWords(key), "")
+}
+
+// splitWords 按驼峰、数字及非字母数字字符分词, 返回小写的词. 如SMTPPassword返回[smtp password].
+func splitWords(key string) []string {
+	var words []string
+	runes := []rune(key)
+	start := -1
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if start >= 0 {
+				words = append(words, strings.ToLower(string(runes[start:i])))
+				start = -1
+			}
+			continue
+		}
+		if start >= 0 && isWordBoundary(runes, i) {
+			words = append(words, strings.ToLower(string(runes[start:i])))
+			start = i
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, strings.ToLower(string(runes[start:])))
+	}
+	return words
+}
+
+// isWordBoundary runes[i]是否为新词的开始, runes[i-1]为字母或数字.
+func isWordBoundary(runes []rune, i int) bool {
+	prev, cur := runes[i-1], runes[i]
+	switch {
+	case unicode.IsDigit(prev) != unicode.IsDigit(cur):
+		return true
+	case unicode.IsLower(prev) && unicode.IsUpper(cur):
+		return true
+	case unicode.IsUpper(prev) && unicode.IsUpper(cur):
+		// 连续大写字母后接小写字母时, 最后一个大写字母为新词的开始, 如SMTPPassword
+		return i+1 < len(runes) && unicode.IsLower(runes[i+1])
+	}
+	return false
+}
+
+// IsSensitiveKey 字段名是否为敏感字段: 分词后以敏感字段名结尾(忽略末尾的数字), 如user_password、X-Auth-Token.
+func (p *Policy) IsSensitiveKey(key string) bool {
+	words := splitWords(key)
+	for len(words) > 0 && isNumber(words[len(words)-1]) {
+		words = words[:len(words)-1]
+	}
+	suffix := ""
+	for i := len(words) - 1; i >= 0; i-- {
+		suffix = words[i] + suffix
+		for _, k := range p.keys {
+			if suffix == k {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isNumber(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// IsSensitiveField 结构体字段是否为敏感字段: 带有敏感标签, 或字段名/json名为敏感字段名.
+func (p *Policy) IsSensitiveField(f reflect.StructField) bool {
+	if p.tag != "" {
+		for _, v := range strings.Split(f.Tag.Get(p.tag), ",") {
+			if strings.TrimSpace(v) == sensitiveTagValue {
+				return true
+			}
+		}
+	}
+	if p.IsSensitiveKey(f.Name) {
+		return true
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	return name != "" && name != "-" && p.IsSensitiveKey(name)
+}
+
+// String 替换字符串中匹配敏感内容正则的部分. 正则中存在名为secret的分组时只替换该分组.
+func (p *Policy) String(s string) string {
+	for _, re := range p.patterns {
+		s = p.replace(re, s)
+	}
+	return s
+}
+
+func (p *Policy) replace(re *regexp.Regexp, s string) string {
+	group := re.SubexpIndex("secret")
+	matches := re.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if group > 0 && m[2*group] >= 0 {
+			start, end = m[2*group], m[2*group+1]
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(p.mask)
+		last = end
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// Header 返回脱敏后的http头副本.
+func (p *Policy) Header(h http.Header) http.Header {
+	if h == nil {
+		return nil
+	}
+	nh := make(http.Header, len(h))
+	for name, values := range h {
+		vs := make([]string, len(values))
+		for i, v := range values {
+			if p.IsSensitiveKey(name) {
+				vs[i] = p.mask
+			} else {
+				vs[i] = p.String(v)
+			}
+		}
+		nh[name] = vs
+	}
+	return nh
+}
+
+// URL 替换url中敏感的查询参数及用户密码.
+func (p *Policy) URL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return p.String(rawURL)
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), p.mask)
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = p.Query(u.RawQuery)
+	}
+	return u.String()
+}
+
+// Query 替换url查询参数或application/x-www-form-urlencoded表单中的敏感参数, 无法解析时按字符串处理.
+func (p *Policy) Query(rawQuery string) string {
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return p.String(rawQuery)
+	}
+	for k, vs := range query {
+		for i := range vs {
+			if p.IsSensitiveKey(k) {
+				vs[i] = p.mask
+			} else {
+				vs[i] = p.String(vs[i])
+			}
+		}
+	}
+	return query.Encode()
+}
+
+// Body 根据Content-Type脱敏请求或响应体: 表单按Query处理, 其他按JSON处理.
+func (p *Policy) Body(contentType string, data []byte) []byte {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	if strings.EqualFold(strings.TrimSpace(mediaType), "application/x-www-form-urlencoded") {
+		return []byte(p.Query(string(data)))
+	}
+	return p.JSON(data)
+}
+
+// JSON 脱敏json数据, 无法解析时按字符串处理.
+func (p *Policy) JSON(data []byte) []byte {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return []byte(p.String(string(data)))
+	}
+	out, err := json.Marshal(p.Value(v))
+	if err != nil {
+		return []byte(p.String(string(data)))
+	}
+	return out
+}
+
+// Value 返回脱敏后的副本, 敏感字段替换成mask, 结构体的未导出字段原样复制.
+// 副本类型通常与原值相同; 敏感字段的类型无法保存mask(如int)时, 所在结构体转换成以json字段名为key的map[string]any,
+// 所在map/切片转换成元素为any的map/切片, 使输出中该字段为mask而不是具有误导性的零值.
+func (p *Policy) Value(v any) any {
+	if v == nil {
+		return nil
+	}
+	rv := p.value(reflect.ValueOf(v), 0)
+	if !rv.IsValid() || !rv.CanInterface() {
+		return v
+	}
+	return rv.Interface()
+}
+
+var anyType = reflect.TypeOf((*any)(nil)).Elem()
+
+// assignable 值是否可以保存到t类型中.
+func assignable(v reflect.Value, t reflect.Type) bool {
+	return v.IsValid() && v.Type().AssignableTo(t)
+}
+
+// value 返回脱敏后的副本. 返回值类型与v不同时, 调用者需要转换成可以保存任意值的容器.
+func (p *Policy) value(v reflect.Value, depth int) reflect.Value {
+	if depth > maxDepth {
+		return v
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		s := v.String()
+		if rs := p.String(s); rs != s {
+			nv := reflect.New(v.Type()).Elem()
+			nv.SetString(rs)
+			return nv
+		}
+		return v
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		ev := p.value(v.Elem(), depth+1)
+		if !assignable(ev, v.Type().Elem()) {
+			return ev
+		}
+		nv := reflect.New(v.Type().Elem())
+		nv.Elem().Set(ev)
+		return nv
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		ev := p.value(v.Elem(), depth+1)
+		if !assignable(ev, v.Type()) {
+			return ev
+		}
+		nv := reflect.New(v.Type()).Elem()
+		nv.Set(ev)
+		return nv
+	case reflect.Struct:
+		return p.structValue(v, depth)
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		return p.mapValue(v, depth)
+	case reflect.Slice:
+		if v.IsNil() || v.Type().Elem().Kind() == reflect.Uint8 {
+			return v
+		}
+		return p.sliceValue(v, depth)
+	case reflect.Array:
+		return p.sliceValue(v, depth)
+	default:
+		return v
+	}
+}
+
+func (p *Policy) structValue(v reflect.Value, depth int) reflect.Value {
+	t := v.Type()
+	values := make([]reflect.Value, t.NumField())
+	same := true
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if p.IsSensitiveField(f) {
+			values[i] = p.masked(f.Type)
+		} else {
+			values[i] = p.value(v.Field(i), depth+1)
+		}
+		same = same && assignable(values[i], f.Type)
+	}
+
+	if same {
+		nv := reflect.New(t).Elem()
+		nv.Set(v)
+		for i, fv := range values {
+			if fv.IsValid() {
+				nv.Field(i).Set(fv)
+			}
+		}
+		return nv
+	}
+
+	m := make(map[string]any, len(values))
+	for i, fv := range values {
+		if !fv.IsValid() {
+			continue
+		}
+		name := t.Field(i).Name
+		if tag, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		m[name] = fv.Interface()
+	}
+	return reflect.ValueOf(m)
+}
+
+func (p *Policy) mapValue(v reflect.Value, depth int) reflect.Value {
+	t := v.Type()
+	stringKey := t.Key().Kind() == reflect.String
+	keys := make([]reflect.Value, 0, v.Len())
+	values := make([]reflect.Value, 0, v.Len())
+	same := true
+	iter := v.MapRange()
+	for iter.Next() {
+		var val reflect.Value
+		if stringKey && p.IsSensitiveKey(iter.Key().String()) {
+			val = p.masked(t.Elem())
+		} else {
+			val = p.value(iter.Value(), depth+1)
+		}
+		keys = append(keys, iter.Key())
+		values = append(values, val)
+		same = same && assignable(val, t.Elem())
+	}
+
+	if !same {
+		t = reflect.MapOf(t.Key(), anyType)
+	}
+	nv := reflect.MakeMapWithSize(t, len(keys))
+	for i := range keys {
+		nv.SetMapIndex(keys[i], values[i])
+	}
+	return nv
+}
+
+func (p *Policy) sliceValue(v reflect.Value, depth int) reflect.Value {
+	t := v.Type()
+	values := make([]reflect.Value, v.Len())
+	same := true
+	for i := range values {
+		values[i] = p.value(v.Index(i), depth+1)
+		same = same && assignable(values[i], t.Elem())
+	}
+
+	var nv reflect.Value
+	switch {
+	case !same:
+		nv = reflect.MakeSlice(reflect.SliceOf(anyType), len(values), len(values))
+	case t.Kind() == reflect.Array:
+		nv = reflect.New(t).Elem()
+	default:
+		nv = reflect.MakeSlice(t, len(values), len(values))
+	}
+	for i, ev := range values {
+		nv.Index(i).Set(ev)
+	}
+	return nv
+}
+
+// masked 返回表示mask的值: 字符串、字符串指针及可以保存字符串的接口为t类型, 其他类型为mask字符串.
+func (p *Policy) masked(t reflect.Type) reflect.Value {
+	switch {
+	case t.Kind() == reflect.String:
+		nv := reflect.New(t).Elem()
+		nv.SetString(p.mask)
+		return nv
+	case t.Kind() == reflect.Pointer && t.Elem().Kind() == reflect.String:
+		nv := reflect.New(t.Elem())
+		nv.Elem().SetString(p.mask)
+		return nv
+	case t.Kind() == reflect.Interface && reflect.TypeOf(p.mask).AssignableTo(t):
+		nv := reflect.New(t).Elem()
+		nv.Set(reflect.ValueOf(p.mask))
+		return nv
+	default:
+		return reflect.ValueOf(p.mask)
+	}
+}
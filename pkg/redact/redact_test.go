@@ -0,0 +1,138 @@
+package redact_test
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/wangweihong/gotoolbox/pkg/redact"
+)
+
+type smtpConfig struct {
+	Server   string
+	Account  string
+	Password string
+	Key      string `log:"sensitive"`
+	Port     int
+	Token    *string
+	Extra    map[string]any
+	Nested   []ldapConfig
+	internal string
+}
+
+type ldapConfig struct {
+	BindDN string `json:"bind_dn"`
+	Secret string `json:"x-secret"`
+}
+
+func TestPolicy(t *testing.T) {
+	p := redact.NewPolicy()
+
+	Convey("敏感字段名", t, func() {
+		So(p.IsSensitiveKey("Password"), ShouldBeTrue)
+		So(p.IsSensitiveKey("bind_passwd"), ShouldBeTrue)
+		So(p.IsSensitiveKey("X-Auth-Token"), ShouldBeTrue)
+		So(p.IsSensitiveKey("access_key"), ShouldBeTrue)
+		So(p.IsSensitiveKey("X-Api-Key"), ShouldBeTrue)
+		So(p.IsSensitiveKey("SMTPPassword"), ShouldBeTrue)
+		So(p.IsSensitiveKey("password2"), ShouldBeTrue)
+		So(p.IsSensitiveKey("username"), ShouldBeFalse)
+		So(p.IsSensitiveKey("TokenTTL"), ShouldBeFalse)
+		So(p.IsSensitiveKey("MaxTokens"), ShouldBeFalse)
+		So(p.IsSensitiveKey("access_token_expires_in"), ShouldBeFalse)
+		So(p.With(redact.WithKeys("phone")).IsSensitiveKey("user_phone"), ShouldBeTrue)
+		So(p.IsSensitiveKey("phone"), ShouldBeFalse)
+	})
+
+	Convey("结构体脱敏返回同类型副本", t, func() {
+		token := "t1"
+		cfg := &smtpConfig{
+			Server:   "smtp.example.com",
+			Account:  "admin",
+			Password: "p@ss",
+			Key:      "k1",
+			Port:     25,
+			Token:    &token,
+			Extra:    map[string]any{"apiKey": "a1", "note": "Bearer abcdefghijklmnop.qrs"},
+			Nested:   []ldapConfig{{BindDN: "cn=admin", Secret: "s1"}},
+			internal: "i1",
+		}
+		out, ok := p.Value(cfg).(*smtpConfig)
+		So(ok, ShouldBeTrue)
+		So(out.Server, ShouldEqual, "smtp.example.com")
+		So(out.Account, ShouldEqual, "admin")
+		So(out.Password, ShouldEqual, redact.DefaultMask)
+		So(out.Key, ShouldEqual, redact.DefaultMask)
+		So(out.Port, ShouldEqual, 25)
+		So(*out.Token, ShouldEqual, redact.DefaultMask)
+		So(out.Extra["apiKey"], ShouldEqual, redact.DefaultMask)
+		So(out.Extra["note"], ShouldEqual, "Bearer "+redact.DefaultMask)
+		So(out.Nested[0].BindDN, ShouldEqual, "cn=admin")
+		So(out.Nested[0].Secret, ShouldEqual, redact.DefaultMask)
+		So(out.internal, ShouldEqual, "i1")
+
+		// 原值不变
+		So(cfg.Password, ShouldEqual, "p@ss")
+		So(token, ShouldEqual, "t1")
+		So(cfg.Extra["apiKey"], ShouldEqual, "a1")
+		So(cfg.Nested[0].Secret, ShouldEqual, "s1")
+	})
+
+	Convey("无法保存mask的敏感字段输出mask而不是零值", t, func() {
+		type account struct {
+			Name     string `json:"name"`
+			PIN      int    `json:"pin" log:"sensitive"`
+			TokenTTL int    `json:"tokenTTL"`
+		}
+		out := p.Value(map[string]any{"accounts": []account{{Name: "a", PIN: 1234, TokenTTL: 300}}})
+		So(out, ShouldResemble, map[string]any{
+			"accounts": []any{map[string]any{"name": "a", "pin": redact.DefaultMask, "tokenTTL": 300}},
+		})
+
+		out = p.Value(map[string]int{"count": 1, "password": 2})
+		So(out, ShouldResemble, map[string]any{"count": 1, "password": redact.DefaultMask})
+	})
+
+	Convey("普通文本不被误伤", t, func() {
+		So(p.String("basic validation failed for bearer account"), ShouldEqual, "basic validation failed for bearer account")
+		So(p.String("Authorization: Basic dXNlcjpwYXNz"), ShouldEqual, "Authorization: Basic "+redact.DefaultMask)
+		So(p.String("token is Bearer abcdefghijklmnopqrstuvwxyz"), ShouldEqual, "token is Bearer "+redact.DefaultMask)
+		So(p.String("jwt eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.sig"), ShouldEqual, "jwt "+redact.DefaultMask)
+	})
+
+	Convey("字符串、http头、url及json", t, func() {
+		p := p.With(redact.WithPatterns(regexp.MustCompile(`\d{11}`)), redact.WithMask("***"))
+		So(p.String("call with 13800000000"), ShouldEqual, "call with ***")
+		So(p.String("Authorization: Bearer eyJhbGc.eyJzdWIi"), ShouldEqual, "Authorization: Bearer ***")
+
+		h := p.Header(http.Header{"X-Auth-Token": {"t1"}, "Accept": {"application/json"}})
+		So(h.Get("X-Auth-Token"), ShouldEqual, "***")
+		So(h.Get("Accept"), ShouldEqual, "application/json")
+
+		u := p.URL("https://admin:p@ss@example.com/login?user=u1&access_token=t1")
+		So(u, ShouldNotContainSubstring, "p@ss")
+		So(u, ShouldNotContainSubstring, "t1")
+		So(u, ShouldContainSubstring, "user=u1")
+
+		out := string(p.JSON([]byte(`{"user":"u1","password":"p1","items":[{"secret":"s1"}]}`)))
+		So(out, ShouldContainSubstring, `"user":"u1"`)
+		So(strings.Contains(out, "p1") || strings.Contains(out, "s1"), ShouldBeFalse)
+		So(string(p.JSON([]byte("not json"))), ShouldEqual, "not json")
+
+		form := string(p.Body("application/x-www-form-urlencoded; charset=utf-8", []byte("client_id=c1&client_secret=s1")))
+		So(form, ShouldContainSubstring, "client_id=c1")
+		So(form, ShouldNotContainSubstring, "s1")
+		So(string(p.Body("application/json", []byte(`{"password":"p1"}`))), ShouldNotContainSubstring, "p1")
+	})
+
+	Convey("全局策略", t, func() {
+		old := redact.Default()
+		defer redact.SetDefault(old)
+
+		redact.SetDefault(old.With(redact.WithKeys("idcard")))
+		So(redact.Default().IsSensitiveKey("IDCard"), ShouldBeTrue)
+	})
+}
@@ -0,0 +1,42 @@
+package wait
+
+import (
+	"time"
+
+	"github.com/wangweihong/gotoolbox/pkg/errors"
+)
+
+// DefaultRetry 默认重试退避: 最多尝试5次, 每次间隔10ms并增加抖动.
+var DefaultRetry = Backoff{
+	Steps:    5,
+	Duration: 10 * time.Millisecond,
+	Factor:   1.0,
+	Jitter:   0.1,
+}
+
+// OnError 按退避策略执行fn, 直到成功、返回不可重试的错误或达到重试次数.
+// retriable判断错误是否可重试, 重试次数用尽时返回最后一次的错误.
+func OnError(backoff Backoff, retriable func(error) bool, fn func() error) error {
+	var lastErr error
+	err := ExponentialBackoff(backoff, func() (bool, error) {
+		err := fn()
+		switch {
+		case err == nil:
+			return true, nil
+		case retriable(err):
+			lastErr = err
+			return false, nil
+		default:
+			return false, err
+		}
+	})
+	if err == ErrWaitTimeout && lastErr != nil { //nolint:errorlint
+		err = lastErr
+	}
+	return err
+}
+
+// OnRetryableError 按退避策略执行fn, 仅重试被标记为可重试的错误, 见errors.IsRetryable.
+func OnRetryableError(backoff Backoff, fn func() error) error {
+	return OnError(backoff, errors.IsRetryable, fn)
+}
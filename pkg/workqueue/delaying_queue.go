@@ -31,8 +31,18 @@ func NewNamedDelayingQueue(name string) DelayingInterface {
 // NewDelayingQueueWithCustomClock constructs a new named workqueue
 // with ability to inject real or fake clock for testing purposes.
 func NewDelayingQueueWithCustomClock(clock clock.Clock, name string) DelayingInterface {
+	return newDelayingQueue(clock, NewNamed(name), name)
+}
+
+// NewDelayingQueueWithCustomQueue 在已有的工作队列(如优先级队列、公平队列)上提供延迟加入能力.
+// 延迟结束后通过q.Add加入.
+func NewDelayingQueueWithCustomQueue(q Interface, name string) DelayingInterface {
+	return newDelayingQueue(clock.RealClock{}, q, name)
+}
+
+func newDelayingQueue(clock clock.Clock, q Interface, name string) *delayingType {
 	ret := &delayingType{
-		Interface:       NewNamed(name),
+		Interface:       q,
 		clock:           clock,
 		heartbeat:       clock.NewTicker(maxWait), // 每隔10秒的定时器
 		stopCh:          make(chan struct{}),
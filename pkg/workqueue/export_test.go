@@ -0,0 +1,8 @@
+package workqueue
+
+// RememberedLen 记住的对象优先级数量, 仅用于测试.
+func (q *PriorityQueue) RememberedLen() int {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	return len(q.order.remembered)
+}
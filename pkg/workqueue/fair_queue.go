@@ -0,0 +1,79 @@
+package workqueue
+
+import (
+	"github.com/wangweihong/gotoolbox/pkg/clock"
+)
+
+// ShareKeyFunc 计算对象所属的分组, 如租户.
+type ShareKeyFunc func(item interface{}) string
+
+// NewFairShareQueue 创建公平队列: 对象按keyFunc分组, 各分组轮流出队, 组内先进先出.
+// 某个分组积压大量对象时, 其他分组的对象最多等待一轮即可被处理. 去重逻辑与Type一致.
+func NewFairShareQueue(keyFunc ShareKeyFunc) *Type {
+	return NewNamedFairShareQueue("", keyFunc)
+}
+
+func NewNamedFairShareQueue(name string, keyFunc ShareKeyFunc) *Type {
+	rc := clock.RealClock{}
+	return newQueueWithOrder(
+		rc,
+		newFairShareOrder(keyFunc),
+		globalMetricsFactory.newQueueMetrics(name, rc),
+		defaultUnfinishedWorkUpdatePeriod,
+	)
+}
+
+// fairShareOrder 按分组轮询出队的itemQueue.
+type fairShareOrder struct {
+	keyFunc ShareKeyFunc
+
+	// 各分组排队的对象
+	queues map[string]*fifoQueue
+	// 有对象排队的分组, 按加入顺序轮询
+	ring []string
+	// 下一个出队的分组在ring中的位置
+	next  int
+	count int
+}
+
+func newFairShareOrder(keyFunc ShareKeyFunc) *fairShareOrder {
+	return &fairShareOrder{
+		keyFunc: keyFunc,
+		queues:  map[string]*fifoQueue{},
+	}
+}
+
+func (o *fairShareOrder) push(item t) {
+	key := o.keyFunc(item)
+	q, ok := o.queues[key]
+	if !ok {
+		q = &fifoQueue{}
+		o.queues[key] = q
+		o.ring = append(o.ring, key)
+	}
+	q.push(item)
+	o.count++
+}
+
+func (o *fairShareOrder) pop() t {
+	key := o.ring[o.next]
+	q := o.queues[key]
+	item := q.pop()
+	o.count--
+
+	if q.len() == 0 {
+		// 分组已没有对象, 从轮询中移除, next即指向下一个分组
+		delete(o.queues, key)
+		o.ring = append(o.ring[:o.next], o.ring[o.next+1:]...)
+	} else {
+		o.next++
+	}
+	if o.next >= len(o.ring) {
+		o.next = 0
+	}
+	return item
+}
+
+func (o *fairShareOrder) len() int {
+	return o.count
+}
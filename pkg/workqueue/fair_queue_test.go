@@ -0,0 +1,50 @@
+package workqueue_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wangweihong/gotoolbox/pkg/workqueue"
+)
+
+func tenantOf(item interface{}) string {
+	return strings.SplitN(item.(string), "/", 2)[0]
+}
+
+func TestFairShareQueue(t *testing.T) {
+	q := workqueue.NewFairShareQueue(tenantOf)
+	defer q.ShutDown()
+
+	for _, item := range []string{"noisy/1", "noisy/2", "noisy/3", "a/1", "a/2", "b/1"} {
+		q.Add(item)
+	}
+	q.Add("noisy/1")
+	if e, a := 6, q.Len(); e != a {
+		t.Errorf("Expected %v, got %v", e, a)
+	}
+
+	assertOrder(t, drain(t, q, 3), "noisy/1", "a/1", "b/1")
+	// 新的分组加入到轮询末尾
+	q.Add("c/1")
+	assertOrder(t, drain(t, q, 4), "noisy/2", "a/2", "c/1", "noisy/3")
+	if a := q.Len(); a != 0 {
+		t.Errorf("Expected queue to be empty. Has %v items", a)
+	}
+}
+
+func TestFairShareQueueReinsert(t *testing.T) {
+	q := workqueue.NewFairShareQueue(tenantOf)
+	defer q.ShutDown()
+
+	q.Add("a/1")
+	item, _ := q.Get()
+	// 正在处理的对象再次加入, Done后重新排队
+	q.Add(item)
+	q.Add("b/1")
+	if e, a := 1, q.Len(); e != a {
+		t.Errorf("Expected %v, got %v", e, a)
+	}
+	q.Done(item)
+
+	assertOrder(t, drain(t, q, 2), "b/1", "a/1")
+}
@@ -0,0 +1,215 @@
+package workqueue
+
+import (
+	"container/heap"
+	"time"
+
+	"github.com/wangweihong/gotoolbox/pkg/clock"
+)
+
+// PriorityFunc 计算对象的优先级, 值越大越先处理.
+type PriorityFunc func(item interface{}) int
+
+// PriorityQueueOption 优先级队列选项.
+type PriorityQueueOption func(*priorityOrder)
+
+// WithPriorityFunc 通过Add加入的对象(包括延迟队列、限速队列重新加入的对象)使用f计算优先级. 默认优先级为0.
+func WithPriorityFunc(f PriorityFunc) PriorityQueueOption {
+	return func(o *priorityOrder) {
+		o.priorityFunc = f
+	}
+}
+
+// WithPriorityRemember 记住通过AddWithPriority指定的优先级, 对象之后通过Add(如延迟队列、限速队列重试)加入时沿用,
+// 直到调用Forget. 不再处理的对象需要Forget, 否则记录不会释放. 默认对象Done后不再记住.
+func WithPriorityRemember() PriorityQueueOption {
+	return func(o *priorityOrder) {
+		o.remembered = map[t]int{}
+	}
+}
+
+// DefaultPriorityAging 默认每等待10秒优先级视为提升1.
+const DefaultPriorityAging = 10 * time.Second
+
+// WithPriorityAging 对象每等待interval, 优先级视为提升1, 避免低优先级的对象一直得不到处理.
+// 默认为DefaultPriorityAging, interval<=0时不提升.
+func WithPriorityAging(interval time.Duration) PriorityQueueOption {
+	return func(o *priorityOrder) {
+		o.heap.aging = interval
+	}
+}
+
+// PriorityQueue 按优先级出队的工作队列, 优先级相同时先进先出. 去重逻辑与Type一致:
+// 排队中的对象再次加入时只会提升优先级, 正在处理的对象再次加入时, 在Done后以记录的最高优先级重新排队.
+// 指定WithPriorityRemember时, 通过AddWithPriority指定的优先级会被记住, 对象之后通过Add(如延迟队列、限速队列重试)
+// 加入时沿用该优先级, 直到调用Forget. 限速队列的Forget会同时调用PriorityQueue.Forget.
+type PriorityQueue struct {
+	*Type
+
+	order *priorityOrder
+}
+
+// NewPriorityQueue 创建优先级队列.
+func NewPriorityQueue(opts ...PriorityQueueOption) *PriorityQueue {
+	return NewNamedPriorityQueue("", opts...)
+}
+
+func NewNamedPriorityQueue(name string, opts ...PriorityQueueOption) *PriorityQueue {
+	return NewPriorityQueueWithClock(clock.RealClock{}, name, opts...)
+}
+
+// NewPriorityQueueWithClock 创建优先级队列, 可注入时钟用于测试.
+func NewPriorityQueueWithClock(c clock.Clock, name string, opts ...PriorityQueueOption) *PriorityQueue {
+	order := &priorityOrder{
+		clock:   c,
+		items:   map[t]*priorityItem{},
+		pending: map[t]int{},
+		heap:    priorityItems{aging: DefaultPriorityAging},
+	}
+	for _, opt := range opts {
+		opt(order)
+	}
+	return &PriorityQueue{
+		Type:  newQueueWithOrder(c, order, globalMetricsFactory.newQueueMetrics(name, c), defaultUnfinishedWorkUpdatePeriod),
+		order: order,
+	}
+}
+
+// AddWithPriority 以指定优先级加入对象. 对象已在排队时, 仅当priority更高时提升其优先级.
+func (q *PriorityQueue) AddWithPriority(item interface{}, priority int) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if q.shuttingDown {
+		return
+	}
+	q.order.raise(item, priority)
+	q.add(item)
+}
+
+// Forget 不再记住对象通过AddWithPriority指定的优先级, 之后通过Add加入时使用WithPriorityFunc计算的优先级.
+func (q *PriorityQueue) Forget(item interface{}) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	delete(q.order.remembered, item)
+}
+
+// priorityItem 堆中的对象.
+type priorityItem struct {
+	data     t
+	priority int
+	// 入队时间及序号, 用于计算等待时间及保证相同优先级先进先出
+	enqueuedAt time.Time
+	seq        uint64
+	index      int
+}
+
+// priorityOrder 以最大堆实现的itemQueue.
+type priorityOrder struct {
+	clock        clock.PassiveClock
+	priorityFunc PriorityFunc
+
+	heap priorityItems
+	// 堆中的对象
+	items map[t]*priorityItem
+	// 通过AddWithPriority指定但还未入堆的对象优先级(对象正在处理中)
+	pending map[t]int
+	// 通过AddWithPriority最后一次指定的对象优先级, 对象重新加入时沿用, 直到Forget. 未指定WithPriorityRemember时为nil
+	remembered map[t]int
+	seq        uint64
+}
+
+// raise 记录对象的优先级, 对象已在堆中则提升其优先级.
+func (o *priorityOrder) raise(item t, priority int) {
+	if o.remembered != nil {
+		o.remembered[item] = priority
+	}
+	if it, ok := o.items[item]; ok {
+		if priority > it.priority {
+			it.priority = priority
+			heap.Fix(&o.heap, it.index)
+		}
+		return
+	}
+	if p, ok := o.pending[item]; !ok || priority > p {
+		o.pending[item] = priority
+	}
+}
+
+func (o *priorityOrder) push(item t) {
+	priority, ok := o.pending[item]
+	if ok {
+		delete(o.pending, item)
+	} else if p, ok := o.remembered[item]; ok {
+		priority = p
+	} else if o.priorityFunc != nil {
+		priority = o.priorityFunc(item)
+	}
+	o.seq++
+	it := &priorityItem{
+		data:       item,
+		priority:   priority,
+		enqueuedAt: o.clock.Now(),
+		seq:        o.seq,
+	}
+	heap.Push(&o.heap, it)
+	o.items[item] = it
+}
+
+func (o *priorityOrder) pop() t {
+	it := heap.Pop(&o.heap).(*priorityItem)
+	delete(o.items, it.data)
+	return it.data
+}
+
+func (o *priorityOrder) len() int {
+	return o.heap.Len()
+}
+
+// priorityItems 实现heap.Interface, 堆顶为下一个要处理的对象.
+//
+// 有效优先级为 priority + 等待时间/aging, 所有对象等待时间增长速度相同, 因此比较
+// enqueuedAt - priority*aging 即可确定顺序, 无需随时间调整堆.
+type priorityItems struct {
+	items []*priorityItem
+	aging time.Duration
+}
+
+func (h priorityItems) Len() int {
+	return len(h.items)
+}
+
+func (h priorityItems) Less(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	if h.aging > 0 {
+		da := a.enqueuedAt.Add(-time.Duration(a.priority) * h.aging)
+		db := b.enqueuedAt.Add(-time.Duration(b.priority) * h.aging)
+		if !da.Equal(db) {
+			return da.Before(db)
+		}
+	}
+	if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	return a.seq < b.seq
+}
+
+func (h priorityItems) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.items[i].index = i
+	h.items[j].index = j
+}
+
+func (h *priorityItems) Push(x interface{}) {
+	item := x.(*priorityItem)
+	item.index = len(h.items)
+	h.items = append(h.items, item)
+}
+
+func (h *priorityItems) Pop() interface{} {
+	n := len(h.items)
+	item := h.items[n-1]
+	item.index = -1
+	h.items[n-1] = nil
+	h.items = h.items[:n-1]
+	return item
+}
@@ -0,0 +1,173 @@
+package workqueue_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wangweihong/gotoolbox/pkg/clock"
+	"github.com/wangweihong/gotoolbox/pkg/workqueue"
+)
+
+func drain(t *testing.T, q workqueue.Interface, n int) []interface{} {
+	t.Helper()
+	items := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		item, shutdown := q.Get()
+		if shutdown {
+			t.Fatalf("unexpected shutdown after %d items", i)
+		}
+		items = append(items, item)
+		q.Done(item)
+	}
+	return items
+}
+
+func assertOrder(t *testing.T, got []interface{}, expected ...interface{}) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, got)
+		}
+	}
+}
+
+func TestPriorityQueue(t *testing.T) {
+	q := workqueue.NewPriorityQueue()
+	defer q.ShutDown()
+
+	q.Add("periodic-1")
+	q.Add("periodic-2")
+	q.AddWithPriority("user-1", 10)
+	q.AddWithPriority("user-2", 10)
+	q.AddWithPriority("urgent", 20)
+	// 已在排队的对象不会重复加入, 只提升优先级
+	q.AddWithPriority("periodic-2", 5)
+	q.Add("user-1")
+	if e, a := 5, q.Len(); e != a {
+		t.Errorf("Expected %v, got %v", e, a)
+	}
+
+	assertOrder(t, drain(t, q, 5), "urgent", "user-1", "user-2", "periodic-2", "periodic-1")
+}
+
+func TestPriorityQueueAddWhileProcessing(t *testing.T) {
+	q := workqueue.NewPriorityQueue()
+	defer q.ShutDown()
+
+	q.Add("foo")
+	item, _ := q.Get()
+	q.AddWithPriority("bar", 1)
+	// 正在处理的对象在Done后以记录的优先级重新排队
+	q.AddWithPriority("foo", 5)
+	if e, a := 1, q.Len(); e != a {
+		t.Errorf("Expected %v, got %v", e, a)
+	}
+	q.Done(item)
+
+	assertOrder(t, drain(t, q, 2), "foo", "bar")
+}
+
+func TestPriorityQueuePriorityFunc(t *testing.T) {
+	q := workqueue.NewPriorityQueue(workqueue.WithPriorityFunc(func(item interface{}) int {
+		return len(item.(string))
+	}))
+	defer q.ShutDown()
+
+	q.Add("a")
+	q.Add("ccc")
+	q.Add("bb")
+
+	assertOrder(t, drain(t, q, 3), "ccc", "bb", "a")
+}
+
+func TestPriorityQueueAging(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	q := workqueue.NewPriorityQueueWithClock(fakeClock, "", workqueue.WithPriorityAging(time.Second))
+	defer q.ShutDown()
+
+	q.Add("old")
+	fakeClock.Step(3 * time.Second)
+	q.AddWithPriority("high", 2)
+	q.AddWithPriority("higher", 4)
+
+	// old等待3秒, 有效优先级为3, 高于high
+	assertOrder(t, drain(t, q, 3), "higher", "old", "high")
+}
+
+func TestRateLimitingPriorityQueue(t *testing.T) {
+	pq := workqueue.NewPriorityQueue(workqueue.WithPriorityFunc(func(item interface{}) int {
+		if item == "user" {
+			return 1
+		}
+		return 0
+	}))
+	q := workqueue.NewRateLimitingQueueWithCustomQueue(pq, workqueue.NewItemExponentialFailureRateLimiter(time.Millisecond, time.Millisecond), "")
+	defer q.ShutDown()
+
+	q.Add("periodic")
+	q.AddRateLimited("user")
+	deadline := time.Now().Add(time.Second)
+	for q.Len() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("rate limited item was not added")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	assertOrder(t, drain(t, q, 2), "user", "periodic")
+}
+
+func TestPriorityQueueNotRemembered(t *testing.T) {
+	q := workqueue.NewPriorityQueue()
+	defer q.ShutDown()
+
+	q.AddWithPriority("user", 10)
+	item, _ := q.Get()
+	q.Done(item)
+	if e, a := 0, q.RememberedLen(); e != a {
+		t.Errorf("Expected %v, got %v", e, a)
+	}
+
+	// 未指定WithPriorityRemember时, 重新加入不沿用之前的优先级
+	q.Add("periodic")
+	q.Add("user")
+	assertOrder(t, drain(t, q, 2), "periodic", "user")
+}
+
+func TestRateLimitingPriorityQueueRetry(t *testing.T) {
+	pq := workqueue.NewPriorityQueue(workqueue.WithPriorityRemember())
+	q := workqueue.NewRateLimitingQueueWithCustomQueue(pq, workqueue.NewItemExponentialFailureRateLimiter(time.Millisecond, time.Millisecond), "")
+	defer q.ShutDown()
+
+	waitLen := func(n int) {
+		deadline := time.Now().Add(time.Second)
+		for q.Len() != n {
+			if time.Now().After(deadline) {
+				t.Fatalf("rate limited item was not added")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	pq.AddWithPriority("user", 10)
+	item, _ := q.Get()
+	// 重试时沿用AddWithPriority指定的优先级
+	q.AddRateLimited(item)
+	q.Done(item)
+	q.Add("periodic")
+	waitLen(2)
+	assertOrder(t, drain(t, q, 2), "user", "periodic")
+
+	// Forget后不再沿用
+	q.Forget("user")
+	if e, a := 0, pq.RememberedLen(); e != a {
+		t.Errorf("Expected %v, got %v", e, a)
+	}
+	q.Add("periodic")
+	q.AddRateLimited("user")
+	waitLen(2)
+	assertOrder(t, drain(t, q, 2), "periodic", "user")
+}
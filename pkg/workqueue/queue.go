@@ -37,8 +37,14 @@ func NewNamed(name string) *Type {
 }
 
 func newQueue(c clock.Clock, metrics queueMetrics, updatePeriod time.Duration) *Type {
+	return newQueueWithOrder(c, &fifoQueue{}, metrics, updatePeriod)
+}
+
+// newQueueWithOrder 创建以queue决定出队顺序的工作队列, 去重逻辑与Type一致.
+func newQueueWithOrder(c clock.Clock, queue itemQueue, metrics queueMetrics, updatePeriod time.Duration) *Type {
 	t := &Type{
 		clock:                      c,
+		queue:                      queue,
 		dirty:                      set{},
 		processing:                 set{},
 		cond:                       sync.NewCond(&sync.Mutex{}),
@@ -56,8 +62,8 @@ type Type struct {
 	// queue defines the order in which we will work on items. Every
 	// element of queue should be in the dirty set and not in the
 	// processing set.
-	// 用来实现顺序存储元素的
-	queue []t
+	// 用来实现顺序存储元素的, 默认先进先出
+	queue itemQueue
 
 	// dirty defines all of the items that need to be processed.
 	// 用来存放需要加入到queue中处理的对象。如果该对象正在处理(processing表中),则不会立即加入到queue中排队，直到处理结束后才加入到队列
@@ -106,11 +112,44 @@ func (s set) delete(item t) {
 	delete(s, item)
 }
 
+// itemQueue 决定等待处理的对象的出队顺序. 调用者持有Type的锁.
+type itemQueue interface {
+	// 对象排队
+	push(item t)
+	// 取出下一个要处理的对象, 仅在len() > 0时调用
+	pop() t
+	len() int
+}
+
+// fifoQueue 先进先出队列.
+type fifoQueue []t
+
+func (q *fifoQueue) push(item t) {
+	*q = append(*q, item)
+}
+
+func (q *fifoQueue) pop() t {
+	item := (*q)[0]
+	// 避免底层数组持有已出队的对象
+	(*q)[0] = nil
+	*q = (*q)[1:]
+	return item
+}
+
+func (q *fifoQueue) len() int {
+	return len(*q)
+}
+
 // Add marks item as needing processing.
 // 写入一个数据，唤醒一个等待的工作进程.
 func (q *Type) Add(item interface{}) {
 	q.cond.L.Lock()
 	defer q.cond.L.Unlock()
+	q.add(item)
+}
+
+// add 调用者持有锁.
+func (q *Type) add(item interface{}) {
 	// 如果队列正在关闭
 	if q.shuttingDown {
 		return
@@ -130,7 +169,7 @@ func (q *Type) Add(item interface{}) {
 		return
 	}
 	// 加入工作队列排队等待处理
-	q.queue = append(q.queue, item)
+	q.queue.push(item)
 	// 唤醒一个工作线程
 	q.cond.Signal()
 }
@@ -142,7 +181,7 @@ func (q *Type) Add(item interface{}) {
 func (q *Type) Len() int {
 	q.cond.L.Lock()
 	defer q.cond.L.Unlock()
-	return len(q.queue)
+	return q.queue.len()
 }
 
 // Get blocks until it can return an item to be processed. If shutdown = true,
@@ -153,16 +192,16 @@ func (q *Type) Get() (item interface{}, shutdown bool) {
 	q.cond.L.Lock()
 	defer q.cond.L.Unlock()
 	// 如果当前没有数据，而且队列没有关闭，则工作进程等待
-	for len(q.queue) == 0 && !q.shuttingDown {
+	for q.queue.len() == 0 && !q.shuttingDown {
 		q.cond.Wait()
 	}
-	if len(q.queue) == 0 {
+	if q.queue.len() == 0 {
 		// We must be shutting down.
 		return nil, true
 	}
 
 	// 从头部获取元素
-	item, q.queue = q.queue[0], q.queue[1:]
+	item = q.queue.pop()
 
 	q.metrics.get(item)
 	// 从 dirty set 里去除, 加到 processing 集合里.
@@ -185,7 +224,7 @@ func (q *Type) Done(item interface{}) {
 	q.processing.delete(item)
 	// 如果该对象又被更新了（需要再次处理），再次将对象新数据进行排队，并唤醒一个工作线程开始处理
 	if q.dirty.has(item) {
-		q.queue = append(q.queue, item)
+		q.queue.push(item)
 		q.cond.Signal()
 	}
 }
@@ -1,5 +1,7 @@
 package workqueue
 
+import "github.com/wangweihong/gotoolbox/pkg/errors"
+
 // RateLimitingInterface is an interface that rate limits items being added to the queue.
 type RateLimitingInterface interface {
 	DelayingInterface
@@ -32,11 +34,30 @@ func NewNamedRateLimitingQueue(rateLimiter RateLimiter, name string) RateLimitin
 	}
 }
 
+// NewRateLimitingQueueWithCustomQueue 在已有的工作队列(如优先级队列、公平队列)上提供延迟加入及限速能力.
+// q实现Forget时(如PriorityQueue), Forget会同时调用q.Forget.
+func NewRateLimitingQueueWithCustomQueue(q Interface, rateLimiter RateLimiter, name string) RateLimitingInterface {
+	ret := &rateLimitingType{
+		DelayingInterface: NewDelayingQueueWithCustomQueue(q, name),
+		rateLimiter:       rateLimiter,
+	}
+	if f, ok := q.(forgetter); ok {
+		ret.queue = f
+	}
+	return ret
+}
+
+// forgetter 在Forget时需要清理对象状态的工作队列, 如PriorityQueue.
+type forgetter interface {
+	Forget(item interface{})
+}
+
 // rateLimitingType wraps an Interface and provides rateLimited re-enquing.
 type rateLimitingType struct {
 	DelayingInterface
 
 	rateLimiter RateLimiter // 限速器，用来计算对象等待插入到主工作队列的时间
+	queue       forgetter   // 主工作队列实现Forget时不为空
 }
 
 // AddRateLimited AddAfter's the item based on the time when the rate limiter says it's ok
@@ -55,4 +76,24 @@ func (q *rateLimitingType) NumRequeues(item interface{}) int {
 // 从限速器中移除。下次插入则重新计算等待延时.
 func (q *rateLimitingType) Forget(item interface{}) {
 	q.rateLimiter.Forget(item)
+	if q.queue != nil {
+		q.queue.Forget(item)
+	}
+}
+
+// RequeueOnError 根据处理结果决定是否限速重新入队, 返回是否重新入队:
+// err为nil或不可重试(见errors.IsRetryable)时不再跟踪对象的失败次数;
+// maxRequeues大于0时, 重新入队次数达到上限后不再重新入队.
+// 调用者仍需调用Done.
+func RequeueOnError(q RateLimitingInterface, item interface{}, err error, maxRequeues int) bool {
+	if err == nil || !errors.IsRetryable(err) {
+		q.Forget(item)
+		return false
+	}
+	if maxRequeues > 0 && q.NumRequeues(item) >= maxRequeues {
+		q.Forget(item)
+		return false
+	}
+	q.AddRateLimited(item)
+	return true
 }
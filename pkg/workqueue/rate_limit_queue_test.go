@@ -0,0 +1,46 @@
+package workqueue_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wangweihong/gotoolbox/pkg/errors"
+	"github.com/wangweihong/gotoolbox/pkg/workqueue"
+)
+
+func TestRequeueOnError(t *testing.T) {
+	q := workqueue.NewRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(time.Millisecond, 10*time.Millisecond))
+	defer q.ShutDown()
+
+	retryable := errors.Retryable(errors.New("service unavailable"))
+	for i := 0; i < 2; i++ {
+		if !workqueue.RequeueOnError(q, "a", retryable, 2) {
+			t.Fatalf("expected item requeued at %d", i)
+		}
+	}
+	if q.NumRequeues("a") != 2 {
+		t.Errorf("expected 2 requeues, got %d", q.NumRequeues("a"))
+	}
+	// 达到重新入队上限
+	if workqueue.RequeueOnError(q, "a", retryable, 2) {
+		t.Errorf("expected item not requeued after max requeues")
+	}
+	if q.NumRequeues("a") != 0 {
+		t.Errorf("expected item forgotten, got %d requeues", q.NumRequeues("a"))
+	}
+
+	// 不可重试的错误
+	workqueue.RequeueOnError(q, "b", retryable, 0)
+	if workqueue.RequeueOnError(q, "b", errors.New("invalid spec"), 0) {
+		t.Errorf("expected non-retryable error not requeued")
+	}
+	if q.NumRequeues("b") != 0 {
+		t.Errorf("expected item forgotten, got %d requeues", q.NumRequeues("b"))
+	}
+
+	item, _ := q.Get()
+	if item != "a" && item != "b" {
+		t.Errorf("unexpected item %v", item)
+	}
+	q.Done(item)
+}
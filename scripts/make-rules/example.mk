@@ -14,4 +14,12 @@ code-gen-example: tools.verify.codegen
 	@codegen -type=int ${ROOT_DIR}/tools/codegen/example
 	@echo "===========> Generating error code markdown documentation to path:${ROOT_DIR}/tools/codegen/example/error_code_generated.md"
 	@codegen -type=int -doc \
-		-output ${ROOT_DIR}/tools/codegen/example/error_code_generated.md ${ROOT_DIR}/tools/codegen/example
\ No newline at end of file
+		-output ${ROOT_DIR}/tools/codegen/example/error_code_generated.md ${ROOT_DIR}/tools/codegen/example
+	@echo "===========> Checking error code collisions and gaps in path:${ROOT_DIR}/tools/codegen/example ${ROOT_DIR}/pkg"
+	@codegen -check -type=int ${ROOT_DIR}/tools/codegen/example ${ROOT_DIR}/pkg
+
+## openapi-gen-example: Run an example show how openapi-gen generate httpcli client from OpenAPI 3 specification
+.PHONY: openapi-gen-example
+openapi-gen-example: tools.verify.openapi-gen
+	@echo "===========> Generating httpcli client to path:${ROOT_DIR}/tools/openapi-gen/example"
+	@cd ${ROOT_DIR}/tools/openapi-gen/example && openapi-gen -spec petstore.yaml -package example -code-base 120001
@@ -104,6 +104,10 @@ install.kube-score:
 install.deepcopy-gen:
 	@$(GO) install ${ROOT_DIR}/tools/deepcopy-gen/deepcopy-gen.go
 
+.PHONY: install.openapi-gen
+install.openapi-gen:
+	@$(GO) install ${ROOT_DIR}/tools/openapi-gen/openapi-gen.go
+
 # for git hook commit-msg
 .PHONY: install.go-gitlint
 install.go-gitlint:
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"io"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// errorsPkgPath 错误码注册所在的包.
+const errorsPkgPath = "github.com/wangweihong/gotoolbox/pkg/errors"
+
+// Definition 扫描到的错误码定义.
+type Definition struct {
+	Code    int64
+	Name    string // 常量名, 字面量时为空
+	Package string
+	Pos     string
+
+	obj types.Object
+}
+
+func (d Definition) String() string {
+	name := d.Name
+	if name == "" {
+		name = "<literal>"
+	}
+	return fmt.Sprintf("%s.%s (%s)", d.Package, name, d.Pos)
+}
+
+// Checker 扫描多个模块中的错误码定义, 检查错误码冲突及错误码段中的空缺.
+//
+// 错误码来源:
+//   - -type指定类型的常量, 与代码生成模式一致;
+//   - errors.NewCoder调用的第一个参数, 需为常量.
+type Checker struct {
+	typeNames []string
+	tags      []string
+	// 错误码段大小, 如100表示110201~110299属于同一错误码段
+	rangeSize int64
+
+	defs []Definition
+	seen map[string]bool
+}
+
+func NewChecker(typeNames []string, tags []string, rangeSize int64) *Checker {
+	return &Checker{
+		typeNames: typeNames,
+		tags:      tags,
+		rangeSize: rangeSize,
+		seen:      make(map[string]bool),
+	}
+}
+
+// Load 加载目录或包模式. 目录按模块内的./...加载, 因此可以指定多个不同模块的根目录.
+func (c *Checker) Load(args []string) {
+	for _, arg := range args {
+		cfg := &packages.Config{
+			//nolint: staticcheck
+			Mode:       packages.LoadSyntax,
+			Tests:      false,
+			BuildFlags: []string{fmt.Sprintf("-tags=%s", strings.Join(c.tags, " "))},
+		}
+		pattern := arg
+		if isDirectory(arg) {
+			cfg.Dir = arg
+			pattern = "./..."
+		}
+		pkgs, err := packages.Load(cfg, pattern)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(pkgs) == 0 {
+			log.Fatalf("error: no packages found in %s", arg)
+		}
+		for _, pkg := range pkgs {
+			for _, e := range pkg.Errors {
+				log.Printf("warning: %s", e)
+			}
+			if pkg.TypesInfo == nil {
+				continue
+			}
+			c.addPackage(pkg)
+		}
+	}
+}
+
+func (c *Checker) addPackage(pkg *packages.Package) {
+	p := &Package{
+		name: pkg.Name,
+		defs: pkg.TypesInfo.Defs,
+	}
+	for _, file := range pkg.Syntax {
+		f := &File{file: file, pkg: p}
+		for _, typeName := range c.typeNames {
+			f.typeName = typeName
+			f.values = nil
+			ast.Inspect(file, f.genDecl)
+			for _, v := range f.values {
+				c.add(pkg, Definition{
+					Code: int64(v.value),
+					Name: v.originalName,
+					obj:  v.obj,
+				}, v.obj.Pos())
+			}
+		}
+
+		ast.Inspect(file, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok || len(call.Args) == 0 || !isNewCoder(pkg.TypesInfo, call.Fun) {
+				return true
+			}
+			tv, ok := pkg.TypesInfo.Types[call.Args[0]]
+			if !ok || tv.Value == nil || tv.Value.Kind() != constant.Int {
+				log.Printf("warning: %s: error code is not a constant", pkg.Fset.Position(call.Pos()))
+				return true
+			}
+			code, _ := constant.Int64Val(tv.Value)
+			d := Definition{Code: code}
+			if id := constIdent(call.Args[0]); id != nil {
+				d.Name = id.Name
+				d.obj = pkg.TypesInfo.Uses[id]
+			}
+			pos := call.Pos()
+			if d.obj != nil {
+				pos = d.obj.Pos()
+			}
+			c.add(pkg, d, pos)
+			return true
+		})
+	}
+}
+
+// add 记录错误码定义, 同一常量只记录一次.
+func (c *Checker) add(pkg *packages.Package, d Definition, pos token.Pos) {
+	position := pkg.Fset.Position(pos)
+	key := position.String()
+	if c.seen[key] {
+		return
+	}
+	c.seen[key] = true
+
+	d.Package = pkg.PkgPath
+	if d.obj != nil && d.obj.Pkg() != nil {
+		d.Package = d.obj.Pkg().Path()
+	}
+	d.Pos = fmt.Sprintf("%s:%d", filepath.Base(position.Filename), position.Line)
+	c.defs = append(c.defs, d)
+}
+
+// isNewCoder 判断是否为errors.NewCoder调用.
+func isNewCoder(info *types.Info, fun ast.Expr) bool {
+	var id *ast.Ident
+	switch f := fun.(type) {
+	case *ast.SelectorExpr:
+		id = f.Sel
+	case *ast.Ident:
+		id = f
+	default:
+		return false
+	}
+	fn, ok := info.Uses[id].(*types.Func)
+	return ok && fn.Pkg() != nil && fn.Pkg().Path() == errorsPkgPath && fn.Name() == "NewCoder"
+}
+
+// constIdent 返回引用常量的标识符, 如ErrX或pkg.ErrX.
+func constIdent(expr ast.Expr) *ast.Ident {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e
+	case *ast.SelectorExpr:
+		return e.Sel
+	case *ast.ParenExpr:
+		return constIdent(e.X)
+	}
+	return nil
+}
+
+// Collisions 返回被多个常量/字面量定义的错误码.
+func (c *Checker) Collisions() map[int64][]Definition {
+	byCode := make(map[int64][]Definition)
+	for _, d := range c.defs {
+		byCode[d.Code] = append(byCode[d.Code], d)
+	}
+	for code, defs := range byCode {
+		if len(defs) < 2 {
+			delete(byCode, code)
+		}
+	}
+	return byCode
+}
+
+// block 返回错误码所在的错误码段, 负数错误码按向下取整计算, 不会与0所在的段合并.
+func (c *Checker) block(code int64) int64 {
+	block := code / c.rangeSize
+	if code%c.rangeSize < 0 {
+		block--
+	}
+	return block
+}
+
+// Overlaps 返回被多个包使用的错误码段及使用该段的包.
+func (c *Checker) Overlaps() map[int64][]string {
+	pkgs := make(map[int64]map[string]bool)
+	for _, d := range c.defs {
+		block := c.block(d.Code)
+		if pkgs[block] == nil {
+			pkgs[block] = make(map[string]bool)
+		}
+		pkgs[block][d.Package] = true
+	}
+
+	overlaps := make(map[int64][]string)
+	for block, names := range pkgs {
+		if len(names) < 2 {
+			continue
+		}
+		for name := range names {
+			overlaps[block] = append(overlaps[block], name)
+		}
+		sort.Strings(overlaps[block])
+	}
+	return overlaps
+}
+
+// Gaps 返回每个错误码段中最小与最大错误码之间未使用的错误码.
+func (c *Checker) Gaps() map[int64][]int64 {
+	used := make(map[int64]map[int64]bool)
+	for _, d := range c.defs {
+		block := c.block(d.Code)
+		if used[block] == nil {
+			used[block] = make(map[int64]bool)
+		}
+		used[block][d.Code] = true
+	}
+
+	gaps := make(map[int64][]int64)
+	for block, codes := range used {
+		first := true
+		var minCode, maxCode int64
+		for code := range codes {
+			if first || code < minCode {
+				minCode = code
+			}
+			if first || code > maxCode {
+				maxCode = code
+			}
+			first = false
+		}
+		for code := minCode + 1; code < maxCode; code++ {
+			if !codes[code] {
+				gaps[block] = append(gaps[block], code)
+			}
+		}
+	}
+	return gaps
+}
+
+// Report 输出检查结果, 存在冲突时返回false. 多个包共用同一错误码段只作为提示输出.
+func (c *Checker) Report(w io.Writer) bool {
+	sort.Slice(c.defs, func(i, j int) bool {
+		if c.defs[i].Code != c.defs[j].Code {
+			return c.defs[i].Code < c.defs[j].Code
+		}
+		return c.defs[i].String() < c.defs[j].String()
+	})
+	fmt.Fprintf(w, "scanned %d error code definitions\n", len(c.defs))
+
+	collisions := c.Collisions()
+	fmt.Fprintf(w, "\ncollisions: %d\n", len(collisions))
+	for _, code := range sortedKeys(collisions) {
+		fmt.Fprintf(w, "  %d:\n", code)
+		for _, d := range collisions[code] {
+			fmt.Fprintf(w, "    %s\n", d)
+		}
+	}
+
+	overlaps := c.Overlaps()
+	fmt.Fprintf(w, "\noverlapping ranges: %d\n", len(overlaps))
+	for _, block := range sortedKeys(overlaps) {
+		fmt.Fprintf(w, "  %d-%d: %s\n", block*c.rangeSize, (block+1)*c.rangeSize-1, strings.Join(overlaps[block], ", "))
+	}
+
+	gaps := c.Gaps()
+	fmt.Fprintf(w, "\ngaps: %d ranges\n", len(gaps))
+	for _, block := range sortedKeys(gaps) {
+		fmt.Fprintf(w, "  %d-%d: %s\n", block*c.rangeSize, (block+1)*c.rangeSize-1, formatRanges(gaps[block]))
+	}
+	return len(collisions) == 0
+}
+
+// formatRanges 将连续的错误码合并显示, 如110203-110205,110208.
+func formatRanges(codes []int64) string {
+	parts := make([]string, 0, len(codes))
+	for i := 0; i < len(codes); {
+		j := i
+		for j+1 < len(codes) && codes[j+1] == codes[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, fmt.Sprintf("%d", codes[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", codes[i], codes[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+func sortedKeys[V any](m map[int64]V) []int64 {
+	keys := make([]int64, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
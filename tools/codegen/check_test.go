@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestChecker(rangeSize int64, defs ...Definition) *Checker {
+	c := NewChecker(nil, nil, rangeSize)
+	c.defs = defs
+	return c
+}
+
+func def(pkg, name string, code int64) Definition {
+	return Definition{Code: code, Name: name, Package: pkg, Pos: name + ".go:1"}
+}
+
+func TestChecker_Collisions(t *testing.T) {
+	tests := []struct {
+		name string
+		defs []Definition
+		want map[int64][]string
+	}{
+		{
+			name: "no duplicate",
+			defs: []Definition{def("a", "ErrA", 110201), def("b", "ErrB", 110202)},
+			want: map[int64][]string{},
+		},
+		{
+			name: "duplicate code in different modules",
+			defs: []Definition{def("a", "ErrA", 110201), def("b", "ErrB", 110201), def("b", "ErrC", 110202)},
+			want: map[int64][]string{110201: {"ErrA", "ErrB"}},
+		},
+		{
+			name: "duplicate code in the same package",
+			defs: []Definition{def("a", "ErrA", 110201), def("a", "", 110201), def("a", "ErrC", 110201)},
+			want: map[int64][]string{110201: {"ErrA", "", "ErrC"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[int64][]string)
+			for code, defs := range newTestChecker(100, tt.defs...).Collisions() {
+				for _, d := range defs {
+					got[code] = append(got[code], d.Name)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Collisions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChecker_Overlaps(t *testing.T) {
+	tests := []struct {
+		name      string
+		rangeSize int64
+		defs      []Definition
+		want      map[int64][]string
+	}{
+		{
+			name:      "separate ranges",
+			rangeSize: 100,
+			defs:      []Definition{def("a", "ErrA", 110201), def("b", "ErrB", 110301)},
+			want:      map[int64][]string{},
+		},
+		{
+			name:      "shared range",
+			rangeSize: 100,
+			defs:      []Definition{def("b", "ErrB", 110299), def("a", "ErrA", 110201), def("c", "ErrC", 110300)},
+			want:      map[int64][]string{1102: {"a", "b"}},
+		},
+		{
+			name:      "shared only with a larger range size",
+			rangeSize: 1000,
+			defs:      []Definition{def("a", "ErrA", 110201), def("b", "ErrB", 110301)},
+			want:      map[int64][]string{110: {"a", "b"}},
+		},
+		{
+			name:      "negative code is not in the range of zero",
+			rangeSize: 100,
+			defs:      []Definition{def("a", "ErrA", 1), def("b", "ErrB", -1)},
+			want:      map[int64][]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newTestChecker(tt.rangeSize, tt.defs...).Overlaps()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Overlaps() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChecker_Gaps(t *testing.T) {
+	tests := []struct {
+		name      string
+		rangeSize int64
+		codes     []int64
+		want      map[int64][]int64
+	}{
+		{
+			name:      "continuous",
+			rangeSize: 100,
+			codes:     []int64{110201, 110202, 110203},
+			want:      map[int64][]int64{},
+		},
+		{
+			name:      "gaps inside a range",
+			rangeSize: 100,
+			codes:     []int64{110201, 110204, 110206},
+			want:      map[int64][]int64{1102: {110202, 110203, 110205}},
+		},
+		{
+			name:      "codes out of the range are not gaps",
+			rangeSize: 100,
+			codes:     []int64{110298, 110301, 110303, 110500},
+			want:      map[int64][]int64{1103: {110302}},
+		},
+		{
+			name:      "negative codes",
+			rangeSize: 100,
+			codes:     []int64{-3, -1, 1},
+			want:      map[int64][]int64{-1: {-2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestChecker(tt.rangeSize)
+			for _, code := range tt.codes {
+				c.defs = append(c.defs, def("a", "", code))
+			}
+			got := c.Gaps()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Gaps() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChecker_Report(t *testing.T) {
+	tests := []struct {
+		name     string
+		defs     []Definition
+		wantOK   bool
+		contains []string
+	}{
+		{
+			name:     "no collision",
+			defs:     []Definition{def("a", "ErrA", 110201), def("a", "ErrB", 110203)},
+			wantOK:   true,
+			contains: []string{"scanned 2 error code definitions", "collisions: 0", "overlapping ranges: 0", "110200-110299: 110202"},
+		},
+		{
+			name:     "collision",
+			defs:     []Definition{def("a", "ErrA", 110201), def("b", "ErrB", 110201)},
+			wantOK:   false,
+			contains: []string{"collisions: 1", "a.ErrA (ErrA.go:1)", "b.ErrB (ErrB.go:1)", "110200-110299: a, b"},
+		},
+		{
+			name:     "shared range without collision",
+			defs:     []Definition{def("a", "ErrA", 110201), def("b", "ErrB", 110202)},
+			wantOK:   true,
+			contains: []string{"collisions: 0", "overlapping ranges: 1", "110200-110299: a, b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if ok := newTestChecker(100, tt.defs...).Report(&buf); ok != tt.wantOK {
+				t.Errorf("Report() = %v, want %v", ok, tt.wantOK)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(buf.String(), s) {
+					t.Errorf("report does not contain %q:\n%s", s, buf.String())
+				}
+			}
+		})
+	}
+}
@@ -61,6 +61,8 @@ var (
 	trimprefix = flag.String("trimprefix", "", "trim the `prefix` from the generated constant names")
 	buildTags  = flag.String("tags", "", "comma-separated list of build tags to apply")
 	doc        = flag.Bool("doc", false, "if true only generate error code documentation in markdown format")
+	check      = flag.Bool("check", false, "if true scan error codes in all packages of the given directories or patterns, report collisions, ranges shared by packages and gaps")
+	rangeSize  = flag.Int64("rangesize", 100, "size of an error code range when reporting gaps, used with -check")
 )
 
 // Usage is a replacement usage function for the flags package.
@@ -68,6 +70,7 @@ func Usage() {
 	fmt.Fprintf(os.Stderr, "Usage of codegen:\n")
 	fmt.Fprintf(os.Stderr, "\tcodegen [flags] -type T [directory]\n")
 	fmt.Fprintf(os.Stderr, "\tcodegen [flags] -type T files... # Must be a single package\n")
+	fmt.Fprintf(os.Stderr, "\tcodegen -check [-type T] [-rangesize N] [directories|patterns...] # Multiple modules\n")
 	fmt.Fprintf(os.Stderr, "Flags:\n")
 	flag.PrintDefaults()
 }
@@ -77,6 +80,10 @@ func main() {
 	log.SetPrefix("codegen: ")
 	flag.Usage = Usage
 	flag.Parse()
+	if *check {
+		runCheck()
+		return
+	}
 	if len(*typeNames) == 0 {
 		flag.Usage()
 		os.Exit(2)
@@ -152,6 +159,38 @@ func main() {
 	}
 }
 
+// runCheck 扫描多个模块的错误码, 存在冲突时以状态码1退出.
+func runCheck() {
+	if *rangeSize <= 0 {
+		log.Fatalf("invalid -rangesize %d", *rangeSize)
+	}
+	var types, tags []string
+	if len(*typeNames) > 0 {
+		types = strings.Split(*typeNames, ",")
+	}
+	if len(*buildTags) > 0 {
+		tags = strings.Split(*buildTags, ",")
+	}
+	args := flag.Args()
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+
+	c := NewChecker(types, tags, *rangeSize)
+	c.Load(args)
+
+	var buf bytes.Buffer
+	ok := c.Report(&buf)
+	if *output == "" {
+		_, _ = os.Stdout.Write(buf.Bytes())
+	} else if err := ioutil.WriteFile(*output, buf.Bytes(), 0o600); err != nil {
+		log.Fatalf("writing output: %s", err)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
 // isDirectory reports whether the named file is a directory.
 func isDirectory(name string) bool {
 	info, err := os.Stat(name)
@@ -351,6 +390,8 @@ type Value struct {
 	str    string // The string representation given by the "go/constant" package.
 
 	commentParseMap map[string]string // parse comment with specific header
+
+	obj types.Object // The declared constant object.
 }
 
 func (v *Value) String() string {
@@ -487,6 +528,7 @@ func (f *File) genDecl(node ast.Node) bool {
 				value:        u64,
 				signed:       info&types.IsUnsigned == 0,
 				str:          value.String(),
+				obj:          obj,
 			}
 			// 提取注释
 			if vspec.Doc != nil && vspec.Doc.Text() != "" {
@@ -0,0 +1,17 @@
+package example
+
+import (
+	"github.com/wangweihong/gotoolbox/pkg/errors"
+)
+
+//go:generate openapi-gen -spec petstore.yaml -package example -code-base 120001
+
+// ErrPetNotFound 通过x-error-code映射的已有错误码.
+const ErrPetNotFound int = 110404
+
+func init() {
+	errors.MustRegister(errors.NewCoder(ErrPetNotFound, 404, map[string]string{
+		errors.MessageLangCNKey: "宠物不存在",
+		errors.MessageLangENKey: "Pet not found.",
+	}))
+}
@@ -0,0 +1,338 @@
+// Code generated by "openapi-gen -spec petstore.yaml -package example -code-base 120001"; DO NOT EDIT.
+
+package example
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wangweihong/gotoolbox/pkg/errors"
+	"github.com/wangweihong/gotoolbox/pkg/httpcli"
+)
+
+// 非2xx响应映射的错误码.
+const (
+	// @HTTP 400
+	// @MessageCN 请求参数错误
+	// @MessageEN Bad request.
+	ErrPetstoreBadRequest int = iota + 120001
+	// @HTTP 401
+	// @MessageCN 认证失败
+	// @MessageEN Unauthorized.
+	ErrPetstoreUnauthorized
+	// @HTTP 403
+	// @MessageCN 没有权限
+	// @MessageEN Forbidden.
+	ErrPetstoreForbidden
+	// @HTTP 404
+	// @MessageCN 资源不存在
+	// @MessageEN Resource not found.
+	ErrPetstoreNotFound
+	// @HTTP 400
+	// @MessageCN 资源冲突
+	// @MessageEN Resource conflict.
+	ErrPetstoreConflict
+	// @HTTP 500
+	// @MessageCN 请求过于频繁
+	// @MessageEN Too many requests.
+	ErrPetstoreTooManyRequests
+	// @HTTP 500
+	// @MessageCN 服务端错误
+	// @MessageEN Server error.
+	ErrPetstoreServerError
+	// @HTTP 500
+	// @MessageCN 非预期的响应状态码
+	// @MessageEN Unexpected status code.
+	ErrPetstoreUnexpectedStatus
+)
+
+func init() {
+	errors.MustRegister(errors.NewCoder(ErrPetstoreBadRequest, 400, map[string]string{errors.MessageLangCNKey: "请求参数错误", errors.MessageLangENKey: "Bad request."}))
+	errors.MustRegister(errors.NewCoder(ErrPetstoreUnauthorized, 401, map[string]string{errors.MessageLangCNKey: "认证失败", errors.MessageLangENKey: "Unauthorized."}))
+	errors.MustRegister(errors.NewCoder(ErrPetstoreForbidden, 403, map[string]string{errors.MessageLangCNKey: "没有权限", errors.MessageLangENKey: "Forbidden."}))
+	errors.MustRegister(errors.NewCoder(ErrPetstoreNotFound, 404, map[string]string{errors.MessageLangCNKey: "资源不存在", errors.MessageLangENKey: "Resource not found."}))
+	errors.MustRegister(errors.NewCoder(ErrPetstoreConflict, 400, map[string]string{errors.MessageLangCNKey: "资源冲突", errors.MessageLangENKey: "Resource conflict."}))
+	errors.MustRegister(errors.NewCoder(ErrPetstoreTooManyRequests, 500, map[string]string{errors.MessageLangCNKey: "请求过于频繁", errors.MessageLangENKey: "Too many requests."}))
+	errors.MustRegister(errors.NewCoder(ErrPetstoreServerError, 500, map[string]string{errors.MessageLangCNKey: "服务端错误", errors.MessageLangENKey: "Server error."}))
+	errors.MustRegister(errors.NewCoder(ErrPetstoreUnexpectedStatus, 500, map[string]string{errors.MessageLangCNKey: "非预期的响应状态码", errors.MessageLangENKey: "Unexpected status code."}))
+}
+
+// statusErrorCode 按响应状态码返回默认错误码.
+func statusErrorCode(status int) int {
+	switch {
+	case status == 400:
+		return ErrPetstoreBadRequest
+	case status == 401:
+		return ErrPetstoreUnauthorized
+	case status == 403:
+		return ErrPetstoreForbidden
+	case status == 404:
+		return ErrPetstoreNotFound
+	case status == 409:
+		return ErrPetstoreConflict
+	case status == 429:
+		return ErrPetstoreTooManyRequests
+	case status >= 500:
+		return ErrPetstoreServerError
+	}
+	return ErrPetstoreUnexpectedStatus
+}
+
+// Client Petstore客户端.
+// 宠物商店示例接口
+type Client struct {
+	cli      *httpcli.Client
+	endpoint string
+	opts     []httpcli.CallOption
+}
+
+// NewClient 创建客户端, opts为所有请求的默认调用选项.
+func NewClient(cli *httpcli.Client, endpoint string, opts ...httpcli.CallOption) *Client {
+	return &Client{
+		cli:      cli,
+		endpoint: endpoint,
+		opts:     opts,
+	}
+}
+
+// ListPetsRequest ListPets请求参数.
+type ListPetsRequest struct {
+	// 返回的最大数量
+	Limit *int32 `form:"limit" json:"-"`
+	// 按标签过滤
+	Tags []string `json:"-"`
+	// 请求ID
+	XRequestID *string `header:"X-Request-ID" json:"-"`
+}
+
+// ListPets 查询宠物列表
+func (c *Client) ListPets(ctx context.Context, req *ListPetsRequest, opts ...httpcli.CallOption) ([]Pet, error) {
+	if req == nil {
+		return nil, errors.New("ListPets request must not be nil")
+	}
+	builder := httpcli.NewHttpRequestBuilder().
+		WithMethod("GET").
+		WithEndpoint(c.endpoint).
+		WithPath("/pets").
+		AddQueryParamByObject(req)
+	if len(req.Tags) != 0 {
+		builder.AddQueryParam("tags", queryValues(req.Tags))
+	}
+	if req.XRequestID != nil {
+		builder.AddHeaderParam("X-Request-ID", fmt.Sprint(*req.XRequestID))
+	}
+
+	rawResp, err := c.cli.Invoke(ctx, builder.Build(), req, nil, append(c.opts[:len(c.opts):len(c.opts)], opts...)...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if status := rawResp.GetStatusCode(); status < 200 || status >= 300 {
+		return nil, statusError(rawResp, nil, 0)
+	}
+
+	var reply []Pet
+	if err := rawResp.Decode(&reply); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return reply, nil
+}
+
+// CreatePetRequest CreatePet请求参数.
+type CreatePetRequest struct {
+	Body *NewPet `json:"-"`
+}
+
+// CreatePet 创建宠物
+func (c *Client) CreatePet(ctx context.Context, req *CreatePetRequest, opts ...httpcli.CallOption) (*Pet, error) {
+	if req == nil {
+		return nil, errors.New("CreatePet request must not be nil")
+	}
+	builder := httpcli.NewHttpRequestBuilder().
+		WithMethod("POST").
+		WithEndpoint(c.endpoint).
+		WithPath("/pets")
+	if req.Body != nil {
+		builder.AddHeaderParam("Content-Type", "application/json").WithBody("", req.Body)
+	}
+
+	rawResp, err := c.cli.Invoke(ctx, builder.Build(), req, nil, append(c.opts[:len(c.opts):len(c.opts)], opts...)...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if status := rawResp.GetStatusCode(); status < 200 || status >= 300 {
+		return nil, statusError(rawResp, nil, 0)
+	}
+
+	reply := &Pet{}
+	if err := rawResp.Decode(reply); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return reply, nil
+}
+
+// GetPetRequest GetPet请求参数.
+type GetPetRequest struct {
+	// 宠物ID
+	PetID int64 `path:"petId" json:"-"`
+}
+
+// GetPet 查询宠物
+func (c *Client) GetPet(ctx context.Context, req *GetPetRequest, opts ...httpcli.CallOption) (*Pet, error) {
+	if req == nil {
+		return nil, errors.New("GetPet request must not be nil")
+	}
+	builder := httpcli.NewHttpRequestBuilder().
+		WithMethod("GET").
+		WithEndpoint(c.endpoint).
+		WithPath("/pets/{petId}").
+		AddPathParam("petId", fmt.Sprint(req.PetID))
+
+	rawResp, err := c.cli.Invoke(ctx, builder.Build(), req, nil, append(c.opts[:len(c.opts):len(c.opts)], opts...)...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if status := rawResp.GetStatusCode(); status < 200 || status >= 300 {
+		return nil, statusError(rawResp, map[int]int{404: 110404}, 0)
+	}
+
+	reply := &Pet{}
+	if err := rawResp.Decode(reply); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return reply, nil
+}
+
+// DeletePetRequest DeletePet请求参数.
+type DeletePetRequest struct {
+	// 宠物ID
+	PetID int64 `path:"petId" json:"-"`
+}
+
+// DeletePet 删除宠物
+//
+// Deprecated: deprecated by api specification.
+func (c *Client) DeletePet(ctx context.Context, req *DeletePetRequest, opts ...httpcli.CallOption) error {
+	if req == nil {
+		return errors.New("DeletePet request must not be nil")
+	}
+	builder := httpcli.NewHttpRequestBuilder().
+		WithMethod("DELETE").
+		WithEndpoint(c.endpoint).
+		WithPath("/pets/{petId}").
+		AddPathParam("petId", fmt.Sprint(req.PetID))
+
+	rawResp, err := c.cli.Invoke(ctx, builder.Build(), req, nil, append(c.opts[:len(c.opts):len(c.opts)], opts...)...)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if status := rawResp.GetStatusCode(); status < 200 || status >= 300 {
+		return statusError(rawResp, nil, 0)
+	}
+	// 读取响应体以便复用连接
+	rawResp.GetBody()
+	return nil
+}
+
+// PutPetsByPetIDPhotoRequest PutPetsByPetIDPhoto请求参数.
+type PutPetsByPetIDPhotoRequest struct {
+	PetID int64  `path:"petId" json:"-"`
+	Body  string `json:"-"`
+}
+
+// PutPetsByPetIDPhoto 上传宠物照片
+func (c *Client) PutPetsByPetIDPhoto(ctx context.Context, req *PutPetsByPetIDPhotoRequest, opts ...httpcli.CallOption) (*PutPetsByPetIDPhotoReply, error) {
+	if req == nil {
+		return nil, errors.New("PutPetsByPetIDPhoto request must not be nil")
+	}
+	builder := httpcli.NewHttpRequestBuilder().
+		WithMethod("PUT").
+		WithEndpoint(c.endpoint).
+		WithPath("/pets/{petId}/photo").
+		AddPathParam("petId", fmt.Sprint(req.PetID))
+	builder.AddHeaderParam("Content-Type", "application/octet-stream").WithBody("", req.Body)
+
+	rawResp, err := c.cli.Invoke(ctx, builder.Build(), req, nil, append(c.opts[:len(c.opts):len(c.opts)], opts...)...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if status := rawResp.GetStatusCode(); status < 200 || status >= 300 {
+		return nil, statusError(rawResp, nil, 0)
+	}
+
+	reply := &PutPetsByPetIDPhotoReply{}
+	if err := rawResp.Decode(reply); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return reply, nil
+}
+
+// Error 数据结构.
+type Error struct {
+	Code    *int64  `json:"code,omitempty"`
+	Message *string `json:"message,omitempty"`
+}
+
+// NewPet 数据结构.
+type NewPet struct {
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// 可选值: cat, dog
+	Kind *string `json:"kind,omitempty"`
+	// 名称
+	Name string `json:"name"`
+	// 标签
+	Tag *string `json:"tag,omitempty"`
+}
+
+// PetOwner 数据结构.
+type PetOwner struct {
+	Name *string `json:"name,omitempty"`
+}
+
+// Pet 宠物
+type Pet struct {
+	Attributes map[string]string `json:"attributes,omitempty"`
+	CreatedAt  *time.Time        `json:"createdAt,omitempty"`
+	ID         int64             `json:"id"`
+	// 可选值: cat, dog
+	Kind *string `json:"kind,omitempty"`
+	// 名称
+	Name  string    `json:"name"`
+	Owner *PetOwner `json:"owner,omitempty"`
+	// 标签
+	Tag *string `json:"tag,omitempty"`
+}
+
+// PutPetsByPetIDPhotoReply 数据结构.
+type PutPetsByPetIDPhotoReply struct {
+	URL *string `json:"url,omitempty"`
+}
+
+// statusError 将非2xx响应转换为错误码错误. codes为接口声明的状态码与错误码映射, 未声明时使用defaultCode, 均未设置时按状态码映射.
+func statusError(rawResp *httpcli.HttpResponse, codes map[int]int, defaultCode int) error {
+	status := rawResp.GetStatusCode()
+	code, ok := codes[status]
+	if !ok {
+		code = defaultCode
+	}
+	if code == 0 {
+		code = statusErrorCode(status)
+	}
+	return errors.WithCode(code, "%s %s status code %d, response body:%s",
+		rawResp.Request.GetMethod(), rawResp.Request.GetPath(), status, rawResp.GetBody())
+}
+
+// queryValues 将数组参数转换为字符串切片, 按多个同名参数发送.
+func queryValues[T any](values []T) []string {
+	s := make([]string, 0, len(values))
+	for _, v := range values {
+		s = append(s, fmt.Sprint(v))
+	}
+	return s
+}
+
+// joinQuery 将数组参数以sep拼接.
+func joinQuery[T any](values []T, sep string) string {
+	return strings.Join(queryValues(values), sep)
+}
@@ -0,0 +1,842 @@
+package generators
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Config 生成配置.
+type Config struct {
+	// Package 生成代码的包名
+	Package string
+	// ErrorPrefix 错误码常量名前缀, 如Petstore生成ErrPetstoreNotFound
+	ErrorPrefix string
+	// CodeBase 错误码起始值, 生成的错误码依次递增, 注意不能与其他已注册的错误码冲突
+	CodeBase int
+	// Command 生成命令, 写入文件头部
+	Command string
+}
+
+// 生成的错误码, 未通过x-error-code指定错误码的非2xx响应按状态码映射到这些错误码.
+var statusErrors = []struct {
+	name      string
+	status    int
+	httpCode  int
+	messageEN string
+	messageCN string
+}{
+	{"BadRequest", 400, 400, "Bad request.", "请求参数错误"},
+	{"Unauthorized", 401, 401, "Unauthorized.", "认证失败"},
+	{"Forbidden", 403, 403, "Forbidden.", "没有权限"},
+	{"NotFound", 404, 404, "Resource not found.", "资源不存在"},
+	{"Conflict", 409, 400, "Resource conflict.", "资源冲突"},
+	{"TooManyRequests", 429, 500, "Too many requests.", "请求过于频繁"},
+	{"ServerError", 500, 500, "Server error.", "服务端错误"},
+	{"UnexpectedStatus", 0, 500, "Unexpected status code.", "非预期的响应状态码"},
+}
+
+// Generator 根据OpenAPI 3文档生成基于httpcli的客户端代码.
+type Generator struct {
+	spec *Spec
+	cfg  Config
+
+	// 已声明的类型定义
+	decls    []string
+	declared map[string]bool
+	// 结构体类型, 作为可选字段/返回值时使用指针
+	structs map[string]bool
+	imports map[string]bool
+}
+
+// Generate 生成客户端代码.
+func Generate(spec *Spec, cfg Config) ([]byte, error) {
+	if cfg.Package == "" {
+		return nil, fmt.Errorf("package name must be set")
+	}
+	if cfg.CodeBase <= 1 {
+		return nil, fmt.Errorf("error code base must be greater than 1")
+	}
+	if cfg.ErrorPrefix == "" {
+		cfg.ErrorPrefix = goName(spec.Info.Title)
+	}
+
+	g := &Generator{
+		spec:     spec,
+		cfg:      cfg,
+		declared: make(map[string]bool),
+		structs:  make(map[string]bool),
+		imports: map[string]bool{
+			"context": true,
+			"fmt":     true,
+			"github.com/wangweihong/gotoolbox/pkg/errors":  true,
+			"github.com/wangweihong/gotoolbox/pkg/httpcli": true,
+		},
+	}
+
+	// 先登记组件类型名, 使得类型之间可以相互引用
+	names := make([]string, 0, len(spec.Components.Schemas))
+	for name, s := range spec.Components.Schemas {
+		names = append(names, name)
+		if isStructSchema(s) {
+			g.structs[goName(name)] = true
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := g.declareSchema(goName(name), spec.Components.Schemas[name]); err != nil {
+			return nil, fmt.Errorf("schema %v: %v", name, err)
+		}
+	}
+
+	ops, err := g.operations()
+	if err != nil {
+		return nil, err
+	}
+
+	body := &bytes.Buffer{}
+	g.writeErrorCodes(body)
+	g.writeClient(body)
+	for _, op := range ops {
+		op.write(body)
+	}
+	for _, d := range g.decls {
+		body.WriteString(d)
+	}
+	g.writeHelpers(body)
+
+	out := &bytes.Buffer{}
+	fmt.Fprintf(out, "// Code generated by \"%s\"; DO NOT EDIT.\n\n", cfg.Command)
+	fmt.Fprintf(out, "package %s\n\n", cfg.Package)
+	g.writeImports(out)
+	out.Write(body.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return out.Bytes(), fmt.Errorf("format generated code error:%v", err)
+	}
+	return src, nil
+}
+
+func (g *Generator) writeImports(w *bytes.Buffer) {
+	std := make([]string, 0)
+	others := make([]string, 0)
+	for imp := range g.imports {
+		if strings.Contains(imp, ".") {
+			others = append(others, imp)
+		} else {
+			std = append(std, imp)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(others)
+
+	w.WriteString("import (\n")
+	for _, imp := range std {
+		fmt.Fprintf(w, "\t%q\n", imp)
+	}
+	w.WriteString("\n")
+	for _, imp := range others {
+		fmt.Fprintf(w, "\t%q\n", imp)
+	}
+	w.WriteString(")\n\n")
+}
+
+func (g *Generator) errorCodeName(name string) string {
+	return "Err" + g.cfg.ErrorPrefix + name
+}
+
+func (g *Generator) writeErrorCodes(w *bytes.Buffer) {
+	w.WriteString("// 非2xx响应映射的错误码.\nconst (\n")
+	for i, e := range statusErrors {
+		fmt.Fprintf(w, "\t// @HTTP %d\n\t// @MessageCN %s\n\t// @MessageEN %s\n", e.httpCode, e.messageCN, e.messageEN)
+		if i == 0 {
+			fmt.Fprintf(w, "\t%s int = iota + %d\n", g.errorCodeName(e.name), g.cfg.CodeBase)
+		} else {
+			fmt.Fprintf(w, "\t%s\n", g.errorCodeName(e.name))
+		}
+	}
+	w.WriteString(")\n\n")
+
+	w.WriteString("func init() {\n")
+	for _, e := range statusErrors {
+		fmt.Fprintf(w, "\terrors.MustRegister(errors.NewCoder(%s, %d, map[string]string{errors.MessageLangCNKey: %q, errors.MessageLangENKey: %q}))\n",
+			g.errorCodeName(e.name), e.httpCode, e.messageCN, e.messageEN)
+	}
+	w.WriteString("}\n\n")
+
+	w.WriteString("// statusErrorCode 按响应状态码返回默认错误码.\nfunc statusErrorCode(status int) int {\n\tswitch {\n")
+	for _, e := range statusErrors {
+		switch {
+		case e.status == 500:
+			fmt.Fprintf(w, "\tcase status >= 500:\n\t\treturn %s\n", g.errorCodeName(e.name))
+		case e.status != 0:
+			fmt.Fprintf(w, "\tcase status == %d:\n\t\treturn %s\n", e.status, g.errorCodeName(e.name))
+		}
+	}
+	fmt.Fprintf(w, "\t}\n\treturn %s\n}\n\n", g.errorCodeName("UnexpectedStatus"))
+}
+
+func (g *Generator) writeClient(w *bytes.Buffer) {
+	title := g.spec.Info.Title
+	if title == "" {
+		title = g.cfg.Package
+	}
+	fmt.Fprintf(w, "// Client %s客户端.\n", title)
+	w.WriteString(commentLines("", g.spec.Info.Description))
+	w.WriteString(`type Client struct {
+	cli      *httpcli.Client
+	endpoint string
+	opts     []httpcli.CallOption
+}
+
+// NewClient 创建客户端, opts为所有请求的默认调用选项.
+func NewClient(cli *httpcli.Client, endpoint string, opts ...httpcli.CallOption) *Client {
+	return &Client{
+		cli:      cli,
+		endpoint: endpoint,
+		opts:     opts,
+	}
+}
+
+`)
+}
+
+func (g *Generator) writeHelpers(w *bytes.Buffer) {
+	w.WriteString(`// statusError 将非2xx响应转换为错误码错误. codes为接口声明的状态码与错误码映射, 未声明时使用defaultCode, 均未设置时按状态码映射.
+func statusError(rawResp *httpcli.HttpResponse, codes map[int]int, defaultCode int) error {
+	status := rawResp.GetStatusCode()
+	code, ok := codes[status]
+	if !ok {
+		code = defaultCode
+	}
+	if code == 0 {
+		code = statusErrorCode(status)
+	}
+	return errors.WithCode(code, "%s %s status code %d, response body:%s",
+		rawResp.Request.GetMethod(), rawResp.Request.GetPath(), status, rawResp.GetBody())
+}
+
+// queryValues 将数组参数转换为字符串切片, 按多个同名参数发送.
+func queryValues[T any](values []T) []string {
+	s := make([]string, 0, len(values))
+	for _, v := range values {
+		s = append(s, fmt.Sprint(v))
+	}
+	return s
+}
+
+// joinQuery 将数组参数以sep拼接.
+func joinQuery[T any](values []T, sep string) string {
+	return strings.Join(queryValues(values), sep)
+}
+`)
+	g.imports["strings"] = true
+}
+
+func isStructSchema(s *Schema) bool {
+	if s == nil || s.Ref != "" {
+		return false
+	}
+	if len(s.AllOf) != 0 {
+		return true
+	}
+	return (s.Type == "object" || s.Type == "") && len(s.Properties) != 0
+}
+
+// declareSchema 声明命名类型.
+func (g *Generator) declareSchema(name string, s *Schema) error {
+	if g.declared[name] {
+		return nil
+	}
+	g.declared[name] = true
+
+	if isStructSchema(s) {
+		g.structs[name] = true
+		return g.declareStruct(name, s)
+	}
+
+	typ, err := g.goType(s, name+"Item")
+	if err != nil {
+		return err
+	}
+	decl := fmt.Sprintf("// %s 数据类型.\n", name)
+	if doc := schemaDoc(s); doc != "" {
+		decl = commentLines("", name+" "+doc)
+	}
+	g.decls = append(g.decls, fmt.Sprintf("%stype %s %s\n\n", decl, name, typ))
+	return nil
+}
+
+// declareStruct 声明结构体, allOf中的各部分合并为一个结构体.
+func (g *Generator) declareStruct(name string, s *Schema) error {
+	props, required, err := g.mergeProperties(s)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	b := &strings.Builder{}
+	if doc := schemaDoc(s); doc != "" {
+		b.WriteString(commentLines("", name+" "+doc))
+	} else {
+		fmt.Fprintf(b, "// %s 数据结构.\n", name)
+	}
+	fmt.Fprintf(b, "type %s struct {\n", name)
+	for _, k := range keys {
+		p := props[k]
+		typ, err := g.goType(p, name+goName(k))
+		if err != nil {
+			return fmt.Errorf("property %v: %v", k, err)
+		}
+
+		tag := k
+		if !required[k] {
+			tag += ",omitempty"
+		}
+		if (!required[k] || p.Nullable) && g.pointable(typ) {
+			typ = "*" + typ
+		}
+		b.WriteString(commentLines("\t", schemaDoc(p)))
+		fmt.Fprintf(b, "\t%s %s `json:%q`\n", goName(k), typ, tag)
+	}
+	b.WriteString("}\n\n")
+
+	g.decls = append(g.decls, b.String())
+	return nil
+}
+
+func (g *Generator) mergeProperties(s *Schema) (map[string]*Schema, map[string]bool, error) {
+	props := make(map[string]*Schema)
+	required := make(map[string]bool)
+
+	var merge func(s *Schema) error
+	merge = func(s *Schema) error {
+		if s.Ref != "" {
+			name, err := refName(s.Ref, "schemas")
+			if err != nil {
+				return err
+			}
+			rs, ok := g.spec.Components.Schemas[name]
+			if !ok {
+				return fmt.Errorf("schema %q not found", s.Ref)
+			}
+			return merge(rs)
+		}
+		for _, part := range s.AllOf {
+			if err := merge(part); err != nil {
+				return err
+			}
+		}
+		for k, p := range s.Properties {
+			props[k] = p
+		}
+		for _, k := range s.Required {
+			required[k] = true
+		}
+		return nil
+	}
+	return props, required, merge(s)
+}
+
+// pointable 可选字段是否使用指针, 切片/映射/any本身可以表示未设置.
+func (g *Generator) pointable(typ string) bool {
+	return !strings.HasPrefix(typ, "[]") && !strings.HasPrefix(typ, "map[") && typ != "any" && !strings.HasPrefix(typ, "*")
+}
+
+func schemaDoc(s *Schema) string {
+	if s == nil {
+		return ""
+	}
+	doc := s.Description
+	if len(s.Enum) != 0 {
+		values := make([]string, 0, len(s.Enum))
+		for _, v := range s.Enum {
+			values = append(values, fmt.Sprint(v))
+		}
+		if doc != "" {
+			doc += "\n"
+		}
+		doc += "可选值: " + strings.Join(values, ", ")
+	}
+	return doc
+}
+
+// goType 返回Schema对应的Go类型, 内联对象以nameHint声明为命名结构体.
+func (g *Generator) goType(s *Schema, nameHint string) (string, error) {
+	if s == nil {
+		return "any", nil
+	}
+	if s.Ref != "" {
+		name, err := refName(s.Ref, "schemas")
+		if err != nil {
+			return "", err
+		}
+		if _, ok := g.spec.Components.Schemas[name]; !ok {
+			return "", fmt.Errorf("schema %q not found", s.Ref)
+		}
+		return goName(name), nil
+	}
+	if len(s.AllOf) == 1 && s.AllOf[0].Ref != "" && len(s.Properties) == 0 {
+		return g.goType(s.AllOf[0], nameHint)
+	}
+	if isStructSchema(s) {
+		if err := g.declareSchema(nameHint, s); err != nil {
+			return "", err
+		}
+		return nameHint, nil
+	}
+
+	switch s.Type {
+	case "string":
+		switch s.Format {
+		case "date-time":
+			g.imports["time"] = true
+			return "time.Time", nil
+		case "byte":
+			// JSON中[]byte以base64编码
+			return "[]byte", nil
+		}
+		return "string", nil
+	case "integer":
+		if s.Format == "int32" {
+			return "int32", nil
+		}
+		return "int64", nil
+	case "number":
+		if s.Format == "float" {
+			return "float32", nil
+		}
+		return "float64", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		item, err := g.goType(s.Items, nameHint)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + item, nil
+	case "object", "":
+		if s.AdditionalProperties != nil {
+			value, err := g.goType(s.AdditionalProperties, nameHint+"Value")
+			if err != nil {
+				return "", err
+			}
+			return "map[string]" + value, nil
+		}
+		if s.Type == "object" {
+			return "map[string]any", nil
+		}
+		return "any", nil
+	}
+	return "", fmt.Errorf("unsupported schema type %q", s.Type)
+}
+
+// operation 待生成的接口.
+type operation struct {
+	g           *Generator
+	name        string
+	method      string
+	path        string
+	doc         string
+	deprecated  bool
+	params      []*param
+	body        *param
+	bodyType    string
+	contentType string
+	replyType   string
+	codes       map[int]int
+	defaultCode int
+}
+
+type param struct {
+	name     string
+	field    string
+	in       string
+	typ      string
+	required bool
+	doc      string
+	// sep 数组参数的分隔符, 为空时按多个同名参数发送
+	sep string
+}
+
+// 数组参数非展开时各序列化方式的分隔符.
+var styleSeparators = map[string]string{
+	"form":           ",",
+	"simple":         ",",
+	"spaceDelimited": " ",
+	"pipeDelimited":  "|",
+}
+
+func (g *Generator) operations() ([]*operation, error) {
+	paths := make([]string, 0, len(g.spec.Paths))
+	for p := range g.spec.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	ops := make([]*operation, 0)
+	names := make(map[string]string)
+	for _, p := range paths {
+		item := g.spec.Paths[p]
+		for _, o := range item.Operations() {
+			op, err := g.operation(p, o.Method, item, o.Op)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %v", o.Method, p, err)
+			}
+			if exist, ok := names[op.name]; ok {
+				return nil, fmt.Errorf("%s %s: operation name %v conflicts with %v", o.Method, p, op.name, exist)
+			}
+			names[op.name] = o.Method + " " + p
+			ops = append(ops, op)
+		}
+	}
+	return ops, nil
+}
+
+// operationName 优先使用operationId, 否则由方法和路径生成, 如GET /pets/{id} -> GetPetsByID.
+func operationName(method, path string, o *Operation) string {
+	if o.OperationID != "" {
+		return goName(o.OperationID)
+	}
+
+	name := goName(strings.ToLower(method))
+	for _, seg := range strings.Split(path, "/") {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			name += "By" + goName(strings.Trim(seg, "{}"))
+		} else if seg != "" {
+			name += goName(seg)
+		}
+	}
+	return name
+}
+
+func (g *Generator) operation(path, method string, item *PathItem, o *Operation) (*operation, error) {
+	op := &operation{
+		g:          g,
+		name:       operationName(method, path, o),
+		method:     method,
+		path:       path,
+		deprecated: o.Deprecated,
+		codes:      make(map[int]int),
+	}
+	op.doc = strings.TrimSpace(o.Summary)
+	if o.Description != "" {
+		op.doc = strings.TrimSpace(op.doc + "\n" + o.Description)
+	}
+
+	// 路径级参数可被操作级同名参数覆盖
+	merged := make([]*Parameter, 0)
+	index := make(map[string]int)
+	for _, raw := range append(append([]*Parameter{}, item.Parameters...), o.Parameters...) {
+		p, err := g.spec.resolveParameter(raw)
+		if err != nil {
+			return nil, err
+		}
+		key := p.In + ":" + p.Name
+		if i, ok := index[key]; ok {
+			merged[i] = p
+			continue
+		}
+		index[key] = len(merged)
+		merged = append(merged, p)
+	}
+
+	fields := make(map[string]bool)
+	for _, p := range merged {
+		if p.In == "cookie" {
+			log.Printf("warning: %s %s cookie parameter %v is not supported, skipped", method, path, p.Name)
+			continue
+		}
+		typ, err := g.goType(p.Schema, op.name+goName(p.Name))
+		if err != nil {
+			return nil, fmt.Errorf("parameter %v: %v", p.Name, err)
+		}
+		field := goName(p.Name)
+		if fields[field] {
+			field += goName(p.In)
+		}
+		fields[field] = true
+
+		required := p.Required || p.In == "path"
+		if !required && g.pointable(typ) {
+			typ = "*" + typ
+		}
+		pa := &param{
+			name: p.Name, field: field, in: p.In, typ: typ, required: required, doc: p.Description,
+		}
+		if strings.HasPrefix(typ, "[]") {
+			style, explode := p.serialization()
+			sep, ok := styleSeparators[style]
+			if !ok || (p.In != "query" && style != "simple") || (p.In == "query" && style == "simple") {
+				return nil, fmt.Errorf("parameter %v: style %v is not supported for %v array", p.Name, style, p.In)
+			}
+			// 头部参数不能重复, simple方式下展开与否都以逗号拼接
+			if !explode || p.In != "query" {
+				pa.sep = sep
+			}
+		}
+		op.params = append(op.params, pa)
+	}
+
+	if o.RequestBody != nil {
+		if err := op.setBody(o.RequestBody); err != nil {
+			return nil, err
+		}
+	}
+	if err := op.setResponses(o.Responses); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+func (op *operation) setBody(raw *RequestBody) error {
+	rb, err := op.g.spec.resolveRequestBody(raw)
+	if err != nil {
+		return err
+	}
+
+	if s := jsonSchema(rb.Content); s != nil {
+		typ, err := op.g.goType(s, op.name+"Body")
+		if err != nil {
+			return fmt.Errorf("request body: %v", err)
+		}
+		if op.g.structs[typ] {
+			typ = "*" + typ
+		}
+		op.contentType = "application/json"
+		op.bodyType = typ
+	} else {
+		// 非JSON请求体按原始数据发送
+		cts := make([]string, 0, len(rb.Content))
+		for ct := range rb.Content {
+			cts = append(cts, ct)
+		}
+		sort.Strings(cts)
+		if len(cts) == 0 {
+			return fmt.Errorf("request body has no content")
+		}
+		if strings.HasPrefix(cts[0], "multipart/") || cts[0] == "application/x-www-form-urlencoded" {
+			return fmt.Errorf("request body content type %v is not supported", cts[0])
+		}
+		op.contentType = cts[0]
+		op.bodyType = "string"
+	}
+
+	op.body = &param{field: "Body", typ: op.bodyType, required: rb.Required, doc: rb.Description}
+	return nil
+}
+
+func (op *operation) setResponses(responses map[string]*Response) error {
+	statuses := make([]string, 0, len(responses))
+	for s := range responses {
+		statuses = append(statuses, s)
+	}
+	sort.Strings(statuses)
+
+	for _, s := range statuses {
+		r, err := op.g.spec.resolveResponse(responses[s])
+		if err != nil {
+			return err
+		}
+
+		if s == "default" {
+			if r.XErrorCode != nil {
+				op.defaultCode = *r.XErrorCode
+			}
+			continue
+		}
+		status, err := strconv.Atoi(s)
+		if err != nil {
+			// 如4XX等范围状态码只用于文档
+			continue
+		}
+		if status >= 200 && status < 300 {
+			if op.replyType != "" {
+				continue
+			}
+			if schema := jsonSchema(r.Content); schema != nil {
+				typ, err := op.g.goType(schema, op.name+"Reply")
+				if err != nil {
+					return fmt.Errorf("response %v: %v", s, err)
+				}
+				op.replyType = typ
+			}
+			continue
+		}
+		if r.XErrorCode != nil {
+			op.codes[status] = *r.XErrorCode
+		}
+	}
+	return nil
+}
+
+func (op *operation) requestType() string {
+	if len(op.params) == 0 && op.body == nil {
+		return ""
+	}
+	return op.name + "Request"
+}
+
+// returnType 返回值类型, 结构体返回指针.
+func (op *operation) returnType() string {
+	if op.replyType == "" {
+		return ""
+	}
+	if op.g.structs[op.replyType] {
+		return "*" + op.replyType
+	}
+	return op.replyType
+}
+
+func (op *operation) write(w *bytes.Buffer) {
+	reqType := op.requestType()
+	if reqType != "" {
+		fmt.Fprintf(w, "// %s %s请求参数.\ntype %s struct {\n", reqType, op.name, reqType)
+		for _, p := range op.params {
+			w.WriteString(commentLines("\t", p.doc))
+			switch {
+			case p.in == "query" && strings.HasPrefix(strings.TrimPrefix(p.typ, "*"), "[]"):
+				// 数组查询参数单独拼接
+				fmt.Fprintf(w, "\t%s %s `json:\"-\"`\n", p.field, p.typ)
+			case p.in == "query":
+				fmt.Fprintf(w, "\t%s %s `form:%q json:\"-\"`\n", p.field, p.typ, p.name)
+			default:
+				fmt.Fprintf(w, "\t%s %s `%s:%q json:\"-\"`\n", p.field, p.typ, p.in, p.name)
+			}
+		}
+		if op.body != nil {
+			w.WriteString(commentLines("\t", op.body.doc))
+			fmt.Fprintf(w, "\tBody %s `json:\"-\"`\n", op.body.typ)
+		}
+		w.WriteString("}\n\n")
+	}
+
+	// 方法注释
+	if op.doc != "" {
+		w.WriteString(commentLines("", op.name+" "+op.doc))
+	} else {
+		fmt.Fprintf(w, "// %s %s %s.\n", op.name, op.method, op.path)
+	}
+	if op.deprecated {
+		w.WriteString("//\n// Deprecated: deprecated by api specification.\n")
+	}
+
+	ret := op.returnType()
+	fmt.Fprintf(w, "func (c *Client) %s(ctx context.Context, ", op.name)
+	if reqType != "" {
+		fmt.Fprintf(w, "req *%s, ", reqType)
+	}
+	w.WriteString("opts ...httpcli.CallOption) ")
+	zero := "nil"
+	if ret != "" {
+		fmt.Fprintf(w, "(%s, error) {\n", ret)
+		if !strings.HasPrefix(ret, "*") && !strings.HasPrefix(ret, "[]") && !strings.HasPrefix(ret, "map[") {
+			zero = "reply"
+			fmt.Fprintf(w, "\tvar reply %s\n", ret)
+		}
+	} else {
+		w.WriteString("error {\n")
+	}
+	ret2 := func(errExpr string) string {
+		if ret == "" {
+			return errExpr
+		}
+		return zero + ", " + errExpr
+	}
+
+	if reqType != "" {
+		fmt.Fprintf(w, "\tif req == nil {\n\t\treturn %s\n\t}\n", ret2(fmt.Sprintf("errors.New(%q)", op.name+" request must not be nil")))
+	}
+
+	fmt.Fprintf(w, "\tbuilder := httpcli.NewHttpRequestBuilder().\n\t\tWithMethod(%q).\n\t\tWithEndpoint(c.endpoint).\n\t\tWithPath(%q)", op.method, op.path)
+	hasQuery := false
+	for _, p := range op.params {
+		if p.in == "path" {
+			fmt.Fprintf(w, ".\n\t\tAddPathParam(%q, fmt.Sprint(req.%s))", p.name, p.field)
+		}
+		if p.in == "query" {
+			hasQuery = true
+		}
+	}
+	if hasQuery {
+		w.WriteString(".\n\t\tAddQueryParamByObject(req)")
+	}
+	w.WriteString("\n")
+
+	for _, p := range op.params {
+		isSlice := strings.HasPrefix(strings.TrimPrefix(p.typ, "*"), "[]")
+		switch {
+		case p.in == "query" && isSlice && p.sep == "":
+			fmt.Fprintf(w, "\tif len(req.%s) != 0 {\n\t\tbuilder.AddQueryParam(%q, queryValues(req.%s))\n\t}\n", p.field, p.name, p.field)
+		case p.in == "query" && isSlice:
+			fmt.Fprintf(w, "\tif len(req.%s) != 0 {\n\t\tbuilder.AddQueryParam(%q, joinQuery(req.%s, %q))\n\t}\n", p.field, p.name, p.field, p.sep)
+		case p.in == "header" && strings.HasPrefix(p.typ, "*"):
+			fmt.Fprintf(w, "\tif req.%s != nil {\n\t\tbuilder.AddHeaderParam(%q, fmt.Sprint(*req.%s))\n\t}\n", p.field, p.name, p.field)
+		case p.in == "header" && isSlice:
+			fmt.Fprintf(w, "\tif len(req.%s) != 0 {\n\t\tbuilder.AddHeaderParam(%q, joinQuery(req.%s, %q))\n\t}\n", p.field, p.name, p.field, p.sep)
+		case p.in == "header":
+			fmt.Fprintf(w, "\tbuilder.AddHeaderParam(%q, fmt.Sprint(req.%s))\n", p.name, p.field)
+		}
+	}
+
+	if op.body != nil {
+		switch {
+		case strings.HasPrefix(op.body.typ, "*") || strings.HasPrefix(op.body.typ, "[]") || strings.HasPrefix(op.body.typ, "map["):
+			fmt.Fprintf(w, "\tif req.Body != nil {\n\t\tbuilder.AddHeaderParam(\"Content-Type\", %q).WithBody(\"\", req.Body)\n\t}\n", op.contentType)
+		default:
+			fmt.Fprintf(w, "\tbuilder.AddHeaderParam(\"Content-Type\", %q).WithBody(\"\", req.Body)\n", op.contentType)
+		}
+	}
+
+	arg := "nil"
+	if reqType != "" {
+		arg = "req"
+	}
+	// 限制c.opts的容量, 并发调用时append总是复制, 不会写入共享的底层数组
+	fmt.Fprintf(w, "\n\trawResp, err := c.cli.Invoke(ctx, builder.Build(), %s, nil, append(c.opts[:len(c.opts):len(c.opts)], opts...)...)\n", arg)
+	fmt.Fprintf(w, "\tif err != nil {\n\t\treturn %s\n\t}\n", ret2("errors.WithStack(err)"))
+
+	codes := "nil"
+	if len(op.codes) != 0 {
+		statuses := make([]int, 0, len(op.codes))
+		for s := range op.codes {
+			statuses = append(statuses, s)
+		}
+		sort.Ints(statuses)
+		items := make([]string, 0, len(statuses))
+		for _, s := range statuses {
+			items = append(items, fmt.Sprintf("%d: %d", s, op.codes[s]))
+		}
+		codes = "map[int]int{" + strings.Join(items, ", ") + "}"
+	}
+	fmt.Fprintf(w, "\tif status := rawResp.GetStatusCode(); status < 200 || status >= 300 {\n\t\treturn %s\n\t}\n",
+		ret2(fmt.Sprintf("statusError(rawResp, %s, %d)", codes, op.defaultCode)))
+
+	if ret == "" {
+		w.WriteString("\t// 读取响应体以便复用连接\n\trawResp.GetBody()\n\treturn nil\n}\n\n")
+		return
+	}
+	if zero == "nil" {
+		if strings.HasPrefix(ret, "*") {
+			fmt.Fprintf(w, "\n\treply := &%s{}\n", strings.TrimPrefix(ret, "*"))
+		} else {
+			fmt.Fprintf(w, "\n\tvar reply %s\n", ret)
+		}
+	}
+	replyRef := "reply"
+	if !strings.HasPrefix(ret, "*") {
+		replyRef = "&reply"
+	}
+	fmt.Fprintf(w, "\tif err := rawResp.Decode(%s); err != nil {\n\t\treturn %s\n\t}\n\treturn reply, nil\n}\n\n",
+		replyRef, ret2("errors.WithStack(err)"))
+}
@@ -0,0 +1,61 @@
+package generators
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGenerateGolden(t *testing.T) {
+	specs, err := filepath.Glob(filepath.Join("testdata", "*.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range specs {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			spec, err := LoadSpec(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Generate(spec, Config{Package: "testdata", CodeBase: 120001, Command: "openapi-gen -spec " + filepath.Base(path)})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			golden := path[:len(path)-len(filepath.Ext(path))] + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("generated code of %v differs from %v, run go test -update to regenerate:\n%s", path, golden, got)
+			}
+		})
+	}
+}
+
+func TestGenerateUnsupportedStyle(t *testing.T) {
+	spec := &Spec{
+		OpenAPI: "3.0.3",
+		Paths: map[string]*PathItem{
+			"/items": {Get: &Operation{
+				Parameters: []*Parameter{{
+					Name: "filter", In: "query", Style: "deepObject",
+					Schema: &Schema{Type: "array", Items: &Schema{Type: "string"}},
+				}},
+			}},
+		},
+	}
+	if _, err := Generate(spec, Config{Package: "testdata", CodeBase: 120001}); err == nil {
+		t.Errorf("expected error for deepObject array parameter")
+	}
+}
@@ -0,0 +1,85 @@
+package generators
+
+import (
+	"strings"
+	"unicode"
+)
+
+// commonInitialisms 生成Go标识符时保持全大写的缩写.
+var commonInitialisms = map[string]bool{
+	"API": true, "HTTP": true, "HTTPS": true, "ID": true, "IP": true, "JSON": true,
+	"SQL": true, "TCP": true, "TLS": true, "TTL": true, "UDP": true, "UID": true,
+	"UUID": true, "URI": true, "URL": true, "XML": true, "CPU": true, "DNS": true,
+}
+
+// splitWords 按非字母数字字符及驼峰边界拆分单词.
+func splitWords(s string) []string {
+	words := make([]string, 0)
+	var cur []rune
+	flush := func() {
+		if len(cur) != 0 {
+			words = append(words, string(cur))
+			cur = nil
+		}
+	}
+
+	runes := []rune(s)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if unicode.IsUpper(r) && len(cur) != 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			// fooBar -> foo Bar, HTTPServer -> HTTP Server
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				flush()
+			}
+		}
+		cur = append(cur, r)
+	}
+	flush()
+	return words
+}
+
+// goName 转换为导出的Go标识符, 如pet_id -> PetID.
+func goName(s string) string {
+	var b strings.Builder
+	for _, w := range splitWords(s) {
+		upper := strings.ToUpper(w)
+		if commonInitialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		r := []rune(strings.ToLower(w))
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+
+	name := b.String()
+	if name == "" {
+		return "Unnamed"
+	}
+	if unicode.IsDigit([]rune(name)[0]) {
+		name = "N" + name
+	}
+	return name
+}
+
+// commentLines 将描述转换为注释行.
+func commentLines(prefix, text string) string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		b.WriteString(prefix)
+		b.WriteString("// ")
+		b.WriteString(strings.TrimRight(line, " \t"))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
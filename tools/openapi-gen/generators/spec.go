@@ -0,0 +1,223 @@
+package generators
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec OpenAPI 3文档中生成客户端需要的部分. 同时支持YAML和JSON格式.
+type Spec struct {
+	OpenAPI    string               `yaml:"openapi"`
+	Info       Info                 `yaml:"info"`
+	Paths      map[string]*PathItem `yaml:"paths"`
+	Components Components           `yaml:"components"`
+}
+
+type Info struct {
+	Title       string `yaml:"title"`
+	Description string `yaml:"description"`
+	Version     string `yaml:"version"`
+}
+
+type Components struct {
+	Schemas       map[string]*Schema      `yaml:"schemas"`
+	Parameters    map[string]*Parameter   `yaml:"parameters"`
+	RequestBodies map[string]*RequestBody `yaml:"requestBodies"`
+	Responses     map[string]*Response    `yaml:"responses"`
+}
+
+type PathItem struct {
+	Parameters []*Parameter `yaml:"parameters"`
+	Get        *Operation   `yaml:"get"`
+	Put        *Operation   `yaml:"put"`
+	Post       *Operation   `yaml:"post"`
+	Delete     *Operation   `yaml:"delete"`
+	Patch      *Operation   `yaml:"patch"`
+	Head       *Operation   `yaml:"head"`
+}
+
+// Operations 按固定顺序返回路径下的操作.
+func (p *PathItem) Operations() []struct {
+	Method string
+	Op     *Operation
+} {
+	all := []struct {
+		Method string
+		Op     *Operation
+	}{
+		{"GET", p.Get}, {"PUT", p.Put}, {"POST", p.Post},
+		{"DELETE", p.Delete}, {"PATCH", p.Patch}, {"HEAD", p.Head},
+	}
+	ops := all[:0]
+	for _, o := range all {
+		if o.Op != nil {
+			ops = append(ops, o)
+		}
+	}
+	return ops
+}
+
+type Operation struct {
+	OperationID string               `yaml:"operationId"`
+	Summary     string               `yaml:"summary"`
+	Description string               `yaml:"description"`
+	Deprecated  bool                 `yaml:"deprecated"`
+	Parameters  []*Parameter         `yaml:"parameters"`
+	RequestBody *RequestBody         `yaml:"requestBody"`
+	Responses   map[string]*Response `yaml:"responses"`
+}
+
+type Parameter struct {
+	Ref         string  `yaml:"$ref"`
+	Name        string  `yaml:"name"`
+	In          string  `yaml:"in"`
+	Description string  `yaml:"description"`
+	Required    bool    `yaml:"required"`
+	Schema      *Schema `yaml:"schema"`
+	// Style 序列化方式, 查询参数默认form, 头部参数默认simple
+	Style string `yaml:"style"`
+	// Explode 数组是否按多个同名参数发送, form默认为true, 其他默认为false
+	Explode *bool `yaml:"explode"`
+}
+
+// serialization 返回参数的序列化方式及是否展开.
+func (p *Parameter) serialization() (string, bool) {
+	style := p.Style
+	if style == "" {
+		style = "simple"
+		if p.In == "query" || p.In == "cookie" {
+			style = "form"
+		}
+	}
+	explode := style == "form"
+	if p.Explode != nil {
+		explode = *p.Explode
+	}
+	return style, explode
+}
+
+type RequestBody struct {
+	Ref         string                `yaml:"$ref"`
+	Description string                `yaml:"description"`
+	Required    bool                  `yaml:"required"`
+	Content     map[string]*MediaType `yaml:"content"`
+}
+
+type Response struct {
+	Ref         string                `yaml:"$ref"`
+	Description string                `yaml:"description"`
+	Content     map[string]*MediaType `yaml:"content"`
+	// XErrorCode 扩展字段, 指定该响应映射的pkg/errors错误码, 用于复用已注册的错误码
+	XErrorCode *int `yaml:"x-error-code"`
+}
+
+type MediaType struct {
+	Schema *Schema `yaml:"schema"`
+}
+
+type Schema struct {
+	Ref                  string             `yaml:"$ref"`
+	Type                 string             `yaml:"type"`
+	Format               string             `yaml:"format"`
+	Description          string             `yaml:"description"`
+	Properties           map[string]*Schema `yaml:"properties"`
+	Required             []string           `yaml:"required"`
+	Items                *Schema            `yaml:"items"`
+	AdditionalProperties *Schema            `yaml:"additionalProperties"`
+	AllOf                []*Schema          `yaml:"allOf"`
+	Enum                 []any              `yaml:"enum"`
+	Nullable             bool               `yaml:"nullable"`
+}
+
+// LoadSpec 加载OpenAPI 3文档.
+func LoadSpec(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := &Spec{}
+	if err := yaml.Unmarshal(data, spec); err != nil {
+		return nil, fmt.Errorf("decode spec %v error:%v", path, err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported openapi version %q, only 3.x is supported", spec.OpenAPI)
+	}
+	return spec, nil
+}
+
+// refName 返回`#/components/<kind>/<name>`中的name.
+func refName(ref, kind string) (string, error) {
+	prefix := "#/components/" + kind + "/"
+	if !strings.HasPrefix(ref, prefix) {
+		return "", fmt.Errorf("unsupported reference %q", ref)
+	}
+	return strings.TrimPrefix(ref, prefix), nil
+}
+
+func (s *Spec) resolveParameter(p *Parameter) (*Parameter, error) {
+	if p.Ref == "" {
+		return p, nil
+	}
+	name, err := refName(p.Ref, "parameters")
+	if err != nil {
+		return nil, err
+	}
+	rp, ok := s.Components.Parameters[name]
+	if !ok {
+		return nil, fmt.Errorf("parameter %q not found", p.Ref)
+	}
+	return rp, nil
+}
+
+func (s *Spec) resolveRequestBody(b *RequestBody) (*RequestBody, error) {
+	if b.Ref == "" {
+		return b, nil
+	}
+	name, err := refName(b.Ref, "requestBodies")
+	if err != nil {
+		return nil, err
+	}
+	rb, ok := s.Components.RequestBodies[name]
+	if !ok {
+		return nil, fmt.Errorf("request body %q not found", b.Ref)
+	}
+	return rb, nil
+}
+
+func (s *Spec) resolveResponse(r *Response) (*Response, error) {
+	if r.Ref == "" {
+		return r, nil
+	}
+	name, err := refName(r.Ref, "responses")
+	if err != nil {
+		return nil, err
+	}
+	rr, ok := s.Components.Responses[name]
+	if !ok {
+		return nil, fmt.Errorf("response %q not found", r.Ref)
+	}
+	// 引用处的错误码扩展优先
+	if r.XErrorCode != nil {
+		copied := *rr
+		copied.XErrorCode = r.XErrorCode
+		return &copied, nil
+	}
+	return rr, nil
+}
+
+// jsonSchema 返回JSON类型内容的Schema, 没有JSON内容时返回nil.
+func jsonSchema(content map[string]*MediaType) *Schema {
+	if m, ok := content["application/json"]; ok && m != nil && m.Schema != nil {
+		return m.Schema
+	}
+	for mt, m := range content {
+		if m != nil && m.Schema != nil && (mt == "application/json" || strings.HasSuffix(mt, "+json")) {
+			return m.Schema
+		}
+	}
+	return nil
+}